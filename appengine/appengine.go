@@ -16,8 +16,14 @@
 package main // import "bramp.net/myip/appengine"
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"bramp.net/myip/lib/conf"
 	"bramp.net/myip/lib/myip"
@@ -102,6 +108,21 @@ func config() *conf.Config {
 
 	}
 
+	if socket := os.Getenv("UNIX_SOCKET"); socket != "" {
+		config.UnixSocket = socket
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		config.LogFormat = format
+	}
+	if config.UnixSocket != "" && config.IPHeader == "" && len(config.IPHeaders) == 0 {
+		// The unix socket has no peer address, so rely on the proxy in front of us.
+		config.IPHeader = "X-Forwarded-For"
+	}
+
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid config: %s", err)
+	}
+
 	config.Version = Version
 	config.BuildTime = BuildTime
 
@@ -125,7 +146,12 @@ func main() {
 
 	config := config()
 
-	myip.Register(r, config)
+	s := myip.NewServer(r, config, os.Stderr)
+
+	if config.UnixSocket != "" {
+		serveUnix(s, config.UnixSocket)
+		return
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -133,16 +159,71 @@ func main() {
 		log.Printf("Defaulting to port %s", port)
 	}
 
-	s := &http.Server{
-		Addr: ":" + port,
-
-		// Log all requests using the standard Apache format.
-		// TODO Ensure this is following the AppEngine best practices
-		Handler: handlers.CombinedLoggingHandler(os.Stderr, r),
+	l, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("Listen(%q) failed: %s", port, err)
 	}
 
 	log.Printf("Listening on port %s", port)
-	if err := s.ListenAndServe(); err != nil {
-		log.Fatalf("ListenAndServe() failed: %s:", err)
+	serve(s, l)
+}
+
+// shutdownTimeout is how long in-flight requests are given to finish when shutting down.
+const shutdownTimeout = 30 * time.Second
+
+// serve serves on the listener until SIGINT or SIGTERM, when it gracefully shuts down.
+func serve(s *http.Server, l net.Listener) {
+	done := make(chan struct{})
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer close(done)
+		<-sigs
+
+		log.Printf("Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := myip.Shutdown(ctx, s); err != nil {
+			log.Warnf("Shutdown() failed: %s", err)
+		}
+	}()
+
+	if err := s.Serve(l); err != http.ErrServerClosed {
+		log.Fatalf("Serve() failed: %s:", err)
+	}
+	<-done
+}
+
+// unixSocketMode allows the reverse proxy (expected to be in the same group) to connect.
+const unixSocketMode = 0660
+
+// serveUnix serves on a unix domain socket at path, removing the socket when shut down.
+func serveUnix(s *http.Server, path string) {
+	l, err := listenUnix(path)
+	if err != nil {
+		log.Fatalf("Failed to listen on the unix socket: %s", err)
+	}
+
+	// Shutting down the server closes the listener, which unlinks the socket.
+	log.Printf("Listening on unix socket %s", path)
+	serve(s, l)
+}
+
+// listenUnix listens on a unix domain socket at path, replacing any stale socket left behind by a
+// previous unclean exit.
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("removing stale socket %q: %w", path, err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %q: %w", path, err)
+	}
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("changing the mode of socket %q: %w", path, err)
 	}
+	return l, nil
 }
@@ -0,0 +1,78 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appengine")
+	if err != nil {
+		t.Fatalf("TempDir() err = %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "myip.sock")
+
+	// Leave a stale socket behind, as if from a previous unclean exit.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen(%q) err = %s", path, err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Stat(%q) err = %s, want the stale socket", path, err)
+	}
+
+	l, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix(%q) with a stale socket err = %s, want it replaced", path, err)
+	}
+
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go s.Serve(l)
+	defer s.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatalf("Get() over %q err = %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("Get() over %q = (%d, %q), want (%d, %q)", path, resp.StatusCode, body, http.StatusOK, "ok")
+	}
+
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != unixSocketMode {
+		t.Errorf("Stat(%q) = (%v, %v), want mode %o", path, fi, err, unixSocketMode)
+	}
+}
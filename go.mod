@@ -3,6 +3,7 @@ module bramp.net/myip
 go 1.14
 
 require (
+	github.com/andybalholm/brotli v1.0.0
 	github.com/domainr/whois v0.0.0-20180714175948-975c7833b02e
 	github.com/domainr/whoistest v0.0.0-20180714175718-26cad4b7c941 // indirect
 	github.com/gorilla/handlers v1.4.2
@@ -10,13 +11,19 @@ require (
 	github.com/kylelemons/godebug v1.1.0
 	github.com/mattn/goveralls v0.0.6 // indirect
 	github.com/nbio/st v0.0.0-20140626010706-e9e8d9816f32 // indirect
+	github.com/oschwald/maxminddb-golang v1.7.0
+	github.com/prometheus/client_golang v1.7.1
 	github.com/saintfish/chardet v0.0.0-20120816061221-3af4cd4741ca // indirect
 	github.com/sirupsen/logrus v1.6.0
-	github.com/stretchr/testify v1.3.0 // indirect
 	github.com/ua-parser/uap-go v0.0.0-20200325213135-e1c09f13e2fe
 	github.com/unrolled/secure v1.0.8
 	github.com/zonedb/zonedb v1.0.2750 // indirect
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
+	golang.org/x/net v0.0.0-20200707034311-ab3426394381
+	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
+	golang.org/x/text v0.3.3
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
 	golang.org/x/tools v0.0.0-20200717024301-6ddee64345a6 // indirect
 	google.golang.org/appengine v1.6.6
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
 )
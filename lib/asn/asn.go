@@ -0,0 +1,182 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package asn looks up the Autonomous System announcing an IP address, using Team Cymru's
+// IP to ASN mapping DNS service (https://team-cymru.com/community-services/ip-asn-mapping/).
+package asn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	origin4Zone = "origin.asn.cymru.com"
+	origin6Zone = "origin6.asn.cymru.com"
+	asnZone     = "asn.cymru.com"
+)
+
+// txtResolver is the subset of net.Resolver we use, so it can be faked in tests.
+type txtResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+var resolver txtResolver = &net.Resolver{
+	PreferGo: true,
+}
+
+// Response contains the ASN data we send to the user.
+type Response struct {
+	Query string
+
+	Number       int    `json:",omitempty"`
+	Organization string `json:",omitempty"`
+	Prefix       string `json:",omitempty"` // The announced prefix containing Query
+	Country      string `json:",omitempty"`
+	Registry     string `json:",omitempty"`
+
+	Error string `json:",omitempty"`
+}
+
+// Handle generates a asn.Response for the given IP address.
+func Handle(ctx context.Context, ipAddr string) *Response {
+	resp, err := Lookup(ctx, ipAddr)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// Lookup returns the AS announcing the most specific prefix containing the address. The returned
+// Response is never nil, but may be incomplete if a error is returned.
+func Lookup(ctx context.Context, ipAddr string) (*Response, error) {
+	resp := &Response{
+		Query: ipAddr,
+	}
+
+	name, err := originName(ipAddr)
+	if err != nil {
+		return resp, err
+	}
+
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return resp, err
+	}
+
+	// Each record is a announced prefix, pick the most specific.
+	bestLen := -1
+	for _, record := range records {
+		o, err := parseOrigin(record)
+		if err != nil {
+			continue
+		}
+		_, prefix, err := net.ParseCIDR(o.Prefix)
+		if err != nil {
+			continue
+		}
+		if l, _ := prefix.Mask.Size(); l > bestLen {
+			bestLen = l
+			resp.Number = o.Number
+			resp.Prefix = o.Prefix
+			resp.Country = o.Country
+			resp.Registry = o.Registry
+		}
+	}
+
+	if resp.Number == 0 {
+		return resp, fmt.Errorf("no AS found for %q", ipAddr)
+	}
+
+	// The organization is not critical, so if it fails, just return what we have.
+	if records, err := resolver.LookupTXT(ctx, fmt.Sprintf("AS%d.%s", resp.Number, asnZone)); err == nil && len(records) > 0 {
+		resp.Organization = parseASName(records[0])
+	}
+
+	return resp, nil
+}
+
+// originName returns the DNS name to query for the address's origin AS.
+func originName(ipAddr string) (string, error) {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address %q", ipAddr)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.%s", ip4[3], ip4[2], ip4[1], ip4[0], origin4Zone), nil
+	}
+
+	// IPv6 addresses are queried by reversed nibbles, similar to ip6.arpa
+	const hex = "0123456789abcdef"
+	name := make([]byte, 0, 4*len(ip)+len(origin6Zone))
+	for i := len(ip) - 1; i >= 0; i-- {
+		name = append(name, hex[ip[i]&0xf], '.', hex[ip[i]>>4], '.')
+	}
+	return string(name) + origin6Zone, nil
+}
+
+// origin is a parsed origin record.
+type origin struct {
+	Number   int
+	Prefix   string
+	Country  string
+	Registry string
+}
+
+// parseOrigin parses a origin TXT record, such as "15169 | 8.8.8.0/24 | US | arin | 1992-12-01".
+// If the prefix is announced by multiple ASes, the first is used.
+func parseOrigin(record string) (*origin, error) {
+	fields := splitRecord(record)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("malformed origin record %q", record)
+	}
+
+	asns := strings.Fields(fields[0])
+	if len(asns) == 0 {
+		return nil, fmt.Errorf("malformed origin record %q", record)
+	}
+	number, err := strconv.Atoi(asns[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed origin record %q: %s", record, err)
+	}
+
+	return &origin{
+		Number:   number,
+		Prefix:   fields[1],
+		Country:  fields[2],
+		Registry: fields[3],
+	}, nil
+}
+
+// parseASName returns the AS name from a AS TXT record, such as
+// "15169 | US | arin | 2000-03-30 | GOOGLE - Google LLC, US".
+func parseASName(record string) string {
+	fields := splitRecord(record)
+	if len(fields) < 5 {
+		return ""
+	}
+	return fields[4]
+}
+
+func splitRecord(record string) []string {
+	fields := strings.Split(record, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// fakeResolver returns canned TXT records.
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if records, found := f[name]; found {
+		return records, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestOriginName(t *testing.T) {
+	data := []struct {
+		input string
+		want  string
+	}{
+		{input: "8.8.8.8", want: "8.8.8.8.origin.asn.cymru.com"},
+		{input: "192.0.2.1", want: "1.2.0.192.origin.asn.cymru.com"},
+		{input: "2001:db8::1", want: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com"},
+	}
+
+	for _, test := range data {
+		got, err := originName(test.input)
+		if err != nil || got != test.want {
+			t.Errorf("originName(%q) = (%q, %v), want (%q, nil)", test.input, got, err, test.want)
+		}
+	}
+
+	if _, err := originName("not an ip"); err == nil {
+		t.Errorf("originName(%q) err = nil, want error", "not an ip")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	old := resolver
+	defer func() { resolver = old }()
+
+	resolver = fakeResolver{
+		"8.8.8.8.origin.asn.cymru.com": {
+			"15169 | 8.0.0.0/9 | US | arin | 1992-12-01",
+			"15169 | 8.8.8.0/24 | US | arin | 1992-12-01",
+		},
+		"AS15169.asn.cymru.com": {
+			"15169 | US | arin | 2000-03-30 | GOOGLE - Google LLC, US",
+		},
+	}
+
+	got, err := Lookup(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Lookup(%q) err: %q, want nil", "8.8.8.8", err)
+	}
+
+	want := &Response{
+		Query:        "8.8.8.8",
+		Number:       15169,
+		Organization: "GOOGLE - Google LLC, US",
+		Prefix:       "8.8.8.0/24",
+		Country:      "US",
+		Registry:     "arin",
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("Lookup(%q) diff: (-got +want)\n%s", "8.8.8.8", diff)
+	}
+
+	if got := Handle(context.Background(), "192.0.2.1"); got.Number != 0 || got.Error == "" {
+		t.Errorf("Handle(%q) = %+v, want a error", "192.0.2.1", got)
+	}
+}
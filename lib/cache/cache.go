@@ -0,0 +1,127 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache provides a size bounded, in-memory, LRU cache whose entries expire.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is a LRU cache holding at most size entries, each of which expires after its TTL. It is
+// safe for concurrent use.
+type Cache struct {
+	size     int
+	ttl      time.Duration
+	compress bool
+
+	mu    sync.Mutex
+	ll    *list.List // Most recently used at the front
+	items map[string]*list.Element
+
+	now func() time.Time // Overridden in tests
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// New returns a Cache holding at most size entries, which by default expire after ttl.
+func New(size int, ttl time.Duration, opts ...Option) *Cache {
+	c := &Cache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get returns the value stored under this key, if it exists and has not expired.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+
+	ent := e.Value.(*entry)
+	if c.now().After(ent.expires) {
+		c.remove(e)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(e)
+	if c.compress {
+		return decode(ent.value)
+	}
+	return ent.value, true
+}
+
+// Set stores the value under this key, using the cache's default TTL.
+func (c *Cache) Set(key string, value interface{}) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores the value under this key, expiring after the ttl.
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	if c.compress {
+		value = encode(value) // Outside the lock, as it may be slow
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if e, found := c.items[key]; found {
+		ent := e.Value.(*entry)
+		ent.value = value
+		ent.expires = expires
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry{
+		key:     key,
+		value:   value,
+		expires: expires,
+	})
+
+	for c.size > 0 && c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Len returns the number of entries in the cache, including any that have expired but not yet been
+// removed.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*entry).key)
+}
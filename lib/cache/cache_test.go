@@ -0,0 +1,91 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestCacheExpires(t *testing.T) {
+	now := time.Now()
+	c := New(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	if got, found := c.Get("a"); !found || got != 1 {
+		t.Errorf("Get(%q) = (%v, %v), want (1, true)", "a", got, found)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got, found := c.Get("a"); found {
+		t.Errorf("Get(%q) = (%v, %v) after expiry, want (nil, false)", "a", got, found)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(2, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a") // Makes "b" the least recently used
+	c.Set("c", 3)
+
+	if _, found := c.Get("b"); found {
+		t.Errorf("Get(%q) found, want evicted", "b")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found := c.Get(key); !found {
+			t.Errorf("Get(%q) not found, want found", key)
+		}
+	}
+	if got := c.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+}
+
+func TestCacheCompressed(t *testing.T) {
+	c := New(10, time.Minute, Compressed())
+
+	big := strings.Repeat("whois ", 1000)
+	data := map[string]interface{}{
+		"string": big,
+		"bytes":  []byte(big),
+		"small":  "small",
+		"int":    42,
+	}
+
+	for key, value := range data {
+		c.Set(key, value)
+	}
+
+	if _, ok := c.items["string"].Value.(*entry).value.(*compressed); !ok {
+		t.Errorf("large string was not stored compressed")
+	}
+
+	for key, want := range data {
+		got, found := c.Get(key)
+		if !found {
+			t.Errorf("Get(%q) not found", key)
+			continue
+		}
+		if diff := pretty.Compare(got, want); diff != "" {
+			t.Errorf("Get(%q) diff: (-got +want)\n%s", key, diff)
+		}
+	}
+}
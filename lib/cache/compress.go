@@ -0,0 +1,98 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// compressMinSize is the smallest value worth compressing.
+const compressMinSize = 256
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// Compressed stores large string and []byte values gzip compressed, decompressing them when read.
+// This trades CPU for memory, and is useful when caching many large values (such as whois bodies).
+// Other types of value (such as structs, or pointers to them) are never compressed, so this option
+// is only worth using for caches mostly holding large strings or []byte.
+func Compressed() Option {
+	return func(c *Cache) {
+		c.compress = true
+	}
+}
+
+// compressed is a value stored compressed.
+type compressed struct {
+	data     []byte
+	isString bool
+}
+
+// encode returns the value to store, compressing it if worthwhile.
+func encode(value interface{}) interface{} {
+	var b []byte
+	isString := false
+
+	switch v := value.(type) {
+	case string:
+		b, isString = []byte(v), true
+	case []byte:
+		b = v
+	default:
+		return value
+	}
+
+	if len(b) < compressMinSize {
+		return value
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return value
+	}
+	if err := w.Close(); err != nil {
+		return value
+	}
+
+	return &compressed{
+		data:     buf.Bytes(),
+		isString: isString,
+	}
+}
+
+// decode returns the original value.
+func decode(value interface{}) (interface{}, bool) {
+	c, ok := value.(*compressed)
+	if !ok {
+		return value, true
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(c.data))
+	if err != nil {
+		return nil, false
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, false
+	}
+
+	if c.isString {
+		return string(b), true
+	}
+	return b, true
+}
@@ -14,7 +14,12 @@
 
 package conf
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
 
 // Config contains all the configuration options for this application.
 // TODO Turn this into a config file that gets parsed onstartup
@@ -25,13 +30,64 @@ type Config struct {
 	// The build time
 	BuildTime string
 
+	// NodeName identifies this instance (such as the region or edge it runs in) in the response,
+	// and the X-Served-By header. Defaults to the hostname.
+	NodeName string `json:",omitempty"`
+
 	Host  string `json:",omitempty"`
 	Host4 string `json:",omitempty"`
 	Host6 string `json:",omitempty"`
 
-	// Debug enables unsafe options for debugging
+	// Debug enables unsafe options for debugging, such as overriding the client's address with the
+	// "host" query param. Lookups for an overridden address bypass all shared caches (and are not
+	// recorded in the client token history), so testing one address never affects what real
+	// clients are served.
 	Debug bool `json:",omitempty"`
 
+	// DebugToken allows access to the /debug/ endpoints when not in debug mode, if sent as a
+	// "Authorization: Bearer <DebugToken>" header. Empty disables access.
+	DebugToken string `json:",omitempty"`
+
+	// UnixSocket is the path of a unix domain socket to listen on, instead of a TCP port. As the
+	// socket has no peer address, the client's address must then come from IPHeader, which
+	// defaults to "X-Forwarded-For".
+	UnixSocket string `json:",omitempty"`
+
+	// IPHeader is the header with the client's IP address, when behind a proxy. It may be a comma
+	// separated list (such as X-Forwarded-For), in which case the client is the last address that
+	// isn't one of the TrustedProxies, or the first address if all (or none) are trusted. When
+	// empty the address of the connection is used.
+	// Examples:
+	//   "Cf-Connecting-Ip" for CloudFlare
+	//   "X-Forwarded-For" for most load balancers
+	IPHeader string `json:",omitempty"`
+
+	// IPHeaders are headers with the client's IP address, tried in order, using the first that is
+	// present in the request. This is for layered proxies, where the best header depends on the
+	// path the request took, e.g. ["Cf-Connecting-Ip", "True-Client-Ip", "X-Forwarded-For"]. Each
+	// is parsed the same as IPHeader, which is ignored if this is set.
+	IPHeaders []string `json:",omitempty"`
+
+	// TrustedHeaderSecret, if set, means the IPHeader (and IPHeaders) are only honored on requests
+	// that also have the TrustedSecretHeader with this value, which the proxy must be configured to
+	// add. Otherwise the address of the connection is used. This stops clients spoofing their
+	// address, when the server can be reached both directly and through the proxy.
+	TrustedHeaderSecret string `json:",omitempty"`
+
+	// TrustedSecretHeader is the header with the TrustedHeaderSecret. Defaults to "X-Proxy-Secret".
+	TrustedSecretHeader string `json:",omitempty"`
+
+	// TrustedProxies lists the CIDRs (or addresses) of proxies that are trusted to appear in the
+	// IPHeader. These are skipped when finding the client in the forwarded chain, as any address
+	// before them could have been spoofed by the client. Other private addresses in the forwarded
+	// chain are flagged as suspicious. The X-Forwarded-Proto header is only honored on requests
+	// directly from a trusted proxy.
+	TrustedProxies []string `json:",omitempty"`
+
+	// MaxForwardedHops is the maximum number of entries parsed from the IPHeader. Any more are
+	// ignored, and noted in the response's Insights. Defaults to 50.
+	MaxForwardedHops int `json:",omitempty"`
+
 	// LatLongHeader is the header with the LatLong information
 	// Examples:
 	//   "X-Appengine-Citylatlong" for App Engine (Standard)
@@ -47,19 +103,332 @@ type Config struct {
 	RegionHeader  string `json:",omitempty"`
 	CountryHeader string `json:",omitempty"`
 
-	// RequestIDHeader is the header with the Request ID
+	// LocationProvider selects where the client's location comes from, one of:
+	//   "headers" (the default) from the LatLongHeader, CityHeader, etc added by a proxy
+	//   "mmdb" from a local MaxMind DB file (such as GeoLite2 City), see LocationDatabase
+	//   "none" to never return a location
+	LocationProvider string `json:",omitempty"`
+
+	// LocationDatabase is the path to the MaxMind DB file, used by the "mmdb" LocationProvider.
+	LocationDatabase string `json:",omitempty"`
+
+	// LogFormat is the format of the access log, either "apache" (the default) for the Apache
+	// combined log format, or "json" for one JSON object per request.
+	LogFormat string `json:",omitempty"`
+
+	// RequestIDHeader is the header with the Request ID. If the request has none, a random one is
+	// generated. Either way it is returned in the X-Request-Id response header. Defaults to
+	// "X-Request-Id".
 	// Examples:
 	//   "Cf-Ray" for CloudFlare
-	//   "X-Appengine-City" for App Engine (Standard)
+	//   "X-Cloud-Trace-Context" for App Engine (Standard)
 	RequestIDHeader string `json:",omitempty"`
 
+	// CLIUserAgents are the User-Agent prefixes (matched ignoring case) of cli tools, which are
+	// served plain text. Defaults to "curl/" and "Wget/".
+	// Example:
+	//   ["curl/", "Wget/", "HTTPie/", "Mozilla/5.0 (Windows NT; Windows NT 10.0; en-US) WindowsPowerShell/"]
+	CLIUserAgents []string `json:",omitempty"`
+
 	// DisallowedHeaders is a list of headers filtered from the response. These either add no value
 	// or leak information that we don't want displayed to the user.
 	DisallowedHeaders []string `json:",omitempty"`
 
+	// EchoHeaders is the list of request headers echoed back in the response. Defaults to
+	// User-Agent, Accept, Accept-Language and X-Forwarded-For. Authorization, Cookie,
+	// Proxy-Authorization and X-Api-Key are never echoed, even if listed.
+	EchoHeaders []string `json:",omitempty"`
+
+	// RedactedHeaders is a list of headers whose values are replaced with "[redacted]" in the
+	// response, in addition to Authorization, Cookie, Proxy-Authorization and X-Api-Key which
+	// are always redacted.
+	RedactedHeaders []string `json:",omitempty"`
+
 	// MapsAPIKey is used to render static Google Maps.
 	// Request your own at https://developers.google.com/maps/documentation/static-maps/
 	MapsAPIKey string `json:",omitempty"`
+
+	// AllowedOrigins are the origins (e.g. "https://example.com") allowed to read the responses
+	// cross-origin, in addition to the Host. "*" allows any origin.
+	AllowedOrigins []string `json:",omitempty"`
+
+	// AllowJSONP allows the /json endpoint's "callback" query parameter, to wrap the response as
+	// JSONP. Any site can then read the responses, regardless of AllowedOrigins, so it's off by
+	// default.
+	AllowJSONP bool `json:",omitempty"`
+
+	// ContentSecurityPolicy replaces the default Content-Security-Policy header, which allows the
+	// AnalyticsHosts, and Google Maps if MapsAPIKey is set.
+	ContentSecurityPolicy string `json:",omitempty"`
+
+	// AnalyticsHosts are the hosts the web-app may load analytics scripts and images from, as
+	// allowed by the default ContentSecurityPolicy. Defaults to "www.google-analytics.com".
+	AnalyticsHosts []string `json:",omitempty"`
+
+	// DisableSSLRedirect stops plain HTTP requests being redirected to HTTPS, such as when a proxy
+	// in front terminates TLS, or in staging environments without a certificate.
+	DisableSSLRedirect bool `json:",omitempty"`
+
+	// STSSeconds is the max-age of the Strict-Transport-Security header. Defaults to one year, and
+	// a negative value omits the header.
+	STSSeconds int64 `json:",omitempty"`
+
+	// DisableSTSPreload omits "preload" from the Strict-Transport-Security header.
+	DisableSTSPreload bool `json:",omitempty"`
+
+	// DisableFrameDeny allows the pages to be embedded in frames by other sites, by omitting the
+	// X-Frame-Options header. The /embed endpoint can always be framed.
+	DisableFrameDeny bool `json:",omitempty"`
+
+	// Organizations maps CIDRs to the name of the organization that owns them. When the client's
+	// address falls within one, it overrides the organization shown in the response. This allows
+	// operators to correct, or annotate, ranges they know the owner of.
+	// Example:
+	//   {"203.0.113.0/24": "Example Corp"}
+	Organizations map[string]string `json:",omitempty"`
+
+	// OrgLogoURL is a URL template for a logo of the client's organization, with "{domain}"
+	// replaced by the organization's domain (taken from the reverse DNS or whois). The logo is not
+	// fetched, only the URL returned. Empty disables it.
+	// Example:
+	//   "https://logo.example.com/{domain}"
+	OrgLogoURL string `json:",omitempty"`
+
+	// DisabledLookups lists lookups that are never performed, each one of "dns", "whois", "asn",
+	// "location" or "ua". Clients may further restrict the lookups with the "include" and
+	// "exclude" query parameters, but can not enable these.
+	DisabledLookups []string `json:",omitempty"`
+
+	// DisabledEndpoints lists paths, such as "/asn" or "/embed", that are not served, and instead
+	// return 404. This allows operators to expose only the endpoints they need.
+	DisabledEndpoints []string `json:",omitempty"`
+
+	// IncludeGranularities adds the location at each granularity (country, region and city), each
+	// with its own confidence when the provider supports it, alongside the flat location fields.
+	IncludeGranularities bool `json:",omitempty"`
+
+	// IncludeNearestIX adds the nearest major internet exchange to the client's location. It is
+	// omitted when the location is not precise.
+	IncludeNearestIX bool `json:",omitempty"`
+
+	// CentroidToleranceKm is how close (in km) a location must be to its country's centroid, to be
+	// flagged as the geo database's country level fallback. Defaults to 1km.
+	CentroidToleranceKm float64 `json:",omitempty"`
+
+	// EnrichmentRate is the number of requests per second, per client address, above which the
+	// expensive lookups (DNS, whois and user agent) are skipped. The request still succeeds, with
+	// just the address and location, and is marked as Throttled. Zero disables this.
+	EnrichmentRate float64 `json:",omitempty"`
+
+	// EnrichmentBurst is the number of requests allowed in a burst before EnrichmentRate applies.
+	// Defaults to 1.
+	EnrichmentBurst int `json:",omitempty"`
+
+	// RateLimit is the number of requests per second, per client address, above which requests are
+	// rejected with 429 Too Many Requests. Health checks and static files are exempt. Zero disables
+	// this.
+	RateLimit float64 `json:",omitempty"`
+
+	// RateLimitBurst is the number of requests allowed in a burst before RateLimit applies.
+	// Defaults to 1.
+	RateLimitBurst int `json:",omitempty"`
+
+	// LookupTimeout bounds how long each lookup (DNS, whois and location) may take. A lookup that
+	// takes longer is omitted from the response, instead of delaying it. Zero means no timeout.
+	LookupTimeout time.Duration `json:",omitempty"`
+
+	// RequestTimeout bounds how long each request may take in total, after which it's cancelled,
+	// and answered with a 503 Service Unavailable. This doesn't apply to the health checks, or the
+	// streaming endpoints (/events and /batch). Zero means no timeout.
+	RequestTimeout time.Duration `json:",omitempty"`
+
+	// VerifyReverseDNS checks each reverse DNS name resolves back to the client's address
+	// (forward-confirmed reverse DNS), marking those that don't, as they may be spoofed.
+	VerifyReverseDNS bool `json:",omitempty"`
+
+	// DNSCacheTTL is how long reverse DNS results are cached for, including negative results (such
+	// as no PTR record). Failures, such as timeouts, are never cached. Zero disables the cache.
+	DNSCacheTTL time.Duration `json:",omitempty"`
+
+	// DNSResolverCheck reports which DNS resolver (and EDNS Client Subnet) looked up the client's
+	// hostname. The server answers DNS queries for the ResolverZone, recording the resolver of each
+	// "<token>.<ResolverZone>" hostname. The client looks up such a hostname (for example by
+	// fetching "https://<token>.<ResolverZone>/ip"), then sends the same "token" query parameter
+	// with its request, which has the resolver in its ResolverAddr.
+	//
+	// This requires the ResolverZone to be delegated to this server, with a NS record in its
+	// parent zone, and for UDP port 53 (or ResolverListen) to be reachable.
+	DNSResolverCheck bool `json:",omitempty"`
+
+	// ResolverZone is the zone delegated to this server for the DNSResolverCheck, e.g.
+	// "resolver.ip.example.com".
+	ResolverZone string `json:",omitempty"`
+
+	// ResolverAnswers are the addresses returned for the ResolverZone's hostnames, normally those
+	// of this server.
+	ResolverAnswers []string `json:",omitempty"`
+
+	// ResolverListen is the UDP address the DNSResolverCheck name server listens on. Defaults to
+	// ":53".
+	ResolverListen string `json:",omitempty"`
+
+	// SlowLookupThreshold logs (at warning level) any lookup taking longer than this. Zero disables
+	// the logging.
+	SlowLookupThreshold time.Duration `json:",omitempty"`
+
+	// DualStackReverse correlates the IPv4 and IPv6 requests of a dual-stacked client and includes
+	// the reverse DNS of both addresses. The client correlates its requests by sending the same
+	// unguessable "token" query parameter with each. Tokens are remembered in memory, for a short
+	// time.
+	DualStackReverse bool `json:",omitempty"`
+
+	// KeepActualRemotePort keeps the source port in the response's ActualRemoteAddr (as the full
+	// "host:port" observed on the connection). By default only the host is included. This does not
+	// affect RemoteAddr, which is always just the host, nor RemoteAddrPort, which is always the
+	// client's source port when it's connected directly. Behind a proxy (when the address came from
+	// the IPHeader) RemoteAddrPort is omitted, as the port kept in ActualRemoteAddr is the proxy's.
+	KeepActualRemotePort bool `json:",omitempty"`
+
+	// TrackCountryChanges remembers the country of each client (identified by their "token" query
+	// parameter), and flags when it changes. This is best effort, as it's only kept in memory for a
+	// bounded number of clients, and for at most a day.
+	TrackCountryChanges bool `json:",omitempty"`
+
+	// IncludeTimings adds how long each lookup took to the response. This is always included in
+	// debug mode.
+	IncludeTimings bool `json:",omitempty"`
+
+	// ServerTiming adds a Server-Timing header with how long each lookup took, so they can be seen
+	// in the browser's developer tools. This is always added in debug mode.
+	ServerTiming bool `json:",omitempty"`
+
+	// WhoisMirrors lists alternative whois servers for a registry, keyed by the registry's whois
+	// server. Queries are spread across them using weighted round-robin, temporarily avoiding any
+	// that return errors.
+	// Example:
+	//   {"whois.arin.net": [{"Host": "whois.arin.net", "Weight": 2}, {"Host": "rr.arin.net"}]}
+	WhoisMirrors map[string][]WhoisMirror `json:",omitempty"`
+
+	// WhoisTimeout bounds how long each whois query may take, including connecting. Defaults to
+	// 10 seconds.
+	WhoisTimeout time.Duration `json:",omitempty"`
+
+	// WhoisCacheTTL is how long successful whois results are cached for. Results are cached by the
+	// network they describe, so are shared by every address in it. Zero disables the cache.
+	WhoisCacheTTL time.Duration `json:",omitempty"`
+
+	// WhoisErrorCacheTTL is how long failed (or empty) whois results are cached for, so a failing
+	// whois server isn't queried for every request. Zero doesn't cache failures. This only applies
+	// if WhoisCacheTTL is set.
+	WhoisErrorCacheTTL time.Duration `json:",omitempty"`
+
+	// IncludeSecurityPosture adds a summary of the connection's security (TLS version, cipher
+	// strength, HSTS, and a overall grade) to the response.
+	IncludeSecurityPosture bool `json:",omitempty"`
+
+	// CompressCache stores the cached whois bodies gzip compressed in memory. This trades CPU for
+	// memory, so is only worthwhile on instances caching many addresses. The other caches (such as
+	// the DNS cache) hold small structured values, which are never compressed.
+	CompressCache bool `json:",omitempty"`
+
+	// MaxResponseBytes bounds the size of a (JSON encoded) response, by trimming the whois body
+	// and then the reverse DNS names, as needed. The trimmed fields are listed in the response's
+	// Truncated field. This is best effort, the other fields are never trimmed. Zero means no
+	// limit.
+	MaxResponseBytes int `json:",omitempty"`
+
+	// MaxHeaderBytes rejects requests whose headers are larger than this, with a 431 Request Header
+	// Fields Too Large. Zero means no limit, beyond the http.Server's default of 1MB.
+	MaxHeaderBytes int `json:",omitempty"`
+
+	// MaxBatchSize is the most addresses that may be looked up by one request to /batch. Defaults
+	// to 100.
+	MaxBatchSize int `json:",omitempty"`
+
+	// ResponseEnvelope wraps JSON responses as {"data": ..., "meta": ...}, with the request's
+	// metadata (request ID, timings and server time) under meta. Errors are returned under
+	// "error" instead of "data".
+	ResponseEnvelope bool `json:",omitempty"`
+
+	// CompressResponses compresses responses with Brotli or gzip, when the client accepts them.
+	CompressResponses bool `json:",omitempty"`
+
+	// BrotliQuality is the Brotli compression level, between 1 (fastest) and 11 (smallest).
+	// Zero uses the library's default.
+	BrotliQuality int `json:",omitempty"`
+
+	// IncludeIPHash adds a keyed hash (HMAC-SHA256) of the client's address, using IPHashSecret, to
+	// the response. This allows unique visitors to be counted without storing their address.
+	IncludeIPHash bool `json:",omitempty"`
+
+	// IPHashSecret is the key for the IPHash, required if IncludeIPHash is set. Keep it secret, as
+	// with it the hashes can be reversed by brute force. Changing it changes every hash, so
+	// visitors before and after the rotation can no longer be matched.
+	IPHashSecret string `json:",omitempty"`
+
+	// MetricsEnabled exposes Prometheus metrics (request counts, and lookup latencies and
+	// failures) on /metrics.
+	MetricsEnabled bool `json:",omitempty"`
+
+	// RefreshInterval is how often local data sources (such as geo databases) are reloaded in the
+	// background. Zero disables the periodic refresh.
+	RefreshInterval time.Duration `json:",omitempty"`
+}
+
+// WhoisMirror is a whois server, and its relative weight when spreading queries across a
+// registry's mirrors.
+type WhoisMirror struct {
+	Host   string
+	Weight int `json:",omitempty"` // Defaults to 1
+}
+
+// redacted is what secret fields are replaced with by Redacted.
+const redacted = "[redacted]"
+
+// Redacted returns a copy of the config with any secrets masked, so it's safe to display.
+func (c *Config) Redacted() *Config {
+	configCopy := &Config{}
+	*configCopy = *c
+
+	for _, secret := range []*string{
+		&configCopy.DebugToken,
+		&configCopy.MapsAPIKey,
+		&configCopy.IPHashSecret,
+		&configCopy.TrustedHeaderSecret,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+
+	return configCopy
+}
+
+// Validate returns a error if the config is inconsistent, such as a feature being enabled
+// without its required settings.
+func (c *Config) Validate() error {
+	if c.IncludeIPHash && c.IPHashSecret == "" {
+		return errors.New("IncludeIPHash requires IPHashSecret to be set")
+	}
+	if c.DNSResolverCheck && c.ResolverZone == "" {
+		return errors.New("DNSResolverCheck requires ResolverZone to be set")
+	}
+	switch c.LogFormat {
+	case "", "apache", "json":
+	default:
+		return fmt.Errorf("unknown LogFormat %q", c.LogFormat)
+	}
+
+	switch c.LocationProvider {
+	case "", "headers", "none":
+	case "mmdb":
+		if c.LocationDatabase == "" {
+			return errors.New("the mmdb LocationProvider requires LocationDatabase to be set")
+		}
+	default:
+		return fmt.Errorf("unknown LocationProvider %q", c.LocationProvider)
+	}
+	return nil
 }
 
 // ApplyDefaults returns a new config with any zero field in config, set to the default value.
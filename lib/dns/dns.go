@@ -16,16 +16,33 @@ package dns
 
 import (
 	"context"
+	"errors"
 	"net"
 	"time"
+
+	"golang.org/x/net/idna"
 )
 
 const (
 	dnsTimeout = 4 * time.Second
 )
 
+// The possible values of Response.Status.
+const (
+	StatusNoError  = "NOERROR"  // The lookup succeeded, although there may be no names.
+	StatusNXDomain = "NXDOMAIN" // No PTR record exists.
+	StatusServFail = "SERVFAIL" // The resolver failed to answer.
+	StatusTimeout  = "TIMEOUT"  // The resolver did not answer in time.
+)
+
+// resolver is the subset of net.Resolver we use, so it can be faked in tests.
+type resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
 var (
-	dns = &net.Resolver{
+	dns resolver = &net.Resolver{
 		PreferGo: true,
 
 		// TODO In future perhaps override `Dial` so we can force the DNS server that is used.
@@ -37,8 +54,25 @@ type Response struct {
 	Query string
 
 	// One of the following
-	Names []string `json:",omitempty"`
+	Names []string `json:",omitempty" xml:"Names>Name,omitempty"`
 	Error string   `json:",omitempty"`
+
+	// UnicodeNames are the Names decoded from punycode (e.g. "xn--mnchen-3ya.example." is
+	// "münchen.example."), in the same order. Names that aren't valid punycode are unchanged.
+	// Omitted if none of the Names are punycode.
+	UnicodeNames []string `json:",omitempty" xml:"UnicodeNames>Name,omitempty"`
+
+	// Status distinguishes why there may be no Names, one of the Status constants.
+	Status string
+
+	// Verified records, for each of the Names, if it forward resolves back to Query (that is
+	// forward-confirmed reverse DNS). A unverified name may be spoofed. Only set by
+	// HandleVerifiedReverseDNS. Omitted from XML, which can't encode maps.
+	Verified map[string]bool `json:",omitempty" xml:"-"`
+
+	// Secondary is the reverse DNS of the client's address in the other address family, when
+	// the client is dual-stacked and the other address is known.
+	Secondary *Response `json:",omitempty"`
 }
 
 // HandleReverseDNS generates a dns.Response for the given IP address.
@@ -46,8 +80,10 @@ func HandleReverseDNS(ctx context.Context, ipAddr string) *Response {
 	names, err := LookupAddr(ctx, ipAddr)
 
 	resp := &Response{
-		Query: ipAddr,
-		Names: names,
+		Query:        ipAddr,
+		Names:        names,
+		UnicodeNames: unicodeNames(names),
+		Status:       status(err),
 	}
 	if err != nil {
 		resp.Error = err.Error()
@@ -56,6 +92,93 @@ func HandleReverseDNS(ctx context.Context, ipAddr string) *Response {
 	return resp
 }
 
+// unicodeNames returns the names decoded from punycode, or nil if none of them are punycode.
+func unicodeNames(names []string) []string {
+	decoded := make([]string, len(names))
+	changed := false
+	for i, name := range names {
+		decoded[i] = name
+		if unicode, err := idna.Display.ToUnicode(name); err == nil && unicode != name {
+			decoded[i], changed = unicode, true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return decoded
+}
+
+// DisplayNames returns the names for display to people, that is the UnicodeNames if any, otherwise
+// the Names.
+func (r *Response) DisplayNames() []string {
+	if r == nil {
+		return nil
+	}
+	if len(r.UnicodeNames) == len(r.Names) {
+		return r.UnicodeNames
+	}
+	return r.Names
+}
+
+// HandleVerifiedReverseDNS generates a dns.Response for the given IP address, verifying each of
+// the names.
+func HandleVerifiedReverseDNS(ctx context.Context, ipAddr string) *Response {
+	resp := HandleReverseDNS(ctx, ipAddr)
+	resp.Verified = Verify(ctx, ipAddr, resp.Names)
+	return resp
+}
+
+// Verify returns, for each of the names, if it resolves to the address. Names that fail to resolve
+// are unverified.
+func Verify(ctx context.Context, ipAddr string, names []string) map[string]bool {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil || len(names) == 0 {
+		return nil
+	}
+
+	verified := make(map[string]bool, len(names))
+	for _, name := range names {
+		verified[name] = resolvesTo(ctx, name, ip)
+	}
+	return verified
+}
+
+// resolvesTo returns true if one of the name's A or AAAA records is the ip.
+func resolvesTo(ctx context.Context, name string, ip net.IP) bool {
+	addrs, err := dns.LookupIPAddr(ctx, name)
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// status returns the Response.Status for the error returned by LookupAddr.
+func status(err error) string {
+	if err == nil {
+		return StatusNoError
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return StatusTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return StatusNXDomain
+		case dnsErr.IsTimeout:
+			return StatusTimeout
+		}
+	}
+	return StatusServFail
+}
+
 // LookupAddr performs a reverse lookup for the given address, returning a list
 // of names mapping to that address.
 func LookupAddr(ctx context.Context, ipAddr string) ([]string, error) {
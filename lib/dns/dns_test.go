@@ -0,0 +1,125 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestStatus(t *testing.T) {
+	data := []struct {
+		err  error
+		want string
+	}{
+		{nil, StatusNoError},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, StatusNXDomain},
+		{&net.DNSError{Err: "i/o timeout", IsTimeout: true}, StatusTimeout},
+		{&net.DNSError{Err: "server misbehaving", IsTemporary: true}, StatusServFail},
+		{fmt.Errorf("lookup: %w", context.DeadlineExceeded), StatusTimeout},
+		{errors.New("something else"), StatusServFail},
+	}
+
+	for _, test := range data {
+		if got := status(test.err); got != test.want {
+			t.Errorf("status(%v) = %q, want %q", test.err, got, test.want)
+		}
+	}
+}
+
+// fakeResolver answers from static maps.
+type fakeResolver struct {
+	ptr map[string][]string
+	ips map[string][]net.IPAddr
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if names, found := r.ptr[addr]; found {
+		return names, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ips, found := r.ips[host]; found {
+		return ips, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestHandleVerifiedReverseDNS(t *testing.T) {
+	old := dns
+	defer func() { dns = old }()
+
+	dns = &fakeResolver{
+		ptr: map[string][]string{
+			"192.0.2.1": {"good.example.com.", "spoofed.example.net.", "missing.example.org."},
+		},
+		ips: map[string][]net.IPAddr{
+			"good.example.com.":    {{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("192.0.2.1")}},
+			"spoofed.example.net.": {{IP: net.ParseIP("198.51.100.1")}},
+		},
+	}
+
+	got := HandleVerifiedReverseDNS(context.Background(), "192.0.2.1")
+	want := map[string]bool{
+		"good.example.com.":    true,
+		"spoofed.example.net.": false,
+		"missing.example.org.": false,
+	}
+	if diff := pretty.Compare(got.Verified, want); diff != "" {
+		t.Errorf("HandleVerifiedReverseDNS(%q).Verified diff: (-got +want)\n%s", "192.0.2.1", diff)
+	}
+
+	// Without any names, there is nothing to verify
+	if got := HandleVerifiedReverseDNS(context.Background(), "192.0.2.2"); got.Verified != nil || got.Status != StatusNXDomain {
+		t.Errorf("HandleVerifiedReverseDNS(%q) = %+v, want NXDOMAIN and no Verified", "192.0.2.2", got)
+	}
+}
+
+func TestHandleReverseDNSUnicodeNames(t *testing.T) {
+	old := dns
+	defer func() { dns = old }()
+
+	dns = &fakeResolver{
+		ptr: map[string][]string{
+			"192.0.2.1": {"xn--mnchen-3ya.example.", "host.example.com.", "xn--zz.example."},
+			"192.0.2.2": {"host.example.com."},
+		},
+	}
+
+	got := HandleReverseDNS(context.Background(), "192.0.2.1")
+	want := []string{"münchen.example.", "host.example.com.", "xn--zz.example."}
+	if diff := pretty.Compare(got.UnicodeNames, want); diff != "" {
+		t.Errorf("HandleReverseDNS(%q).UnicodeNames diff: (-got +want)\n%s", "192.0.2.1", diff)
+	}
+	if diff := pretty.Compare(got.DisplayNames(), want); diff != "" {
+		t.Errorf("HandleReverseDNS(%q).DisplayNames() diff: (-got +want)\n%s", "192.0.2.1", diff)
+	}
+
+	// No punycode, so there's no need for the UnicodeNames
+	got = HandleReverseDNS(context.Background(), "192.0.2.2")
+	if got.UnicodeNames != nil {
+		t.Errorf("HandleReverseDNS(%q).UnicodeNames = %q, want nil", "192.0.2.2", got.UnicodeNames)
+	}
+	if diff := pretty.Compare(got.DisplayNames(), got.Names); diff != "" {
+		t.Errorf("HandleReverseDNS(%q).DisplayNames() diff: (-got +want)\n%s", "192.0.2.2", diff)
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"bramp.net/myip/lib/cache"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// resolverLogSize is the maximum number of tokens remembered.
+	resolverLogSize = 10000
+
+	// resolverLogTTL is how long a token is remembered for.
+	resolverLogTTL = 5 * time.Minute
+
+	// edns0ClientSubnet is the EDNS0 option code of the client subnet (RFC 7871).
+	edns0ClientSubnet = 8
+)
+
+// ResolverReport is what a NameServer learnt about the resolver that looked up a token's hostname.
+type ResolverReport struct {
+	// Addr is the address the resolver queried from.
+	Addr string
+
+	// ClientSubnet is the client's subnet the resolver sent with the query (using the EDNS Client
+	// Subnet option), e.g. "203.0.113.0/24", or "" if it didn't send one.
+	ClientSubnet string `json:",omitempty"`
+}
+
+// ResolverReports is a source of ResolverReports, such as a ResolverLog.
+type ResolverReports interface {
+	// Report returns the report for the token, or nil if the token's hostname wasn't looked up.
+	Report(token string) *ResolverReport
+}
+
+// ResolverLog remembers the ResolverReport of each token, for a short time. It is safe for
+// concurrent use.
+type ResolverLog struct {
+	reports *cache.Cache
+}
+
+var _ ResolverReports = (*ResolverLog)(nil)
+
+// NewResolverLog returns a empty ResolverLog.
+func NewResolverLog() *ResolverLog {
+	return &ResolverLog{
+		reports: cache.New(resolverLogSize, resolverLogTTL),
+	}
+}
+
+// Record records the report for the token, replacing any earlier report.
+func (l *ResolverLog) Record(token string, report *ResolverReport) {
+	l.reports.Set(strings.ToLower(token), report)
+}
+
+// Report returns the report for the token, or nil if there is none.
+func (l *ResolverLog) Report(token string) *ResolverReport {
+	if report, found := l.reports.Get(strings.ToLower(token)); found {
+		return report.(*ResolverReport)
+	}
+	return nil
+}
+
+// NameServer is a minimal authoritative name server for a zone, that records which resolver
+// looked up each hostname in it. Clients look up "<token>.<zone>", where the token is a unique
+// label, and later ask for the report of that token.
+//
+// For resolvers to query it, the zone must be delegated to this server (with a NS record in the
+// parent zone).
+type NameServer struct {
+	// Zone is the delegated zone, e.g. "resolver.ip.example.com".
+	Zone string
+
+	// Answers are the addresses returned for the A and AAAA queries of the zone's hostnames, such
+	// as those of the web server, so the client's request for the hostname also succeeds.
+	Answers []net.IP
+
+	Log *ResolverLog
+}
+
+// ListenAndServe serves the NameServer on the UDP address, e.g. ":53".
+func (n *NameServer) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("listening for DNS on %q: %w", addr, err)
+	}
+	defer conn.Close()
+	return n.Serve(conn)
+}
+
+// Serve answers the queries received on the conn, until it's closed.
+func (n *NameServer) Serve(conn net.PacketConn) error {
+	buf := make([]byte, 512)
+	for {
+		size, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		reply, err := n.answer(buf[:size], from)
+		if err != nil {
+			continue // Malformed queries are dropped
+		}
+		conn.WriteTo(reply, from)
+	}
+}
+
+// answer returns the reply to the query, recording its token's resolver.
+func (n *NameServer) answer(query []byte, from net.Addr) ([]byte, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	if header.Response {
+		return nil, errors.New("not a query")
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+
+	token, inZone := n.token(q.Name.String())
+
+	reply := dnsmessage.Header{
+		ID:               header.ID,
+		Response:         true,
+		OpCode:           header.OpCode,
+		Authoritative:    inZone,
+		RecursionDesired: header.RecursionDesired,
+	}
+	if !inZone {
+		reply.RCode = dnsmessage.RCodeRefused
+	}
+
+	b := dnsmessage.NewBuilder(nil, reply)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if !inZone {
+		return b.Finish()
+	}
+
+	if token != "" {
+		n.Log.Record(token, &ResolverReport{
+			Addr:         addrHost(from),
+			ClientSubnet: clientSubnet(&p),
+		})
+	}
+
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET} // TTL 0, so never cached
+	for _, ip := range n.Answers {
+		switch ip4 := ip.To4(); {
+		case q.Type == dnsmessage.TypeA && ip4 != nil:
+			r := dnsmessage.AResource{}
+			copy(r.A[:], ip4)
+			err = b.AResource(rh, r)
+		case q.Type == dnsmessage.TypeAAAA && ip4 == nil && len(ip) == net.IPv6len:
+			r := dnsmessage.AAAAResource{}
+			copy(r.AAAA[:], ip)
+			err = b.AAAAResource(rh, r)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// token returns the token of a hostname in the zone, that is its first label, or "" for the zone
+// itself. Returns false if the hostname isn't in the zone.
+func (n *NameServer) token(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zone := strings.ToLower(strings.TrimSuffix(n.Zone, "."))
+	if name == zone {
+		return "", true
+	}
+	if !strings.HasSuffix(name, "."+zone) {
+		return "", false
+	}
+
+	name = strings.TrimSuffix(name, "."+zone)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:] // The label closest to the zone, e.g. "<token>" of "www.<token>.<zone>"
+	}
+	return name, true
+}
+
+// clientSubnet returns the EDNS Client Subnet of the query, or "" if there is none. The parser must
+// be positioned after the questions.
+func clientSubnet(p *dnsmessage.Parser) string {
+	if err := p.SkipAllAnswers(); err != nil {
+		return ""
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return ""
+	}
+
+	for {
+		h, err := p.AdditionalHeader()
+		if err != nil {
+			return ""
+		}
+		if h.Type != dnsmessage.TypeOPT {
+			if err := p.SkipAdditional(); err != nil {
+				return ""
+			}
+			continue
+		}
+
+		opt, err := p.OPTResource()
+		if err != nil {
+			return ""
+		}
+		for _, o := range opt.Options {
+			if o.Code == edns0ClientSubnet {
+				return parseClientSubnet(o.Data)
+			}
+		}
+		return ""
+	}
+}
+
+// parseClientSubnet parses the data of a EDNS Client Subnet option, that is the family, source
+// prefix length, scope prefix length, and the significant bytes of the address.
+func parseClientSubnet(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	family, prefix := int(data[0])<<8|int(data[1]), int(data[2])
+
+	var ip net.IP
+	switch family {
+	case 1:
+		ip = make(net.IP, net.IPv4len)
+	case 2:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return ""
+	}
+	if prefix > 8*len(ip) || len(data)-4 > len(ip) {
+		return ""
+	}
+	copy(ip, data[4:])
+
+	mask := net.CIDRMask(prefix, 8*len(ip))
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+// addrHost returns the host of the address, without any port.
+func addrHost(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
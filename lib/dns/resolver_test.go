@@ -0,0 +1,141 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newQuery returns a query for the name, with the EDNS Client Subnet option if ecs is set.
+func newQuery(t *testing.T, name string, qtype dnsmessage.Type, ecs []byte) []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1234, RecursionDesired: true})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET})
+
+	if ecs != nil {
+		b.StartAdditionals()
+		var rh dnsmessage.ResourceHeader
+		rh.SetEDNS0(1232, dnsmessage.RCodeSuccess, false)
+		b.OPTResource(rh, dnsmessage.OPTResource{
+			Options: []dnsmessage.Option{{Code: edns0ClientSubnet, Data: ecs}},
+		})
+	}
+
+	query, err := b.Finish()
+	if err != nil {
+		t.Fatalf("Building query for %q: %s", name, err)
+	}
+	return query
+}
+
+func TestNameServer(t *testing.T) {
+	data := []struct {
+		name       string
+		qtype      dnsmessage.Type
+		ecs        []byte
+		token      string
+		wantRCode  dnsmessage.RCode
+		wantAnswer []string
+		wantReport *ResolverReport
+	}{
+		{
+			name:       "abc123.resolver.example.com.",
+			qtype:      dnsmessage.TypeA,
+			token:      "abc123",
+			wantRCode:  dnsmessage.RCodeSuccess,
+			wantAnswer: []string{"192.0.2.80"},
+			wantReport: &ResolverReport{Addr: "198.51.100.53"},
+		}, {
+			name:       "ABC123.Resolver.Example.com.",
+			qtype:      dnsmessage.TypeAAAA,
+			ecs:        []byte{0, 1, 24, 0, 203, 0, 113},
+			token:      "abc123",
+			wantRCode:  dnsmessage.RCodeSuccess,
+			wantAnswer: []string{"2001:db8::80"},
+			wantReport: &ResolverReport{Addr: "198.51.100.53", ClientSubnet: "203.0.113.0/24"},
+		}, {
+			name:      "abc123.other.example.com.",
+			qtype:     dnsmessage.TypeA,
+			token:     "abc123",
+			wantRCode: dnsmessage.RCodeRefused,
+		},
+	}
+
+	for _, test := range data {
+		n := &NameServer{
+			Zone:    "resolver.example.com",
+			Answers: []net.IP{net.ParseIP("192.0.2.80"), net.ParseIP("2001:db8::80")},
+			Log:     NewResolverLog(),
+		}
+		from := &net.UDPAddr{IP: net.ParseIP("198.51.100.53"), Port: 5353}
+
+		reply, err := n.answer(newQuery(t, test.name, test.qtype, test.ecs), from)
+		if err != nil {
+			t.Errorf("answer(%q) err = %s, want nil", test.name, err)
+			continue
+		}
+
+		var msg dnsmessage.Message
+		if err := msg.Unpack(reply); err != nil {
+			t.Errorf("answer(%q) = invalid reply: %s", test.name, err)
+			continue
+		}
+		if msg.Header.ID != 1234 || msg.Header.RCode != test.wantRCode {
+			t.Errorf("answer(%q) header = %+v, want ID 1234, RCode %s", test.name, msg.Header, test.wantRCode)
+		}
+
+		var answers []string
+		for _, a := range msg.Answers {
+			switch r := a.Body.(type) {
+			case *dnsmessage.AResource:
+				answers = append(answers, net.IP(r.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				answers = append(answers, net.IP(r.AAAA[:]).String())
+			}
+		}
+		if diff := pretty.Compare(answers, test.wantAnswer); diff != "" {
+			t.Errorf("answer(%q) answers diff: (-got +want)\n%s", test.name, diff)
+		}
+
+		if diff := pretty.Compare(n.Log.Report(test.token), test.wantReport); diff != "" {
+			t.Errorf("answer(%q) Report(%q) diff: (-got +want)\n%s", test.name, test.token, diff)
+		}
+	}
+}
+
+func TestParseClientSubnet(t *testing.T) {
+	data := []struct {
+		data []byte
+		want string
+	}{
+		{[]byte{0, 1, 24, 0, 203, 0, 113}, "203.0.113.0/24"},
+		{[]byte{0, 1, 20, 0, 203, 0, 127}, "203.0.112.0/20"}, // Bits beyond the prefix are ignored
+		{[]byte{0, 2, 48, 0, 0x20, 0x01, 0x0d, 0xb8, 0, 1}, "2001:db8:1::/48"},
+		{[]byte{0, 1, 33, 0, 203, 0, 113, 1}, ""},    // Prefix too long
+		{[]byte{0, 3, 24, 0, 203, 0, 113}, ""},       // Unknown family
+		{[]byte{0, 1, 24, 0, 203, 0, 113, 1, 2}, ""}, // Address too long
+		{[]byte{0, 1}, ""},
+	}
+
+	for _, test := range data {
+		if got := parseClientSubnet(test.data); got != test.want {
+			t.Errorf("parseClientSubnet(%v) = %q, want %q", test.data, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ix finds the nearest major internet exchange (IX) to a location.
+package ix
+
+import (
+	"math"
+
+	"bramp.net/myip/lib/location"
+)
+
+// IX is a internet exchange point.
+type IX struct {
+	Name    string
+	City    string
+	Country string // ISO 3166-1 alpha-2 code
+
+	Lat, Long float64
+}
+
+// Nearest returns the nearest IX to the location, and its distance in kilometers.
+func Nearest(lat, long float64) (*IX, float64) {
+	var nearest *IX
+	best := math.Inf(1)
+
+	for i := range exchanges {
+		ix := &exchanges[i]
+		if d := location.DistanceKm(lat, long, ix.Lat, ix.Long); d < best {
+			nearest, best = ix, d
+		}
+	}
+
+	return nearest, best
+}
+
+// exchanges is a list of the major IXs, with the approximate location of their main site.
+var exchanges = []IX{
+	{Name: "Any2 Los Angeles", City: "Los Angeles", Country: "US", Lat: 34.0522, Long: -118.2437},
+	{Name: "AMS-IX", City: "Amsterdam", Country: "NL", Lat: 52.3676, Long: 4.9041},
+	{Name: "BCIX", City: "Berlin", Country: "DE", Lat: 52.5200, Long: 13.4050},
+	{Name: "CABASE", City: "Buenos Aires", Country: "AR", Lat: -34.6037, Long: -58.3816},
+	{Name: "DE-CIX Frankfurt", City: "Frankfurt", Country: "DE", Lat: 50.1109, Long: 8.6821},
+	{Name: "DE-CIX Mumbai", City: "Mumbai", Country: "IN", Lat: 19.0760, Long: 72.8777},
+	{Name: "DE-CIX New York", City: "New York", Country: "US", Lat: 40.7128, Long: -74.0060},
+	{Name: "Equinix Ashburn", City: "Ashburn", Country: "US", Lat: 39.0438, Long: -77.4874},
+	{Name: "Equinix Chicago", City: "Chicago", Country: "US", Lat: 41.8781, Long: -87.6298},
+	{Name: "Equinix Dallas", City: "Dallas", Country: "US", Lat: 32.7767, Long: -96.7970},
+	{Name: "Equinix San Jose", City: "San Jose", Country: "US", Lat: 37.3382, Long: -121.8863},
+	{Name: "Equinix Singapore", City: "Singapore", Country: "SG", Lat: 1.3521, Long: 103.8198},
+	{Name: "ESPANIX", City: "Madrid", Country: "ES", Lat: 40.4168, Long: -3.7038},
+	{Name: "France-IX", City: "Paris", Country: "FR", Lat: 48.8566, Long: 2.3522},
+	{Name: "HKIX", City: "Hong Kong", Country: "HK", Lat: 22.3193, Long: 114.1694},
+	{Name: "IX.br São Paulo", City: "São Paulo", Country: "BR", Lat: -23.5505, Long: -46.6333},
+	{Name: "IX Australia", City: "Sydney", Country: "AU", Lat: -33.8688, Long: 151.2093},
+	{Name: "JPNAP", City: "Tokyo", Country: "JP", Lat: 35.6762, Long: 139.6503},
+	{Name: "KINX", City: "Seoul", Country: "KR", Lat: 37.5665, Long: 126.9780},
+	{Name: "LINX", City: "London", Country: "GB", Lat: 51.5074, Long: -0.1278},
+	{Name: "MIX", City: "Milan", Country: "IT", Lat: 45.4642, Long: 9.1900},
+	{Name: "MSK-IX", City: "Moscow", Country: "RU", Lat: 55.7558, Long: 37.6173},
+	{Name: "NAP of the Americas", City: "Miami", Country: "US", Lat: 25.7617, Long: -80.1918},
+	{Name: "NAPAfrica", City: "Johannesburg", Country: "ZA", Lat: -26.2041, Long: 28.0473},
+	{Name: "Netnod", City: "Stockholm", Country: "SE", Lat: 59.3293, Long: 18.0686},
+	{Name: "NIX.CZ", City: "Prague", Country: "CZ", Lat: 50.0755, Long: 14.4378},
+	{Name: "PIT Chile", City: "Santiago", Country: "CL", Lat: -33.4489, Long: -70.6693},
+	{Name: "SIX", City: "Seattle", Country: "US", Lat: 47.6062, Long: -122.3321},
+	{Name: "TorIX", City: "Toronto", Country: "CA", Lat: 43.6532, Long: -79.3832},
+	{Name: "UAE-IX", City: "Dubai", Country: "AE", Lat: 25.2048, Long: 55.2708},
+	{Name: "VIX", City: "Vienna", Country: "AT", Lat: 48.2082, Long: 16.3738},
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ix
+
+import "testing"
+
+func TestNearest(t *testing.T) {
+	data := []struct {
+		lat, long float64
+		want      string
+	}{
+		{lat: 51.75, long: -1.25, want: "LINX"},                   // Oxford
+		{lat: 37.7749, long: -122.4194, want: "Equinix San Jose"}, // San Francisco
+		{lat: -37.8136, long: 144.9631, want: "IX Australia"},     // Melbourne
+	}
+
+	for _, test := range data {
+		got, km := Nearest(test.lat, test.long)
+		if got == nil || got.Name != test.want {
+			t.Errorf("Nearest(%v, %v) = %+v, want %q", test.lat, test.long, got, test.want)
+		}
+		if km <= 0 || km > 1000 {
+			t.Errorf("Nearest(%v, %v) distance = %vkm, want (0, 1000]", test.lat, test.long, km)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+import "strings"
+
+// defaultCentroidToleranceKm is how close a location must be to a country's centroid to be
+// considered the centroid, when not configured.
+const defaultCentroidToleranceKm = 1.0
+
+type latLong struct {
+	lat, long float64
+}
+
+// IsCentroid returns true if the lat/long is within toleranceKm of the centroid of the country,
+// or if the country is unknown, any country. Geo databases often return the centroid when they
+// only know the country, so such a location should not be treated as precise.
+func IsCentroid(country string, lat, long, toleranceKm float64) bool {
+	if lat == 0 && long == 0 {
+		return false // Unknown, rather than a centroid
+	}
+
+	near := func(c latLong) bool {
+		return DistanceKm(lat, long, c.lat, c.long) <= toleranceKm
+	}
+
+	if country != "" {
+		c, found := centroids[strings.ToUpper(country)]
+		return found && near(c)
+	}
+
+	for _, c := range centroids {
+		if near(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// centroids are the geographic centers of countries, keyed by ISO 3166-1 alpha-2 code. The
+// values are from the Google DSPL canonical countries dataset.
+var centroids = map[string]latLong{
+	"AD": {42.546245, 1.601554}, "AE": {23.424076, 53.847818}, "AF": {33.93911, 67.709953},
+	"AG": {17.060816, -61.796428}, "AL": {41.153332, 20.168331}, "AM": {40.069099, 45.038189},
+	"AO": {-11.202692, 17.873887}, "AR": {-38.416097, -63.616672}, "AT": {47.516231, 14.550072},
+	"AU": {-25.274398, 133.775136}, "AZ": {40.143105, 47.576927}, "BA": {43.915886, 17.679076},
+	"BD": {23.684994, 90.356331}, "BE": {50.503887, 4.469936}, "BG": {42.733883, 25.48583},
+	"BH": {25.930414, 50.637772}, "BO": {-16.290154, -63.588653}, "BR": {-14.235004, -51.92528},
+	"BY": {53.709807, 27.953389}, "CA": {56.130366, -106.346771}, "CH": {46.818188, 8.227512},
+	"CL": {-35.675147, -71.542969}, "CN": {35.86166, 104.195397}, "CO": {4.570868, -74.297333},
+	"CR": {9.748917, -83.753428}, "CU": {21.521757, -77.781167}, "CY": {35.126413, 33.429859},
+	"CZ": {49.817492, 15.472962}, "DE": {51.165691, 10.451526}, "DK": {56.26392, 9.501785},
+	"DO": {18.735693, -70.162651}, "DZ": {28.033886, 1.659626}, "EC": {-1.831239, -78.183406},
+	"EE": {58.595272, 25.013607}, "EG": {26.820553, 30.802498}, "ES": {40.463667, -3.74922},
+	"ET": {9.145, 40.489673}, "FI": {61.92411, 25.748151}, "FR": {46.227638, 2.213749},
+	"GB": {55.378051, -3.435973}, "GE": {42.315407, 43.356892}, "GH": {7.946527, -1.023194},
+	"GR": {39.074208, 21.824312}, "HK": {22.396428, 114.109497}, "HR": {45.1, 15.2},
+	"HU": {47.162494, 19.503304}, "ID": {-0.789275, 113.921327}, "IE": {53.41291, -8.24389},
+	"IL": {31.046051, 34.851612}, "IN": {20.593684, 78.96288}, "IQ": {33.223191, 43.679291},
+	"IR": {32.427908, 53.688046}, "IS": {64.963051, -19.020835}, "IT": {41.87194, 12.56738},
+	"JM": {18.109581, -77.297508}, "JO": {30.585164, 36.238414}, "JP": {36.204824, 138.252924},
+	"KE": {-0.023559, 37.906193}, "KR": {35.907757, 127.766922}, "KW": {29.31166, 47.481766},
+	"KZ": {48.019573, 66.923684}, "LB": {33.854721, 35.862285}, "LK": {7.873054, 80.771797},
+	"LT": {55.169438, 23.881275}, "LU": {49.815273, 6.129583}, "LV": {56.879635, 24.603189},
+	"MA": {31.791702, -7.09262}, "MX": {23.634501, -102.552784}, "MY": {4.210484, 101.975766},
+	"NG": {9.081999, 8.675277}, "NL": {52.132633, 5.291266}, "NO": {60.472024, 8.468946},
+	"NP": {28.394857, 84.124008}, "NZ": {-40.900557, 174.885971}, "PE": {-9.189967, -75.015152},
+	"PH": {12.879721, 121.774017}, "PK": {30.375321, 69.345116}, "PL": {51.919438, 19.145136},
+	"PT": {39.399872, -8.224454}, "QA": {25.354826, 51.183884}, "RO": {45.943161, 24.96676},
+	"RS": {44.016521, 21.005859}, "RU": {61.52401, 105.318756}, "SA": {23.885942, 45.079162},
+	"SE": {60.128161, 18.643501}, "SG": {1.352083, 103.819836}, "SI": {46.151241, 14.995463},
+	"SK": {48.669026, 19.699024}, "TH": {15.870032, 100.992541}, "TR": {38.963745, 35.243322},
+	"TW": {23.69781, 120.960515}, "UA": {48.379433, 31.16558}, "US": {37.09024, -95.712891},
+	"UY": {-32.522779, -55.765835}, "VE": {6.42375, -66.58973}, "VN": {14.058324, 108.277199},
+	"ZA": {-30.559482, 22.937506},
+}
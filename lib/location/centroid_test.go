@@ -0,0 +1,52 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	// London to Paris is roughly 344km.
+	if got := DistanceKm(51.5074, -0.1278, 48.8566, 2.3522); math.Abs(got-344) > 1 {
+		t.Errorf("DistanceKm(London, Paris) = %v, want ~344", got)
+	}
+	if got := DistanceKm(10, 10, 10, 10); got != 0 {
+		t.Errorf("DistanceKm(same point) = %v, want 0", got)
+	}
+}
+
+func TestIsCentroid(t *testing.T) {
+	data := []struct {
+		country   string
+		lat, long float64
+		want      bool
+	}{
+		{country: "US", lat: 37.09024, long: -95.712891, want: true},
+		{country: "us", lat: 37.0903, long: -95.7129, want: true},
+		{country: "", lat: 37.09024, long: -95.712891, want: true},
+		{country: "US", lat: 40.7128, long: -74.0060, want: false}, // New York
+		{country: "FR", lat: 37.09024, long: -95.712891, want: false},
+		{country: "US", lat: 0, long: 0, want: false},
+		{country: "ZZ", lat: 1, long: 1, want: false},
+	}
+
+	for _, test := range data {
+		if got := IsCentroid(test.country, test.lat, test.long, defaultCentroidToleranceKm); got != test.want {
+			t.Errorf("IsCentroid(%q, %v, %v) = %v, want %v", test.country, test.lat, test.long, got, test.want)
+		}
+	}
+}
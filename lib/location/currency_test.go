@@ -0,0 +1,37 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+import "testing"
+
+func TestCurrencyForCountry(t *testing.T) {
+	data := []struct {
+		country string
+		want    string
+	}{
+		{"US", "USD"},
+		{"gb", "GBP"},
+		{"FR", "EUR"},
+		{"JP", "JPY"},
+		{"", ""},
+		{"ZZ", ""},
+	}
+
+	for _, test := range data {
+		if got := CurrencyForCountry(test.country); got != test.want {
+			t.Errorf("CurrencyForCountry(%q) = %q, want %q", test.country, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+// regionalIndicatorA is the Unicode regional indicator symbol letter A. A pair of regional
+// indicators, one for each letter of a country's code, is displayed as the country's flag.
+const regionalIndicatorA = 0x1F1E6
+
+// FlagForCountry returns the flag emoji for the ISO 3166-1 alpha-2 country, e.g. "🇺🇸" for "US",
+// or "" if the code isn't two letters.
+func FlagForCountry(country string) string {
+	if len(country) != 2 {
+		return ""
+	}
+
+	flag := make([]rune, 0, len(country))
+	for _, c := range country {
+		switch {
+		case 'A' <= c && c <= 'Z':
+			flag = append(flag, regionalIndicatorA+c-'A')
+		case 'a' <= c && c <= 'z':
+			flag = append(flag, regionalIndicatorA+c-'a')
+		default:
+			return ""
+		}
+	}
+	return string(flag)
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package location
+
+import (
+	"context"
+	"net"
+
+	"bramp.net/myip/lib/conf"
+)
+
+// HeadersProvider locates the client from the headers added by a proxy, such as
+// "X-Appengine-Country" on App Engine, or "Cf-Ipcountry" on CloudFlare. The headers are
+// configured with conf.Config.LatLongHeader, CityHeader, etc, and read from the context (see
+// WithHeader), so only the client's own address can be located.
+type HeadersProvider struct {
+	Config *conf.Config
+}
+
+// Lookup returns the location from the headers. The ip is ignored.
+func (p *HeadersProvider) Lookup(ctx context.Context, ip net.IP) (*Response, error) {
+	header := headerFrom(ctx)
+
+	lat, long, _ := parseLatLong(header.Get(p.Config.LatLongHeader))
+	response := &Response{
+		City:    header.Get(p.Config.CityHeader),
+		Region:  header.Get(p.Config.RegionHeader),
+		Country: header.Get(p.Config.CountryHeader),
+		Lat:     lat,
+		Long:    long,
+	}
+
+	// The headers only contain codes for the country and region, and the name of the city.
+	response.Granularities = &Granularities{
+		Country: newGranularity("", response.Country),
+		Region:  newGranularity("", response.Region),
+		City:    newGranularity(response.City, ""),
+	}
+
+	return response, nil
+}
+
+// Metadata returns the HeadersProvider's Metadata.
+func (p *HeadersProvider) Metadata() *Metadata {
+	return &Metadata{Provider: "headers"}
+}
@@ -15,7 +15,10 @@
 package location
 
 import (
+	"context"
 	"errors"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -30,6 +33,68 @@ type Response struct {
 	Country string `json:",omitempty"`
 
 	Lat, Long float64 `json:",omitempty"`
+
+	// AccuracyRadius is how precise Lat/Long is, as the radius (in Units) around it the address
+	// is likely to be. Omitted if the provider doesn't report it.
+	AccuracyRadius int `json:",omitempty"`
+
+	// AccuracyRadiusKm is the AccuracyRadius as reported by the provider, which Handle converts
+	// to Units.
+	AccuracyRadiusKm int `json:"-" xml:"-" yaml:"-"`
+
+	// CentroidFallback is set when Lat/Long is the centroid of the country, meaning the location
+	// is only known to the country level, and is not precise.
+	CentroidFallback bool `json:",omitempty"`
+
+	// Currency is the ISO 4217 code of the currency used in Country, e.g. "USD".
+	Currency string `json:",omitempty"`
+
+	// CallingCode is the international calling code of Country, e.g. "+44".
+	CallingCode string `json:",omitempty"`
+
+	// CountryFlag is the flag emoji of Country, e.g. "🇺🇸".
+	CountryFlag string `json:",omitempty"`
+
+	// Timezone is the IANA timezone, e.g. "America/New_York". From the provider if it knows it,
+	// otherwise estimated from Lat/Long.
+	Timezone string `json:",omitempty"`
+
+	// CountryChanged is set if this client was previously seen in a different country, see
+	// conf.Config.TrackCountryChanges.
+	CountryChanged  bool   `json:",omitempty"`
+	PreviousCountry string `json:",omitempty"`
+
+	// Units any distances in this response are in. Chosen by the "units" query parameter, or
+	// defaults to those used in Country.
+	Units Units `json:",omitempty"`
+
+	// Granularities has the location at each granularity, with the provider's confidence in each.
+	// Only included if conf.Config.IncludeGranularities is set.
+	Granularities *Granularities `json:",omitempty"`
+}
+
+// Granularities is the location at multiple granularities.
+type Granularities struct {
+	Country *Granularity `json:",omitempty"`
+	Region  *Granularity `json:",omitempty"`
+	City    *Granularity `json:",omitempty"`
+}
+
+// Granularity is the location at a single granularity, such as the country.
+type Granularity struct {
+	Name string `json:",omitempty"`
+	Code string `json:",omitempty"` // e.g. the ISO 3166 code for a country or region
+
+	// Confidence is the provider's confidence (0-100) that this is correct, omitted if unknown.
+	Confidence int `json:",omitempty"`
+}
+
+// newGranularity returns a Granularity, or nil if there is nothing known about it.
+func newGranularity(name, code string) *Granularity {
+	if name == "" && code == "" {
+		return nil
+	}
+	return &Granularity{Name: name, Code: code}
 }
 
 func parseLatLong(latlong string) (float64, float64, error) {
@@ -49,15 +114,31 @@ func parseLatLong(latlong string) (float64, float64, error) {
 	return lat, long, nil
 }
 
-// Handle generates a location.Response
-func Handle(config *conf.Config, req *http.Request) *Response {
-	lat, long, _ := parseLatLong(req.Header.Get(config.LatLongHeader))
-	response := &Response{
-		City:    req.Header.Get(config.CityHeader),
-		Region:  req.Header.Get(config.RegionHeader),
-		Country: req.Header.Get(config.CountryHeader),
-		Lat:     lat,
-		Long:    long,
+// Handle generates a location.Response for the address using the provider, adding the details
+// derived from the location, such as the currency. Returns nil if the lookup failed.
+func Handle(ctx context.Context, provider Provider, config *conf.Config, req *http.Request, ip net.IP) *Response {
+	response, err := provider.Lookup(WithHeader(ctx, req.Header), ip)
+	if err != nil || response == nil {
+		return nil
+	}
+
+	tolerance := config.CentroidToleranceKm
+	if tolerance == 0 {
+		tolerance = defaultCentroidToleranceKm
+	}
+	response.CentroidFallback = IsCentroid(response.Country, response.Lat, response.Long, tolerance)
+
+	response.Currency = CurrencyForCountry(response.Country)
+	response.CallingCode = CallingCodeForCountry(response.Country)
+	response.CountryFlag = FlagForCountry(response.Country)
+	if response.Timezone == "" {
+		response.Timezone = TimezoneForLocation(response.Country, response.Lat, response.Long)
+	}
+	response.Units = ChooseUnits(req.URL.Query().Get("units"), response.Country)
+	response.AccuracyRadius = int(math.Round(response.Units.FromKm(float64(response.AccuracyRadiusKm))))
+
+	if !config.IncludeGranularities {
+		response.Granularities = nil
 	}
 
 	return response
@@ -15,7 +15,12 @@
 package location
 
 import (
+	"context"
+	"net/http/httptest"
 	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
 )
 
 func TestParseLatLong(t *testing.T) {
@@ -37,3 +42,25 @@ func TestParseLatLong(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleGranularities(t *testing.T) {
+	config := &conf.Config{
+		CountryHeader:        "X-Country",
+		RegionHeader:         "X-Region",
+		CityHeader:           "X-City",
+		IncludeGranularities: true,
+	}
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("X-Country", "US")
+	req.Header.Set("X-City", "San Francisco")
+
+	got := Handle(context.Background(), &HeadersProvider{config}, config, req, nil).Granularities
+	want := &Granularities{
+		Country: &Granularity{Code: "US"},
+		City:    &Granularity{Name: "San Francisco"},
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("Handle(...).Granularities diff: (-got +want)\n%s", diff)
+	}
+}
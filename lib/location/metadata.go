@@ -0,0 +1,39 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+import (
+	"time"
+)
+
+// Metadata describes the source of the location data, so its freshness can be verified.
+type Metadata struct {
+	// Provider is the type of provider, e.g. "headers" when the location comes from headers added
+	// by a proxy (such as App Engine or CloudFlare).
+	Provider string
+
+	// The following are only known for database backed providers.
+	DatabaseType string     `json:",omitempty"`
+	BuildTime    *time.Time `json:",omitempty"`
+	Nodes        uint       `json:",omitempty"` // The number of nodes in the search tree, not records
+}
+
+// GetMetadata returns the Metadata for the location provider.
+func GetMetadata(provider Provider) *Metadata {
+	if p, ok := provider.(interface{ Metadata() *Metadata }); ok {
+		return p.Metadata()
+	}
+	return &Metadata{Provider: "unknown"}
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package location
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"bramp.net/myip/lib/refresh"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MMDBProvider locates addresses using a MaxMind DB file, such as GeoIP2 or GeoLite2 City.
+type MMDBProvider struct {
+	reader func() *maxminddb.Reader // The current database, or nil if it has never loaded
+}
+
+// mmdbRecord is the subset of a GeoIP2 City record we use.
+type mmdbRecord struct {
+	City struct {
+		Confidence int               `maxminddb:"confidence"` // Only in the Enterprise database
+		Names      map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+
+	Country struct {
+		Confidence int               `maxminddb:"confidence"`
+		IsoCode    string            `maxminddb:"iso_code"`
+		Names      map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+
+	Subdivisions []struct {
+		Confidence int               `maxminddb:"confidence"`
+		IsoCode    string            `maxminddb:"iso_code"`
+		Names      map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+
+	Location struct {
+		AccuracyRadius int     `maxminddb:"accuracy_radius"` // In km
+		Latitude       float64 `maxminddb:"latitude"`
+		Longitude      float64 `maxminddb:"longitude"`
+		TimeZone       string  `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+// NewMMDBProvider returns a MMDBProvider reading the database at path. The database is never
+// reloaded, see NewRefreshedMMDBProvider.
+func NewMMDBProvider(path string) (*MMDBProvider, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening location database %q: %w", path, err)
+	}
+	return &MMDBProvider{
+		reader: func() *maxminddb.Reader { return reader },
+	}, nil
+}
+
+// NewRefreshedMMDBProvider returns a MMDBProvider reading the database at path, which is reloaded
+// by the refresher, so a replaced database is picked up without a restart. If the initial load
+// fails, the error is returned along with the provider, which fails its lookups until a refresh
+// succeeds.
+func NewRefreshedMMDBProvider(refresher *refresh.Scheduler, path string) (*MMDBProvider, error) {
+	v, err := refresher.Add(&MMDBSource{Path: path})
+	return &MMDBProvider{
+		reader: func() *maxminddb.Reader {
+			reader, _ := v.Load().(*maxminddb.Reader)
+			return reader
+		},
+	}, err
+}
+
+// MMDBSource is a refresh.Source of the MaxMind DB file at Path.
+type MMDBSource struct {
+	Path string
+}
+
+// Name returns the name of this source.
+func (s *MMDBSource) Name() string {
+	return "location database " + s.Path
+}
+
+// Load returns a *maxminddb.Reader of the database. Unlike maxminddb.Open, the database is read
+// into memory (instead of memory mapped), so a replaced Reader never needs closing, which would
+// break any in-flight lookups. It's just garbage collected.
+func (s *MMDBSource) Load() (interface{}, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading location database %q: %w", s.Path, err)
+	}
+	reader, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("opening location database %q: %w", s.Path, err)
+	}
+	return reader, nil
+}
+
+// errNotLoaded is returned by the lookups of a MMDBProvider whose database has never loaded.
+var errNotLoaded = errors.New("location database not loaded")
+
+// Lookup returns the location of the address from the database.
+func (p *MMDBProvider) Lookup(ctx context.Context, ip net.IP) (*Response, error) {
+	reader := p.reader()
+	if reader == nil {
+		return nil, errNotLoaded
+	}
+
+	var record mmdbRecord
+	if err := reader.Lookup(ip, &record); err != nil {
+		return nil, err
+	}
+
+	response := &Response{
+		City:     record.City.Names["en"],
+		Country:  record.Country.IsoCode,
+		Lat:      record.Location.Latitude,
+		Long:     record.Location.Longitude,
+		Timezone: record.Location.TimeZone,
+
+		AccuracyRadiusKm: record.Location.AccuracyRadius,
+	}
+
+	granularities := &Granularities{
+		Country: newGranularity(record.Country.Names["en"], record.Country.IsoCode),
+		City:    newGranularity(record.City.Names["en"], ""),
+	}
+	if granularities.Country != nil {
+		granularities.Country.Confidence = record.Country.Confidence
+	}
+	if granularities.City != nil {
+		granularities.City.Confidence = record.City.Confidence
+	}
+
+	// The first subdivision is the largest, e.g. the state
+	if len(record.Subdivisions) > 0 {
+		region := record.Subdivisions[0]
+		response.Region = region.IsoCode
+		if granularities.Region = newGranularity(region.Names["en"], region.IsoCode); granularities.Region != nil {
+			granularities.Region.Confidence = region.Confidence
+		}
+	}
+	response.Granularities = granularities
+
+	return response, nil
+}
+
+// Metadata returns the database's Metadata.
+func (p *MMDBProvider) Metadata() *Metadata {
+	reader := p.reader()
+	if reader == nil {
+		return &Metadata{Provider: "mmdb"}
+	}
+
+	m := reader.Metadata
+	built := time.Unix(int64(m.BuildEpoch), 0).UTC()
+	return &Metadata{
+		Provider:     "mmdb",
+		DatabaseType: m.DatabaseType,
+		BuildTime:    &built,
+		Nodes:        m.NodeCount,
+	}
+}
+
+// Close closes the current database.
+func (p *MMDBProvider) Close() error {
+	reader := p.reader()
+	if reader == nil {
+		return nil
+	}
+	return reader.Close()
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package location
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/location/mmdbtest"
+	"bramp.net/myip/lib/refresh"
+)
+
+func TestRefreshedMMDBProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmdb")
+	if err != nil {
+		t.Fatalf("TempDir() err = %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.mmdb")
+	if err := mmdbtest.Write(path, "GB", time.Now()); err != nil {
+		t.Fatalf("mmdbtest.Write(%q) err = %s", path, err)
+	}
+
+	refresher := refresh.NewScheduler(0)
+	p, err := NewRefreshedMMDBProvider(refresher, path)
+	if err != nil {
+		t.Fatalf("NewRefreshedMMDBProvider(%q) err = %s", path, err)
+	}
+
+	lookup := func() string {
+		got, err := p.Lookup(context.Background(), net.ParseIP("8.8.8.8"))
+		if err != nil {
+			t.Fatalf("Lookup(8.8.8.8) err = %s", err)
+		}
+		return got.Country
+	}
+
+	if got, want := lookup(), "GB"; got != want {
+		t.Errorf("Lookup(8.8.8.8).Country = %q, want %q", got, want)
+	}
+
+	// Replace the database, which is only picked up after a refresh.
+	if err := mmdbtest.Write(path, "FR", time.Now()); err != nil {
+		t.Fatalf("mmdbtest.Write(%q) err = %s", path, err)
+	}
+	if got, want := lookup(), "GB"; got != want {
+		t.Errorf("Lookup(8.8.8.8).Country = %q before refresh, want %q", got, want)
+	}
+
+	refresher.Refresh()
+	if got, want := lookup(), "FR"; got != want {
+		t.Errorf("Lookup(8.8.8.8).Country = %q after refresh, want %q", got, want)
+	}
+}
+
+func TestRefreshedMMDBProviderMissing(t *testing.T) {
+	refresher := refresh.NewScheduler(0)
+	p, err := NewRefreshedMMDBProvider(refresher, "testdata/missing.mmdb")
+	if err == nil {
+		t.Errorf("NewRefreshedMMDBProvider(missing) err = nil, want error")
+	}
+	if _, err := p.Lookup(context.Background(), net.ParseIP("8.8.8.8")); err != errNotLoaded {
+		t.Errorf("Lookup(8.8.8.8) err = %v, want %v", err, errNotLoaded)
+	}
+	if got, want := GetMetadata(p).Provider, "mmdb"; got != want {
+		t.Errorf("GetMetadata() provider = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mmdbtest writes tiny MaxMind DB files, for testing the code reading them.
+package mmdbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// The MaxMind DB data types, see https://maxmind.github.io/MaxMind-DB/
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+)
+
+// metadataMarker starts the metadata section, at the end of the file.
+const metadataMarker = "\xAB\xCD\xEFMaxMind.com"
+
+// Write writes a IPv4 GeoIP2 City style database to path, which locates every address in the
+// country (a ISO 3166-1 alpha-2 code).
+func Write(path, country string, built time.Time) error {
+	var b bytes.Buffer
+
+	// The search tree is a single node, whose records (of 24 bits) both point to the only
+	// data, at offset 0. Data pointers are offset by the node count, and the 16 byte separator.
+	const nodeCount = 1
+	record := []byte{0, 0, nodeCount + 16}
+	b.Write(record)
+	b.Write(record)
+	b.Write(make([]byte, 16))
+
+	writeMap(&b, map[string]interface{}{
+		"country": map[string]interface{}{
+			"iso_code": country,
+		},
+	})
+
+	b.WriteString(metadataMarker)
+	writeMap(&b, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(built.Unix()),
+		"database_type":               "Test-City",
+		"ip_version":                  uint16(4),
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})
+
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
+
+// writeControl writes the control byte(s) for a value of the type and size (which must be less
+// than 29).
+func writeControl(b *bytes.Buffer, typ, size int) {
+	if typ <= typeMap {
+		b.WriteByte(byte(typ<<5 | size))
+		return
+	}
+	b.WriteByte(byte(size))
+	b.WriteByte(byte(typ - typeMap)) // Extended type
+}
+
+// writeUint writes the unsigned integer, using only as many bytes as needed.
+func writeUint(b *bytes.Buffer, typ int, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	data := bytes.TrimLeft(buf[:], "\x00")
+	writeControl(b, typ, len(data))
+	b.Write(data)
+}
+
+func writeValue(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		writeControl(b, typeString, len(v))
+		b.WriteString(v)
+	case uint16:
+		writeUint(b, typeUint16, uint64(v))
+	case uint32:
+		writeUint(b, typeUint32, uint64(v))
+	case uint64:
+		writeUint(b, typeUint64, v)
+	case map[string]interface{}:
+		writeMap(b, v)
+	default:
+		panic("mmdbtest: unsupported type")
+	}
+}
+
+func writeMap(b *bytes.Buffer, m map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writeControl(b, typeMap, len(keys))
+	for _, key := range keys {
+		writeValue(b, key)
+		writeValue(b, m[key])
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package location
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/refresh"
+)
+
+// Provider looks up the location of a IP address.
+type Provider interface {
+	// Lookup returns the location of the address. The Response may be empty if the location is
+	// unknown.
+	Lookup(ctx context.Context, ip net.IP) (*Response, error)
+}
+
+// ProviderFunc adapts a function to a Provider.
+type ProviderFunc func(ctx context.Context, ip net.IP) (*Response, error)
+
+// Lookup calls f(ctx, ip).
+func (f ProviderFunc) Lookup(ctx context.Context, ip net.IP) (*Response, error) {
+	return f(ctx, ip)
+}
+
+// NewProvider returns the Provider selected by conf.Config.LocationProvider. Any database is
+// reloaded by the refresher, if not nil. A database backed Provider may be returned along with a
+// error, if its initial load failed, as the load is retried by the refresher.
+func NewProvider(config *conf.Config, refresher *refresh.Scheduler) (Provider, error) {
+	switch config.LocationProvider {
+	case "", "headers":
+		return &HeadersProvider{config}, nil
+	case "mmdb":
+		if refresher != nil {
+			return NewRefreshedMMDBProvider(refresher, config.LocationDatabase)
+		}
+		p, err := NewMMDBProvider(config.LocationDatabase)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "none":
+		return NoopProvider{}, nil
+	}
+	return nil, fmt.Errorf("unknown location provider %q", config.LocationProvider)
+}
+
+// NoopProvider never knows the location.
+type NoopProvider struct{}
+
+// Lookup returns a empty Response.
+func (NoopProvider) Lookup(ctx context.Context, ip net.IP) (*Response, error) {
+	return &Response{}, nil
+}
+
+// Metadata returns the NoopProvider's Metadata.
+func (NoopProvider) Metadata() *Metadata {
+	return &Metadata{Provider: "none"}
+}
+
+type headerKey struct{}
+
+// WithHeader returns a context carrying the request's header, for the providers (such as
+// HeadersProvider) that locate the client from it.
+func WithHeader(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+// headerFrom returns the header carried by the context, or nil if there is none.
+func headerFrom(ctx context.Context) http.Header {
+	header, _ := ctx.Value(headerKey{}).(http.Header)
+	return header
+}
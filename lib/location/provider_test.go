@@ -0,0 +1,91 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package location
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestNewProvider(t *testing.T) {
+	data := []struct {
+		config       *conf.Config
+		wantProvider string
+		wantErr      bool
+	}{
+		{config: &conf.Config{}, wantProvider: "headers"},
+		{config: &conf.Config{LocationProvider: "headers"}, wantProvider: "headers"},
+		{config: &conf.Config{LocationProvider: "none"}, wantProvider: "none"},
+		{config: &conf.Config{LocationProvider: "mmdb", LocationDatabase: "testdata/missing.mmdb"}, wantErr: true},
+		{config: &conf.Config{LocationProvider: "other"}, wantErr: true},
+	}
+
+	for _, test := range data {
+		p, err := NewProvider(test.config, nil)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("NewProvider(%q) err = nil, want error", test.config.LocationProvider)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewProvider(%q) err = %s, want nil", test.config.LocationProvider, err)
+			continue
+		}
+		if got := GetMetadata(p).Provider; got != test.wantProvider {
+			t.Errorf("NewProvider(%q) provider = %q, want %q", test.config.LocationProvider, got, test.wantProvider)
+		}
+	}
+}
+
+func TestHandleProvider(t *testing.T) {
+	config := &conf.Config{}
+	req := httptest.NewRequest("GET", "/json", nil)
+
+	if got := Handle(context.Background(), NoopProvider{}, config, req, nil); got == nil || got.Country != "" {
+		t.Errorf("Handle(NoopProvider) = %+v, want a empty Response", got)
+	}
+
+	failing := ProviderFunc(func(ctx context.Context, ip net.IP) (*Response, error) {
+		return nil, errors.New("lookup failed")
+	})
+	if got := Handle(context.Background(), failing, config, req, nil); got != nil {
+		t.Errorf("Handle(failing) = %+v, want nil", got)
+	}
+
+	fake := ProviderFunc(func(ctx context.Context, ip net.IP) (*Response, error) {
+		return &Response{Country: "JP"}, nil
+	})
+	if got := Handle(context.Background(), fake, config, req, nil); got == nil || got.Currency != "JPY" {
+		t.Errorf("Handle(fake) = %+v, want currency JPY", got)
+	}
+
+	accurate := ProviderFunc(func(ctx context.Context, ip net.IP) (*Response, error) {
+		return &Response{Country: "JP", Lat: 35.69, Long: 139.69, AccuracyRadiusKm: 50}, nil
+	})
+	if got := Handle(context.Background(), accurate, config, req, nil); got == nil || got.AccuracyRadius != 50 {
+		t.Errorf("Handle(accurate) = %+v, want AccuracyRadius 50", got)
+	}
+
+	// The radius is converted to the units, like every distance.
+	imperial := httptest.NewRequest("GET", "/json?units=imperial", nil)
+	if got := Handle(context.Background(), accurate, config, imperial, nil); got == nil || got.AccuracyRadius != 31 || got.Units != Imperial {
+		t.Errorf("Handle(accurate, %q) = %+v, want AccuracyRadius 31 (miles)", imperial.URL, got)
+	}
+}
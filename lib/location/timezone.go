@@ -0,0 +1,206 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package location
+
+import (
+	"math"
+	"strings"
+)
+
+// maxTimezoneKm is how far a location may be from the nearest reference point, when the country is
+// unknown, for its timezone to be guessed.
+const maxTimezoneKm = 1000
+
+// zonePoint is a reference location within a timezone.
+type zonePoint struct {
+	zone      string // IANA timezone name
+	country   string // ISO 3166-1 alpha-2 code
+	lat, long float64
+}
+
+// TimezoneForLocation returns the IANA timezone (e.g. "America/New_York") of the location, or ""
+// if unknown. This is a approximation, picking the timezone of the nearest reference point
+// (preferring those in the same country), so may be wrong near timezone borders.
+func TimezoneForLocation(country string, lat, long float64) string {
+	country = strings.ToUpper(country)
+
+	var candidates []zonePoint
+	for _, p := range zonePoints {
+		if p.country == country {
+			candidates = append(candidates, p)
+		}
+	}
+
+	if lat == 0 && long == 0 {
+		// Without a location, we can only be sure for countries with a single timezone
+		if len(candidates) > 0 && singleZone(candidates) {
+			return candidates[0].zone
+		}
+		return ""
+	}
+
+	maxKm := math.Inf(1)
+	if len(candidates) == 0 {
+		candidates, maxKm = zonePoints, maxTimezoneKm
+	}
+
+	best, bestKm := "", maxKm
+	for _, p := range candidates {
+		if d := DistanceKm(lat, long, p.lat, p.long); d <= bestKm {
+			best, bestKm = p.zone, d
+		}
+	}
+	return best
+}
+
+// singleZone returns true if all the points are in the same timezone.
+func singleZone(points []zonePoint) bool {
+	for _, p := range points[1:] {
+		if p.zone != points[0].zone {
+			return false
+		}
+	}
+	return true
+}
+
+// zonePoints are the reference locations, typically the largest city of each timezone.
+var zonePoints = []zonePoint{
+	// North America
+	{"America/New_York", "US", 40.7128, -74.0060},
+	{"America/Detroit", "US", 42.3314, -83.0458},
+	{"America/Chicago", "US", 41.8781, -87.6298},
+	{"America/Chicago", "US", 29.7604, -95.3698},
+	{"America/Denver", "US", 39.7392, -104.9903},
+	{"America/Phoenix", "US", 33.4484, -112.0740},
+	{"America/Los_Angeles", "US", 34.0522, -118.2437},
+	{"America/Los_Angeles", "US", 37.7749, -122.4194},
+	{"America/Los_Angeles", "US", 47.6062, -122.3321},
+	{"America/Anchorage", "US", 61.2181, -149.9003},
+	{"Pacific/Honolulu", "US", 21.3069, -157.8583},
+	{"America/Toronto", "CA", 43.6532, -79.3832},
+	{"America/Toronto", "CA", 45.5017, -73.5673},
+	{"America/Halifax", "CA", 44.6488, -63.5752},
+	{"America/St_Johns", "CA", 47.5615, -52.7126},
+	{"America/Winnipeg", "CA", 49.8951, -97.1384},
+	{"America/Regina", "CA", 50.4452, -104.6189},
+	{"America/Edmonton", "CA", 51.0447, -114.0719},
+	{"America/Vancouver", "CA", 49.2827, -123.1207},
+	{"America/Mexico_City", "MX", 19.4326, -99.1332},
+	{"America/Tijuana", "MX", 32.5149, -117.0382},
+	{"America/Cancun", "MX", 21.1619, -86.8515},
+
+	// Central and South America
+	{"America/Guatemala", "GT", 14.6349, -90.5069},
+	{"America/Panama", "PA", 8.9824, -79.5199},
+	{"America/Havana", "CU", 23.1136, -82.3666},
+	{"America/Bogota", "CO", 4.7110, -74.0721},
+	{"America/Caracas", "VE", 10.4806, -66.9036},
+	{"America/Lima", "PE", -12.0464, -77.0428},
+	{"America/Guayaquil", "EC", -2.1710, -79.9224},
+	{"America/Santiago", "CL", -33.4489, -70.6693},
+	{"America/Argentina/Buenos_Aires", "AR", -34.6037, -58.3816},
+	{"America/Montevideo", "UY", -34.9011, -56.1645},
+	{"America/Asuncion", "PY", -25.2637, -57.5759},
+	{"America/La_Paz", "BO", -16.4897, -68.1193},
+	{"America/Sao_Paulo", "BR", -23.5505, -46.6333},
+	{"America/Sao_Paulo", "BR", -15.7975, -47.8919},
+	{"America/Manaus", "BR", -3.1190, -60.0217},
+	{"America/Fortaleza", "BR", -3.7319, -38.5267},
+
+	// Europe
+	{"Atlantic/Reykjavik", "IS", 64.1466, -21.9426},
+	{"Europe/Dublin", "IE", 53.3498, -6.2603},
+	{"Europe/London", "GB", 51.5074, -0.1278},
+	{"Europe/Lisbon", "PT", 38.7223, -9.1393},
+	{"Europe/Madrid", "ES", 40.4168, -3.7038},
+	{"Atlantic/Canary", "ES", 28.1235, -15.4363},
+	{"Europe/Paris", "FR", 48.8566, 2.3522},
+	{"Europe/Brussels", "BE", 50.8503, 4.3517},
+	{"Europe/Amsterdam", "NL", 52.3676, 4.9041},
+	{"Europe/Berlin", "DE", 52.5200, 13.4050},
+	{"Europe/Zurich", "CH", 47.3769, 8.5417},
+	{"Europe/Rome", "IT", 41.9028, 12.4964},
+	{"Europe/Vienna", "AT", 48.2082, 16.3738},
+	{"Europe/Prague", "CZ", 50.0755, 14.4378},
+	{"Europe/Warsaw", "PL", 52.2297, 21.0122},
+	{"Europe/Copenhagen", "DK", 55.6761, 12.5683},
+	{"Europe/Oslo", "NO", 59.9139, 10.7522},
+	{"Europe/Stockholm", "SE", 59.3293, 18.0686},
+	{"Europe/Helsinki", "FI", 60.1699, 24.9384},
+	{"Europe/Budapest", "HU", 47.4979, 19.0402},
+	{"Europe/Bucharest", "RO", 44.4268, 26.1025},
+	{"Europe/Sofia", "BG", 42.6977, 23.3219},
+	{"Europe/Athens", "GR", 37.9838, 23.7275},
+	{"Europe/Istanbul", "TR", 41.0082, 28.9784},
+	{"Europe/Kiev", "UA", 50.4501, 30.5234},
+	{"Europe/Minsk", "BY", 53.9006, 27.5590},
+	{"Europe/Moscow", "RU", 55.7558, 37.6173},
+	{"Europe/Samara", "RU", 53.2415, 50.2212},
+	{"Asia/Yekaterinburg", "RU", 56.8389, 60.6057},
+	{"Asia/Novosibirsk", "RU", 55.0084, 82.9357},
+	{"Asia/Irkutsk", "RU", 52.2870, 104.3050},
+	{"Asia/Vladivostok", "RU", 43.1198, 131.8869},
+
+	// Africa
+	{"Africa/Casablanca", "MA", 33.5731, -7.5898},
+	{"Africa/Algiers", "DZ", 36.7538, 3.0588},
+	{"Africa/Cairo", "EG", 30.0444, 31.2357},
+	{"Africa/Lagos", "NG", 6.5244, 3.3792},
+	{"Africa/Accra", "GH", 5.6037, -0.1870},
+	{"Africa/Nairobi", "KE", -1.2921, 36.8219},
+	{"Africa/Addis_Ababa", "ET", 9.0300, 38.7400},
+	{"Africa/Johannesburg", "ZA", -26.2041, 28.0473},
+	{"Africa/Johannesburg", "ZA", -33.9249, 18.4241},
+	{"Africa/Kinshasa", "CD", -4.4419, 15.2663},
+
+	// Asia
+	{"Asia/Jerusalem", "IL", 31.7683, 35.2137},
+	{"Asia/Riyadh", "SA", 24.7136, 46.6753},
+	{"Asia/Dubai", "AE", 25.2048, 55.2708},
+	{"Asia/Tehran", "IR", 35.6892, 51.3890},
+	{"Asia/Karachi", "PK", 24.8607, 67.0011},
+	{"Asia/Kolkata", "IN", 28.6139, 77.2090},
+	{"Asia/Kolkata", "IN", 19.0760, 72.8777},
+	{"Asia/Kolkata", "IN", 12.9716, 77.5946},
+	{"Asia/Kathmandu", "NP", 27.7172, 85.3240},
+	{"Asia/Dhaka", "BD", 23.8103, 90.4125},
+	{"Asia/Bangkok", "TH", 13.7563, 100.5018},
+	{"Asia/Ho_Chi_Minh", "VN", 10.8231, 106.6297},
+	{"Asia/Jakarta", "ID", -6.2088, 106.8456},
+	{"Asia/Makassar", "ID", -5.1477, 119.4327},
+	{"Asia/Jayapura", "ID", -2.5337, 140.7181},
+	{"Asia/Kuala_Lumpur", "MY", 3.1390, 101.6869},
+	{"Asia/Singapore", "SG", 1.3521, 103.8198},
+	{"Asia/Manila", "PH", 14.5995, 120.9842},
+	{"Asia/Shanghai", "CN", 31.2304, 121.4737},
+	{"Asia/Shanghai", "CN", 39.9042, 116.4074},
+	{"Asia/Urumqi", "CN", 43.8256, 87.6168},
+	{"Asia/Hong_Kong", "HK", 22.3193, 114.1694},
+	{"Asia/Taipei", "TW", 25.0330, 121.5654},
+	{"Asia/Seoul", "KR", 37.5665, 126.9780},
+	{"Asia/Tokyo", "JP", 35.6762, 139.6503},
+	{"Asia/Almaty", "KZ", 43.2220, 76.8512},
+	{"Asia/Tashkent", "UZ", 41.2995, 69.2401},
+
+	// Oceania
+	{"Australia/Perth", "AU", -31.9505, 115.8605},
+	{"Australia/Darwin", "AU", -12.4634, 130.8456},
+	{"Australia/Adelaide", "AU", -34.9285, 138.6007},
+	{"Australia/Brisbane", "AU", -27.4698, 153.0251},
+	{"Australia/Sydney", "AU", -33.8688, 151.2093},
+	{"Australia/Melbourne", "AU", -37.8136, 144.9631},
+	{"Australia/Hobart", "AU", -42.8821, 147.3272},
+	{"Pacific/Auckland", "NZ", -36.8485, 174.7633},
+	{"Pacific/Fiji", "FJ", -18.1248, 178.4501},
+}
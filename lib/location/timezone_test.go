@@ -0,0 +1,38 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package location
+
+import "testing"
+
+func TestTimezoneForLocation(t *testing.T) {
+	data := []struct {
+		country   string
+		lat, long float64
+		want      string
+	}{
+		{"US", 40.7128, -74.0060, "America/New_York"},     // New York
+		{"US", 37.3382, -121.8863, "America/Los_Angeles"}, // San Jose
+		{"GB", 53.4808, -2.2426, "Europe/London"},         // Manchester
+		{"", 53.5511, 9.9937, "Europe/Berlin"},            // Hamburg, with the country unknown
+		{"JP", 0, 0, "Asia/Tokyo"},                        // Single timezone countries need no location
+		{"US", 0, 0, ""},                                  // But others do
+		{"", -60, -120, ""},                               // Nowhere near anything
+	}
+
+	for _, test := range data {
+		if got := TimezoneForLocation(test.country, test.lat, test.long); got != test.want {
+			t.Errorf("TimezoneForLocation(%q, %v, %v) = %q, want %q", test.country, test.lat, test.long, got, test.want)
+		}
+	}
+}
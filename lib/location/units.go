@@ -0,0 +1,69 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+import "strings"
+
+// Units is the system of measurement that distances are returned in.
+type Units string
+
+// The supported Units.
+const (
+	Metric   Units = "metric"
+	Imperial Units = "imperial"
+)
+
+const kmPerMile = 1.609344
+
+// imperialCountries are the countries where distances are commonly given in miles.
+var imperialCountries = map[string]bool{
+	"GB": true,
+	"LR": true,
+	"MM": true,
+	"US": true,
+}
+
+// ChooseUnits returns the requested units (from the "units" query parameter), or if none (or
+// invalid) units were requested, the units commonly used in the client's country. Metric is used
+// if the country is unknown.
+func ChooseUnits(requested, country string) Units {
+	switch Units(strings.ToLower(requested)) {
+	case Metric:
+		return Metric
+	case Imperial:
+		return Imperial
+	}
+
+	if imperialCountries[strings.ToUpper(country)] {
+		return Imperial
+	}
+	return Metric
+}
+
+// FromKm converts the distance in kilometers to these units.
+func (u Units) FromKm(km float64) float64 {
+	if u == Imperial {
+		return km / kmPerMile
+	}
+	return km
+}
+
+// Symbol returns the abbreviation for distances in these units, e.g "km".
+func (u Units) Symbol() string {
+	if u == Imperial {
+		return "mi"
+	}
+	return "km"
+}
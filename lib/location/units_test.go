@@ -0,0 +1,51 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+import (
+	"math"
+	"testing"
+)
+
+func TestChooseUnits(t *testing.T) {
+	data := []struct {
+		requested string
+		country   string
+		want      Units
+	}{
+		{requested: "", country: "US", want: Imperial},
+		{requested: "", country: "gb", want: Imperial},
+		{requested: "", country: "FR", want: Metric},
+		{requested: "", country: "", want: Metric},
+		{requested: "metric", country: "US", want: Metric},
+		{requested: "IMPERIAL", country: "FR", want: Imperial},
+		{requested: "furlongs", country: "FR", want: Metric},
+	}
+
+	for _, test := range data {
+		if got := ChooseUnits(test.requested, test.country); got != test.want {
+			t.Errorf("ChooseUnits(%q, %q) = %q, want %q", test.requested, test.country, got, test.want)
+		}
+	}
+}
+
+func TestUnitsFromKm(t *testing.T) {
+	if got := Metric.FromKm(10); got != 10 {
+		t.Errorf("Metric.FromKm(10) = %v, want 10", got)
+	}
+	if got := Imperial.FromKm(kmPerMile * 10); math.Abs(got-10) > 1e-9 {
+		t.Errorf("Imperial.FromKm(%v) = %v, want 10", kmPerMile*10, got)
+	}
+}
@@ -0,0 +1,141 @@
+package myip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/handlers"
+)
+
+// AccessLogHandler returns a handler logging each request to out, in the conf.Config.LogFormat.
+func AccessLogHandler(config *conf.Config, out io.Writer, h http.Handler) http.Handler {
+	if config.LogFormat == "json" {
+		return jsonLogHandler(config, out, h)
+	}
+	return handlers.CustomLoggingHandler(out, h, AccessLogFormatter(config))
+}
+
+// AccessLogFormatter returns a handlers.LogFormatter writing the Apache combined log format,
+// followed by the request ID (see RequestID), so log lines can be correlated with responses.
+func AccessLogFormatter(config *conf.Config) handlers.LogFormatter {
+	header := requestIDHeader(config)
+	return func(w io.Writer, params handlers.LogFormatterParams) {
+		req := params.Request
+
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+
+		requestID := req.Header.Get(header)
+		if requestID == "" {
+			requestID = "-"
+		}
+
+		fmt.Fprintf(w, "%s - - [%s] %q %d %d %q %q %q\n",
+			host,
+			params.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
+			req.Method+" "+params.URL.RequestURI()+" "+req.Proto,
+			params.StatusCode,
+			params.Size,
+			req.Referer(),
+			req.UserAgent(),
+			requestID,
+		)
+	}
+}
+
+// jsonLogEntry is a single line of the JSON access log.
+type jsonLogEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// jsonLogHandler returns a handler logging each request to out as a JSON object, one per line.
+func jsonLogHandler(config *conf.Config, out io.Writer, h http.Handler) http.Handler {
+	header := requestIDHeader(config)
+
+	var mu sync.Mutex // Serialises the writes to out
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		h.ServeHTTP(sw, req)
+
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+
+		b, err := json.Marshal(&jsonLogEntry{
+			Timestamp:  start.UTC(),
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			Status:     sw.status(),
+			Bytes:      sw.bytes,
+			DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+			RemoteAddr: host,
+			UserAgent:  req.UserAgent(),
+			RequestID:  req.Header.Get(header), // Set by the RequestID middleware
+		})
+		if err != nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		out.Write(append(b, '\n'))
+	})
+}
+
+// statusWriter is a http.ResponseWriter recording the status code and number of bytes written.
+type statusWriter struct {
+	http.ResponseWriter
+
+	code  int
+	bytes int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush allows streaming responses (such as the events) through the writer.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// status returns the status code sent, which is 200 OK if the handler never set one.
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,77 @@
+package myip
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/handlers"
+)
+
+func TestAccessLogFormatter(t *testing.T) {
+	req := httptest.NewRequest("GET", "/json?a=b", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Request-Id", "abc-123")
+	req.Header.Set("User-Agent", "curl/7.64.1")
+
+	var buf bytes.Buffer
+	AccessLogFormatter(&conf.Config{})(&buf, handlers.LogFormatterParams{
+		Request:    req,
+		URL:        *req.URL,
+		TimeStamp:  time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC),
+		StatusCode: 200,
+		Size:       42,
+	})
+
+	want := `192.0.2.1 - - [01/Jul/2020:12:00:00 +0000] "GET /json?a=b HTTP/1.1" 200 42 "" "curl/7.64.1" "abc-123"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("AccessLogFormatter() = %q, want %q", got, want)
+	}
+}
+
+func TestAccessLogHandlerJSON(t *testing.T) {
+	config := &conf.Config{LogFormat: "json"}
+
+	var buf bytes.Buffer
+	h := AccessLogHandler(config, &buf, RequestID(config)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	})))
+
+	req := httptest.NewRequest("GET", "/json?a=b", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Request-Id", "abc-123")
+	req.Header.Set("User-Agent", "curl/7.64.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("AccessLogHandler() logged %q, invalid json: %s", buf.String(), err)
+	}
+
+	want := map[string]interface{}{
+		"method":      "GET",
+		"path":        "/json",
+		"status":      float64(http.StatusTeapot),
+		"bytes":       float64(len("hello")),
+		"remote_addr": "192.0.2.1",
+		"user_agent":  "curl/7.64.1",
+		"request_id":  "abc-123",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("AccessLogHandler() logged %s = %v, want %v", key, got[key], value)
+		}
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, got["timestamp"].(string)); err != nil {
+		t.Errorf("AccessLogHandler() logged timestamp = %q, want RFC 3339: %s", got["timestamp"], err)
+	}
+	if d, ok := got["duration_ms"].(float64); !ok || d < 0 {
+		t.Errorf("AccessLogHandler() logged duration_ms = %v, want a positive number", got["duration_ms"])
+	}
+}
@@ -0,0 +1,47 @@
+package myip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"bramp.net/myip/lib/cache"
+)
+
+// ASNHandler returns just the client's AS number (e.g. "AS15169") as plain text, or the full
+// asn.Response with "?format=json". Returns 204 No Content if the AS can't be determined, such as
+// for local addresses, which are never looked up.
+func (s *DefaultServer) ASNHandler(w http.ResponseWriter, req *http.Request) {
+	host, err := s.GetRemoteAddr(req)
+	if err != nil {
+		status, _ := errResponse(err)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if isLocalScope(addressScope(net.ParseIP(host))) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ctx := req.Context()
+	if s.hostOverride(req) != "" {
+		ctx = cache.WithBypass(ctx)
+	}
+
+	response := s.lookupASN(ctx, host)
+	if response.Number == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if req.URL.Query().Get("format") == "json" {
+		s.writeJSON(w, req, response)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "AS%d\n", response.Number)
+}
@@ -0,0 +1,50 @@
+package myip
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/asn"
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+)
+
+func TestASNHandler(t *testing.T) {
+	data := []struct {
+		url        string
+		remoteAddr string
+		wantCode   int
+		wantBody   string
+		wantLookup bool
+		wantBypass bool
+	}{
+		{url: "/asn", remoteAddr: "8.8.8.8:1234", wantCode: http.StatusOK, wantBody: "AS15169\n", wantLookup: true},
+		{url: "/asn", remoteAddr: "10.0.0.1:1234", wantCode: http.StatusNoContent},  // Local addresses are never looked up
+		{url: "/asn", remoteAddr: "127.0.0.1:1234", wantCode: http.StatusNoContent}, // Nor loopbacks
+		{url: "/asn?host=8.8.8.8", remoteAddr: "192.0.2.1:1234", wantCode: http.StatusOK, wantBody: "AS15169\n", wantLookup: true, wantBypass: true},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{Debug: true})
+
+		var lookedUp, bypassed bool
+		s.asnLookup = func(ctx context.Context, addr string) *asn.Response {
+			lookedUp, bypassed = true, cache.Bypassed(ctx)
+			return &asn.Response{Query: addr, Number: 15169}
+		}
+
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.RemoteAddr = test.remoteAddr
+		w := httptest.NewRecorder()
+		s.ASNHandler(w, req)
+
+		if w.Code != test.wantCode || w.Body.String() != test.wantBody {
+			t.Errorf("ASNHandler(%q from %q) = (%d, %q), want (%d, %q)", test.url, test.remoteAddr, w.Code, w.Body.String(), test.wantCode, test.wantBody)
+		}
+		if lookedUp != test.wantLookup || bypassed != test.wantBypass {
+			t.Errorf("ASNHandler(%q from %q) looked up %t bypassing the cache %t, want %t, %t", test.url, test.remoteAddr, lookedUp, bypassed, test.wantLookup, test.wantBypass)
+		}
+	}
+}
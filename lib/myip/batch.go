@@ -0,0 +1,208 @@
+package myip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultMaxBatchSize is used if conf.Config.MaxBatchSize is unset.
+	defaultMaxBatchSize = 100
+
+	// maxBatchBodyBytes bounds the size of the posted batch.
+	maxBatchBodyBytes = 1 << 20
+
+	// batchConcurrency is how many addresses of a batch are looked up at once.
+	batchConcurrency = 4
+)
+
+// batchParams are the query parameters of the batch request applied to each address, to choose
+// the lookups and fields.
+var batchParams = []string{"include", "exclude", "fields", "reverse", "whois", "ua"}
+
+// parseBatch parses the addresses from the body, which is either a JSON array of strings, or one
+// address per line.
+func parseBatch(body []byte) ([]string, error) {
+	body = bytes.TrimSpace(body)
+	if bytes.HasPrefix(body, []byte("[")) {
+		var addrs []string
+		if err := json.Unmarshal(body, &addrs); err != nil {
+			return nil, fmt.Errorf("invalid JSON array of addresses: %w", err)
+		}
+		return addrs, nil
+	}
+
+	var addrs []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			addrs = append(addrs, line)
+		}
+	}
+	return addrs, nil
+}
+
+// batchRequest returns the request to look up addr, as if it came from addr. Only the batch
+// request's batchParams are kept, so none of the caller's details (such as their headers, or
+// their other address) are attributed to addr.
+func batchRequest(req *http.Request, addr string) *http.Request {
+	q := url.Values{}
+	for _, param := range batchParams {
+		if values, found := req.URL.Query()[param]; found {
+			q[param] = values
+		}
+	}
+
+	return (&http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: "/json", RawQuery: q.Encode()},
+		Proto:      req.Proto,
+		ProtoMajor: req.ProtoMajor,
+		ProtoMinor: req.ProtoMinor,
+		Header:     make(http.Header),
+		Host:       req.Host,
+		RemoteAddr: addr,
+	}).WithContext(req.Context())
+}
+
+// BatchHandler looks up each of the addresses posted, as a JSON array or one per line, and streams
+// the results as newline delimited JSON, each sent as soon as it completes. Addresses that can't
+// be looked up (e.g. invalid) have a ErrResponse line instead. At most conf.Config.MaxBatchSize
+// addresses may be posted, and each address counts against the caller's conf.Config.RateLimit,
+// those over the limit having a ErrResponse line.
+func (s *DefaultServer) BatchHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.writeJSONStatus(w, req, http.StatusMethodNotAllowed, &ErrResponse{Error: "batches must be POSTed"})
+		return
+	}
+
+	caller, err := s.GetRemoteAddr(req)
+	if err != nil {
+		status, resp := errResponse(err)
+		s.writeJSONStatus(w, req, status, resp)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxBatchBodyBytes))
+	if err != nil {
+		s.writeJSONStatus(w, req, http.StatusRequestEntityTooLarge, &ErrResponse{Error: err.Error(), Code: codeBatchTooLarge})
+		return
+	}
+
+	addrs, err := parseBatch(body)
+	if err != nil {
+		s.writeJSONStatus(w, req, http.StatusBadRequest, &ErrResponse{Error: err.Error(), Code: codeInvalidBatch})
+		return
+	}
+
+	max := s.Config.MaxBatchSize
+	if max <= 0 {
+		max = defaultMaxBatchSize
+	}
+	if len(addrs) > max {
+		s.writeJSONStatus(w, req, http.StatusRequestEntityTooLarge, &ErrResponse{
+			Error: fmt.Sprintf("batch of %d addresses is larger than the maximum %d", len(addrs), max),
+			Code:  codeBatchTooLarge,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	s.writeCORSHeaders(w, req)
+
+	flusher, _ := w.(http.Flusher)
+	fields := requestedFields(req)
+
+	// Cancelled if the results can't be written, to stop looking up the rest of the batch.
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	results := make(chan interface{})
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < batchConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range queue {
+				if !s.rateLimiter.allow(caller) {
+					results <- &ErrResponse{
+						Error: http.StatusText(http.StatusTooManyRequests),
+						Code:  codeRateLimited,
+						Value: addr,
+					}
+					continue
+				}
+				results <- s.batchLookup(req, addr, fields)
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+		for _, addr := range addrs {
+			select {
+			case queue <- addr:
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	e := json.NewEncoder(w)
+	for result := range results {
+		if result == nil {
+			continue // Cancelled
+		}
+
+		if err := e.Encode(result); err != nil {
+			log.Warningf("Failed to write batch result, abandoning the batch: %s", err)
+			cancel()
+			break
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// Wait for the in-flight lookups, which return quickly once cancelled.
+	for range results {
+	}
+}
+
+// batchLookup returns the result of looking up one address of the batch, either a Response (or
+// just its requested fields), or a ErrResponse. nil is returned if the request was cancelled.
+func (s *DefaultServer) batchLookup(req *http.Request, addr string, fields map[string]bool) interface{} {
+	response, err := s.MyIPHandler(batchRequest(req, addr))
+	if cancelled(err) {
+		return nil
+	}
+	if err != nil {
+		_, resp := errResponse(err)
+		return resp
+	}
+
+	if fields != nil {
+		data, err := selectFields(response, fields)
+		if err != nil {
+			_, resp := errResponse(err)
+			return resp
+		}
+		return data
+	}
+	return response
+}
@@ -0,0 +1,165 @@
+package myip
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestParseBatch(t *testing.T) {
+	data := []struct {
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{body: `["203.0.113.1", "2001:db8::1"]`, want: []string{"203.0.113.1", "2001:db8::1"}},
+		{body: "203.0.113.1\n\n 2001:db8::1 \r\n", want: []string{"203.0.113.1", "2001:db8::1"}},
+		{body: "", want: nil},
+		{body: `["203.0.113.1", 1]`, wantErr: true},
+	}
+
+	for _, test := range data {
+		got, err := parseBatch([]byte(test.body))
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseBatch(%q) err = %v, want err %t", test.body, err, test.wantErr)
+			continue
+		}
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("parseBatch(%q) diff: (-got +want)\n%s", test.body, diff)
+		}
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	s := newSlowServer(&conf.Config{}, 0, 0, 0)
+
+	req := httptest.NewRequest("POST", "/batch?include=dns,whois", strings.NewReader("203.0.113.1\n203.0.113.2\nnot-an-ip\n"))
+	req.Header.Set("User-Agent", "curl/7.64.1")
+	w := httptest.NewRecorder()
+	s.BatchHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("BatchHandler() code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("BatchHandler() Content-Type = %q, want %q", got, "application/x-ndjson")
+	}
+
+	// The lines are in the order the lookups completed.
+	var got []string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var line struct {
+			RemoteAddr      string
+			RemoteAddrWhois *struct{ Query string }
+			UserAgent       interface{}
+			Location        interface{}
+
+			Code  string `json:"code"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("BatchHandler() line %q is invalid json: %s", scanner.Text(), err)
+		}
+
+		if line.Code != "" {
+			got = append(got, line.Code+" "+line.Value)
+			continue
+		}
+		if line.RemoteAddrWhois == nil || line.RemoteAddrWhois.Query != line.RemoteAddr {
+			t.Errorf("BatchHandler() line %q, want the whois of %q", scanner.Text(), line.RemoteAddr)
+		}
+		if line.UserAgent != nil || line.Location != nil {
+			t.Errorf("BatchHandler() line %q, want only the included lookups", scanner.Text())
+		}
+		got = append(got, line.RemoteAddr)
+	}
+	sort.Strings(got)
+
+	want := []string{"203.0.113.1", "203.0.113.2", codeInvalidIP + " not-an-ip"}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("BatchHandler() lines diff: (-got +want)\n%s", diff)
+	}
+}
+
+func TestBatchHandlerErrors(t *testing.T) {
+	s := newSlowServer(&conf.Config{MaxBatchSize: 2}, 0, 0, 0)
+
+	data := []struct {
+		method   string
+		body     string
+		wantCode int
+	}{
+		{method: "GET", wantCode: http.StatusMethodNotAllowed},
+		{method: "POST", body: `["203.0.113.1", "203.0.113.2", "203.0.113.3"]`, wantCode: http.StatusRequestEntityTooLarge},
+		{method: "POST", body: `["203.0.113.1"`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest(test.method, "/batch", strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		s.BatchHandler(w, req)
+
+		if w.Code != test.wantCode {
+			t.Errorf("BatchHandler(%s %q) code = %d, want %d", test.method, test.body, w.Code, test.wantCode)
+		}
+	}
+}
+
+func TestBatchHandlerRateLimit(t *testing.T) {
+	s := newSlowServer(&conf.Config{RateLimit: 0.001, RateLimitBurst: 2}, 0, 0, 0)
+
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(`["203.0.113.1", "203.0.113.2", "203.0.113.3"]`))
+	w := httptest.NewRecorder()
+	s.BatchHandler(w, req)
+
+	limited := 0
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var line struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("BatchHandler() line %q is invalid json: %s", scanner.Text(), err)
+		}
+		if line.Code == codeRateLimited {
+			limited++
+		}
+	}
+
+	// Each address costs the caller one request, so only the burst of 2 are looked up.
+	if limited != 1 {
+		t.Errorf("BatchHandler() rate limited %d addresses, want 1", limited)
+	}
+}
+
+// failingWriter is a http.ResponseWriter whose writes fail, as if the client went away.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("connection reset")
+}
+
+func TestBatchHandlerWriteError(t *testing.T) {
+	s := newSlowServer(&conf.Config{}, 0, 0, 0)
+
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(`["203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4", "203.0.113.5"]`))
+	w := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+	s.BatchHandler(w, req)
+
+	if w.writes != 1 {
+		t.Errorf("BatchHandler() wrote %d times, want 1 (stopping after the failure)", w.writes)
+	}
+}
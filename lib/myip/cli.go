@@ -1,7 +1,9 @@
 package myip
 
 import (
+	"bytes"
 	"net/http"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -10,35 +12,90 @@ import (
 
 var cliTmpl = template.Must(template.New("test").Parse(
 	"IP: {{.RemoteAddr}}\n" +
-		"{{range .RemoteAddrReverse.Names}}" +
+		"{{range .RemoteAddrReverse.DisplayNames}}" +
 		"DNS: {{.}}\n" +
 		"{{end}}\n" +
 		"WHOIS:\n" +
 		"{{.RemoteAddrWhois.Body}}\n\n" +
 		"Location: " +
 		"{{.Location.City}} {{.Location.Region}} {{.Location.Country}}" +
-		"{{if (and (ne .Location.Lat 0.0) (ne .Location.Long 0.0))}} ({{.Location.Lat}}, {{.Location.Long}}) {{end}}\n\n" +
-		"ID: {{.RequestID}}\n"))
+		"{{if (and (ne .Location.Lat 0.0) (ne .Location.Long 0.0))}} ({{.Location.Lat}}, {{.Location.Long}})" +
+		"{{with .Location.AccuracyRadius}} ±{{.}}{{$.Location.Units.Symbol}}{{end}} {{end}}" +
+		"{{with .Location.Timezone}} {{.}}{{end}}\n\n" +
+		"ID: {{.RequestID}}\n" +
+		"{{if .Verbose}}" +
+		"\nMethod: {{.Method}}\n" +
+		"Proto: {{.Proto}}\n" +
+		"Referer: {{.Referer}}\n" +
+		"{{end}}"))
 
-// isCLI returns true iif the request is coming from a cli tool, such as curl, or wget
-func isCLI(req *http.Request, _ *mux.RouteMatch) bool {
-	ua := req.Header.Get("User-Agent")
-	return strings.HasPrefix(ua, "curl/") || strings.HasPrefix(ua, "Wget/")
+// cliView is the data for the cliTmpl.
+type cliView struct {
+	*Response
+
+	// Verbose is set by the "verbose" query param, to also print details of the request.
+	Verbose bool
+	Referer string
+}
+
+// newCLIView returns the cliTmpl's data for this response.
+func newCLIView(req *http.Request, response *Response) *cliView {
+	verbose, _ := strconv.ParseBool(req.URL.Query().Get("verbose"))
+	return &cliView{
+		Response: response,
+		Verbose:  verbose,
+		Referer:  req.Referer(),
+	}
+}
+
+// defaultCLIUserAgents are used when conf.Config.CLIUserAgents is empty.
+var defaultCLIUserAgents = []string{"curl/", "Wget/"}
+
+// newCLIMatcher returns a matcher that returns true iif the request is coming from a cli tool,
+// such as curl, or wget. That is the User-Agent starts with one of the prefixes, ignoring case.
+func newCLIMatcher(prefixes []string) mux.MatcherFunc {
+	if len(prefixes) == 0 {
+		prefixes = defaultCLIUserAgents
+	}
+
+	lower := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		lower[i] = strings.ToLower(prefix)
+	}
+
+	return func(req *http.Request, _ *mux.RouteMatch) bool {
+		ua := strings.ToLower(req.Header.Get("User-Agent"))
+		for _, prefix := range lower {
+			if strings.HasPrefix(ua, prefix) {
+				return true
+			}
+		}
+		return false
+	}
 }
 
 // CLIHandler handles a CLI request to the service.
 func (s *DefaultServer) CLIHandler(w http.ResponseWriter, req *http.Request) {
+	s.metrics.request(formatText)
+
 	response, err := s.MyIPHandler(req)
+	if cancelled(err) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "text/plain")
 
 	if err == nil {
-		err = cliTmpl.Execute(w, response)
+		var buf bytes.Buffer
+		if err = cliTmpl.Execute(&buf, newCLIView(req, response)); err == nil {
+			writeServerTiming(w, response.serverTiming)
+			setDownload(w, req, "myip.txt")
+			writeBody(w, req, http.StatusOK, buf.Bytes())
+			return
+		}
 		// Drop though with a new err
 	}
 
-	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
-	}
+	status, _ := errResponse(err)
+	writeBody(w, req, status, []byte(err.Error()))
 }
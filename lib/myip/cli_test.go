@@ -0,0 +1,113 @@
+package myip
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/location"
+	"bramp.net/myip/lib/whois"
+)
+
+func TestCLIMatcher(t *testing.T) {
+	data := []struct {
+		prefixes  []string
+		userAgent string
+		want      bool
+	}{
+		{prefixes: nil, userAgent: "curl/7.64.1", want: true},
+		{prefixes: nil, userAgent: "Wget/1.20.3 (linux-gnu)", want: true},
+		{prefixes: nil, userAgent: "HTTPie/3.2.1", want: false},
+		{prefixes: nil, userAgent: "Mozilla/5.0", want: false},
+		{prefixes: []string{"curl/", "httpie/"}, userAgent: "HTTPie/3.2.1", want: true},
+		{prefixes: []string{"MyMonitor/"}, userAgent: "mymonitor/2.0", want: true},
+		{prefixes: []string{"MyMonitor/"}, userAgent: "curl/7.64.1", want: false},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", test.userAgent)
+
+		if got := newCLIMatcher(test.prefixes)(req, nil); got != test.want {
+			t.Errorf("newCLIMatcher(%q)(User-Agent: %q) = %v, want %v", test.prefixes, test.userAgent, got, test.want)
+		}
+	}
+}
+
+func TestCLIHandler(t *testing.T) {
+	s := newSlowServer(&conf.Config{}, 0, 0, 0)
+	s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		return &dns.Response{Query: addr, Names: []string{"host.example.com."}}
+	}
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		return &whois.Response{Query: addr, Body: "OrgName: Example"}
+	}
+
+	const want = "IP: 192.0.2.1\n" +
+		"DNS: host.example.com.\n" +
+		"\n" +
+		"WHOIS:\n" +
+		"OrgName: Example\n" +
+		"\n" +
+		"Location:   GB Europe/London\n" +
+		"\n" +
+		"ID: \n"
+
+	data := []struct {
+		url  string
+		want string
+	}{
+		{url: "/", want: want},
+		{url: "/?verbose=0", want: want},
+		{
+			url: "/?verbose=1",
+			want: want +
+				"\n" +
+				"Method: GET\n" +
+				"Proto: HTTP/1.1\n" +
+				"Referer: https://example.com/redirected\n",
+		},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.Header.Set("Referer", "https://example.com/redirected")
+		w := httptest.NewRecorder()
+		s.CLIHandler(w, req)
+
+		if got := w.Body.String(); got != test.want {
+			t.Errorf("CLIHandler(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+func TestCLIHandlerAccuracyRadius(t *testing.T) {
+	data := []struct {
+		url    string
+		radius int
+		want   string
+	}{
+		{url: "/?units=metric", radius: 50, want: "Location:   GB (51.5, -0.12) ±50km  Europe/London\n"},
+		{url: "/", radius: 50, want: "Location:   GB (51.5, -0.12) ±31mi  Europe/London\n"}, // GB defaults to imperial
+		{url: "/", radius: 0, want: "Location:   GB (51.5, -0.12)  Europe/London\n"},
+	}
+
+	for _, test := range data {
+		s := newSlowServer(&conf.Config{}, 0, 0, 0)
+		radius := test.radius
+		s.locator = location.ProviderFunc(func(ctx context.Context, ip net.IP) (*location.Response, error) {
+			return &location.Response{Country: "GB", Lat: 51.5, Long: -0.12, AccuracyRadiusKm: radius}, nil
+		})
+
+		w := httptest.NewRecorder()
+		s.CLIHandler(w, httptest.NewRequest("GET", test.url, nil))
+
+		if got := w.Body.String(); !strings.Contains(got, test.want) {
+			t.Errorf("CLIHandler(%q, radius %d) = %q, want it to contain %q", test.url, test.radius, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package myip
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	encodingBrotli   = "br"
+	encodingGzip     = "gzip"
+	encodingDeflate  = "deflate"
+	encodingIdentity = "identity"
+)
+
+// supportedEncodings are the content encodings we can serve, in order of preference.
+var supportedEncodings = []string{encodingBrotli, encodingGzip, encodingDeflate}
+
+// negotiateEncoding returns the most preferred encoding accepted by the Accept-Encoding header,
+// or identity if none are.
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[coding] = q > 0
+	}
+
+	for _, encoding := range supportedEncodings {
+		if ok, found := accepted[encoding]; ok || (!found && accepted["*"]) {
+			return encoding
+		}
+	}
+	return encodingIdentity
+}
+
+// compressWriter compresses everything written to the underlying ResponseWriter.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	quality  int
+
+	w           io.WriteCloser // Lazily created on the first write
+	wroteHeader bool
+	passthrough bool // The handler set its own Content-Encoding
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		cw.passthrough = true
+	} else {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length") // The length will change once compressed
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			// Sniff before compressing, otherwise the compressed bytes would be sniffed.
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	if cw.w == nil {
+		switch cw.encoding {
+		case encodingBrotli:
+			cw.w = brotli.NewWriterLevel(cw.ResponseWriter, cw.quality)
+		case encodingDeflate:
+			cw.w, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		default:
+			cw.w = gzip.NewWriter(cw.ResponseWriter)
+		}
+	}
+	return cw.w.Write(b)
+}
+
+// Flush flushes any buffered compressed data to the client, so streaming responses still work.
+func (cw *compressWriter) Flush() {
+	if cw.w != nil {
+		if f, ok := cw.w.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the compressed stream.
+func (cw *compressWriter) Close() error {
+	if cw.w == nil {
+		return nil
+	}
+	return cw.w.Close()
+}
+
+// Compress returns middleware which compresses responses with the best encoding the client
+// accepts, Brotli, then gzip, then deflate, falling back to no compression.
+func Compress(config *conf.Config) func(http.Handler) http.Handler {
+	quality := config.BrotliQuality
+	if quality == 0 {
+		quality = brotli.DefaultCompression
+	}
+
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == encodingIdentity {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				quality:        quality,
+			}
+			defer cw.Close()
+
+			h.ServeHTTP(cw, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,131 @@
+package myip
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	data := []struct {
+		header string
+		want   string
+	}{
+		{"", encodingIdentity},
+		{"gzip", encodingGzip},
+		{"gzip, deflate, br", encodingBrotli},
+		{"br;q=0, gzip", encodingGzip},
+		{"GZIP;q=0.5", encodingGzip},
+		{"*", encodingBrotli},
+		{"*, br;q=0", encodingGzip},
+		{"deflate", encodingDeflate},
+		{"identity", encodingIdentity},
+	}
+
+	for _, test := range data {
+		if got := negotiateEncoding(test.header); got != test.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	const body = `{"RemoteAddr":"203.0.113.1"}`
+	handler := Compress(&conf.Config{BrotliQuality: 4})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+
+	data := []struct {
+		acceptEncoding string
+		want           string
+		decode         func(io.Reader) (io.Reader, error)
+	}{
+		{"", "", func(r io.Reader) (io.Reader, error) { return r, nil }},
+		{"gzip", encodingGzip, func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"gzip, br", encodingBrotli, func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+		{"deflate", encodingDeflate, func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil }},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "/json", nil)
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != test.want {
+			t.Errorf("Accept-Encoding: %q Content-Encoding = %q, want %q", test.acceptEncoding, got, test.want)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding: %q Vary = %q, want %q", test.acceptEncoding, got, "Accept-Encoding")
+		}
+
+		r, err := test.decode(w.Body)
+		if err != nil {
+			t.Errorf("Accept-Encoding: %q decoding failed: %s", test.acceptEncoding, err)
+			continue
+		}
+		if got, err := ioutil.ReadAll(r); err != nil || string(got) != body {
+			t.Errorf("Accept-Encoding: %q body = %q, %v, want %q", test.acceptEncoding, got, err, body)
+		}
+	}
+}
+
+func TestCompressRegister(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{
+		Debug:             true,
+		CompressResponses: true,
+	})
+
+	// The debug config is large enough to be worth compressing
+	req := httptest.NewRequest("GET", "/debug/config", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Encoding"); got != encodingGzip {
+		t.Fatalf("GET /debug/config Content-Encoding = %q, want %q", got, encodingGzip)
+	}
+	if got := w.Header()["Vary"]; !contains(got, "Accept-Encoding") || !contains(got, "Origin") {
+		t.Errorf("GET /debug/config Vary = %q, want Accept-Encoding and Origin", got)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("GET /debug/config returned invalid gzip: %s", err)
+	}
+	var config conf.Config
+	if err := json.NewDecoder(gz).Decode(&config); err != nil || !config.CompressResponses {
+		t.Errorf("GET /debug/config = (%+v, %v), want the config", config, err)
+	}
+
+	// /ip is tiny, so not worth compressing
+	req = httptest.NewRequest("GET", "/ip", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("GET /ip Content-Encoding = %q, want none", got)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package myip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+type connIDKey struct{}
+
+var lastConnID uint64
+
+// ConnContext should be set as the http.Server's ConnContext. It tags each connection with an ID,
+// so in debug mode requests multiplexed over the same HTTP/2 connection can be identified.
+//
+// net/http does not expose the HTTP/2 stream ID, so only the connection can be identified.
+func ConnContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connIDKey{}, atomic.AddUint64(&lastConnID, 1))
+}
+
+// connID returns the ID of the HTTP/2 connection this request arrived on, or "" if it's not known,
+// or the request was not over HTTP/2.
+func connID(req *http.Request) string {
+	if req.ProtoMajor != 2 {
+		return ""
+	}
+	if id, ok := req.Context().Value(connIDKey{}).(uint64); ok {
+		return strconv.FormatUint(id, 10)
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package myip
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConnContext(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(connID(req)))
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ConnContext = ConnContext
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := srv.Client()
+	get := func() string {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get(%q) err = %s", srv.URL, err)
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("Get(%q) proto = %q, want HTTP/2", srv.URL, resp.Proto)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Get(%q) read err = %s", srv.URL, err)
+		}
+		return string(body)
+	}
+
+	first := get()
+	if first == "" {
+		t.Fatalf("connID() = %q, want a ID", first)
+	}
+	if second := get(); second != first {
+		t.Errorf("connID() on the same connection = %q, want %q", second, first)
+	}
+
+	client.CloseIdleConnections()
+	if third := get(); third == first || third == "" {
+		t.Errorf("connID() on a new connection = %q, want a new ID (not %q)", third, first)
+	}
+}
+
+func TestConnIDHTTP1(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(connID(req)))
+	}))
+	srv.Config.ConnContext = ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get(%q) err = %s", srv.URL, err)
+	}
+	defer resp.Body.Close()
+
+	// Only HTTP/2 connections are identified.
+	if body, _ := ioutil.ReadAll(resp.Body); len(body) != 0 {
+		t.Errorf("connID() over HTTP/1.1 = %q, want %q", body, "")
+	}
+}
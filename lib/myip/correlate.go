@@ -0,0 +1,61 @@
+package myip
+
+import (
+	"net/http"
+	"time"
+
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+)
+
+const (
+	// correlationSize is the maximum number of tokens remembered.
+	correlationSize = 10000
+
+	// correlationTTL is how long a token is remembered for.
+	correlationTTL = 5 * time.Minute
+)
+
+// correlator remembers the address each client token was seen from, per address family. This
+// allows the IPv4 and IPv6 requests from a dual-stacked client to be associated.
+type correlator struct {
+	seen *cache.Cache
+}
+
+func newCorrelator(config *conf.Config) *correlator {
+	return &correlator{
+		seen: cache.New(correlationSize, correlationTTL),
+	}
+}
+
+// see records that the token was seen from this address, returning the address most recently
+// seen with the same token but from the other address family.
+func (c *correlator) see(token, addr string) string {
+	family := addressFamily(addr)
+	c.seen.Set(token+"/"+family, addr)
+
+	other := "IPv6"
+	if family == "IPv6" {
+		other = "IPv4"
+	}
+	if addr, found := c.seen.Get(token + "/" + other); found {
+		return addr.(string)
+	}
+	return ""
+}
+
+// correlatedAddr returns the client's address from the other address family, if it's known. The
+// client correlates its requests by sending the same (unguessable) "token" query parameter with
+// each. Debug host overrides are never recorded.
+func (s *DefaultServer) correlatedAddr(req *http.Request, host string) string {
+	if !s.Config.DualStackReverse || s.hostOverride(req) != "" {
+		return ""
+	}
+
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		return ""
+	}
+
+	return s.correlator.see(token, host)
+}
@@ -0,0 +1,40 @@
+package myip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestCorrelatorSee(t *testing.T) {
+	c := newCorrelator(&conf.Config{})
+
+	if got := c.see("token", "192.0.2.1"); got != "" {
+		t.Errorf("see(%q, %q) = %q, want %q", "token", "192.0.2.1", got, "")
+	}
+	if got := c.see("token", "2001:db8::1"); got != "192.0.2.1" {
+		t.Errorf("see(%q, %q) = %q, want %q", "token", "2001:db8::1", got, "192.0.2.1")
+	}
+	if got := c.see("token", "192.0.2.1"); got != "2001:db8::1" {
+		t.Errorf("see(%q, %q) = %q, want %q", "token", "192.0.2.1", got, "2001:db8::1")
+	}
+	if got := c.see("other", "2001:db8::2"); got != "" {
+		t.Errorf("see(%q, %q) = %q, want %q", "other", "2001:db8::2", got, "")
+	}
+}
+
+func TestCorrelatedAddrIgnoresHostOverride(t *testing.T) {
+	s := newDefaultServer(&conf.Config{Debug: true, DualStackReverse: true})
+
+	req := httptest.NewRequest("GET", "/json?token=abc&host=2001:db8::1", nil)
+	if got := s.correlatedAddr(req, "2001:db8::1"); got != "" {
+		t.Errorf("correlatedAddr(%q) = %q, want %q", req.URL, got, "")
+	}
+
+	// The override must not have been recorded for real clients using the same token.
+	req = httptest.NewRequest("GET", "/json?token=abc", nil)
+	if got := s.correlatedAddr(req, "192.0.2.1"); got != "" {
+		t.Errorf("correlatedAddr(%q) = %q, want %q", req.URL, got, "")
+	}
+}
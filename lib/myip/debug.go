@@ -0,0 +1,49 @@
+package myip
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"bramp.net/myip/lib/location"
+)
+
+// debugAllowed returns true if this request may access the debug endpoints. That is either the
+// server is in debug mode, or the request has the configured debug token.
+func (s *DefaultServer) debugAllowed(req *http.Request) bool {
+	if s.Config.Debug {
+		return true
+	}
+
+	if s.Config.DebugToken == "" {
+		return false
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.DebugToken)) == 1
+}
+
+// DebugConfigHandler returns the effective config, with any secrets redacted.
+func (s *DefaultServer) DebugConfigHandler(w http.ResponseWriter, req *http.Request) {
+	if !s.debugAllowed(req) {
+		http.NotFound(w, req)
+		return
+	}
+
+	s.writeJSON(w, req, s.Config.Redacted())
+}
+
+// DebugGeoDataHandler returns the metadata of the geo location data, such as its provider and age.
+func (s *DefaultServer) DebugGeoDataHandler(w http.ResponseWriter, req *http.Request) {
+	if !s.debugAllowed(req) {
+		http.NotFound(w, req)
+		return
+	}
+
+	s.writeJSON(w, req, location.GetMetadata(s.locator))
+}
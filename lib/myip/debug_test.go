@@ -0,0 +1,70 @@
+package myip
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/location"
+)
+
+func TestDebugConfigHandler(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Host:       "example.com",
+		DebugToken: "secret-token",
+		MapsAPIKey: "secret-key",
+	})
+
+	data := []struct {
+		auth     string
+		wantCode int
+	}{
+		{auth: "", wantCode: http.StatusNotFound},
+		{auth: "Bearer wrong", wantCode: http.StatusNotFound},
+		{auth: "Bearer secret-token", wantCode: http.StatusOK},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "/debug/config", nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+
+		w := httptest.NewRecorder()
+		s.DebugConfigHandler(w, req)
+
+		if w.Code != test.wantCode {
+			t.Errorf("DebugConfigHandler(Authorization: %q) code = %d, want %d", test.auth, w.Code, test.wantCode)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		var got conf.Config
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("DebugConfigHandler() returned invalid json: %s", err)
+		}
+		if got.Host != "example.com" || got.MapsAPIKey != "[redacted]" || got.DebugToken != "[redacted]" {
+			t.Errorf("DebugConfigHandler() = %+v, want secrets redacted", got)
+		}
+	}
+}
+
+func TestDebugGeoDataHandler(t *testing.T) {
+	s := newDefaultServer(&conf.Config{Debug: true})
+
+	req := httptest.NewRequest("GET", "/debug/geodata", nil)
+	w := httptest.NewRecorder()
+	s.DebugGeoDataHandler(w, req)
+
+	var got location.Metadata
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("DebugGeoDataHandler() returned invalid json: %s", err)
+	}
+	if got.Provider != "headers" {
+		t.Errorf("DebugGeoDataHandler().Provider = %q, want %q", got.Provider, "headers")
+	}
+}
@@ -0,0 +1,51 @@
+package myip
+
+import (
+	"context"
+
+	"bramp.net/myip/lib/asn"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/whois"
+)
+
+// dedupe calls f, unless a call with the same key is already in flight, in which case it waits
+// for, and returns, that call's result instead. This stops bursts of requests from the same
+// address issuing redundant lookups. Note the in flight call uses the first caller's context.
+func (s *DefaultServer) dedupe(key string, f func() interface{}) interface{} {
+	v, _, _ := s.flight.Do(key, func() (interface{}, error) {
+		return f(), nil
+	})
+	return v
+}
+
+// lookupReverseDNS returns the reverse DNS for the address, sharing concurrent lookups, and
+// caching the result if conf.Config.DNSCacheTTL is set.
+func (s *DefaultServer) lookupReverseDNS(ctx context.Context, addr string) *dns.Response {
+	resp := s.dedupe("dns/"+addr, func() interface{} {
+		return s.cachedReverseDNS(ctx, addr)
+	}).(*dns.Response)
+
+	// Copy, as the callers may modify their response.
+	respCopy := *resp
+	return &respCopy
+}
+
+// lookupWhois returns the whois for the address, sharing concurrent lookups.
+func (s *DefaultServer) lookupWhois(ctx context.Context, addr string) *whois.Response {
+	resp := s.dedupe("whois/"+addr, func() interface{} {
+		return s.cachedWhois(ctx, addr)
+	}).(*whois.Response)
+
+	respCopy := *resp
+	return &respCopy
+}
+
+// lookupASN returns the AS announcing the address, sharing concurrent lookups.
+func (s *DefaultServer) lookupASN(ctx context.Context, addr string) *asn.Response {
+	resp := s.dedupe("asn/"+addr, func() interface{} {
+		return s.asnLookup(ctx, addr)
+	}).(*asn.Response)
+
+	respCopy := *resp
+	return &respCopy
+}
@@ -0,0 +1,44 @@
+package myip
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestLookupReverseDNSDedupes(t *testing.T) {
+	const n = 10
+
+	var calls int32
+	release := make(chan struct{})
+
+	s := newDefaultServer(&conf.Config{})
+	s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &dns.Response{Query: addr, Names: []string{"host.example.com."}}
+	}
+
+	var g errgroup.Group
+	for i := 0; i < n; i++ {
+		goLookup(&g, func() {
+			if got := s.lookupReverseDNS(context.Background(), "192.0.2.1"); got.Query != "192.0.2.1" {
+				t.Errorf("lookupReverseDNS(%q).Query = %q, want %q", "192.0.2.1", got.Query, "192.0.2.1")
+			}
+		})
+	}
+
+	// Give all the lookups time to join the first.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	g.Wait()
+
+	if calls != 1 {
+		t.Errorf("%d concurrent lookupReverseDNS made %d backend calls, want 1", n, calls)
+	}
+}
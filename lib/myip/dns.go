@@ -0,0 +1,25 @@
+package myip
+
+import (
+	"net/http"
+
+	"bramp.net/myip/lib/cache"
+)
+
+// DNSHandler returns just the reverse DNS of the client's address as JSON, without doing any of
+// the other lookups.
+func (s *DefaultServer) DNSHandler(w http.ResponseWriter, req *http.Request) {
+	host, err := s.GetRemoteAddr(req)
+	if err != nil {
+		status, resp := errResponse(err)
+		s.writeJSONStatus(w, req, status, resp)
+		return
+	}
+
+	ctx := req.Context()
+	if s.hostOverride(req) != "" {
+		ctx = cache.WithBypass(ctx)
+	}
+
+	s.writeJSON(w, req, s.lookupReverseDNS(ctx, host))
+}
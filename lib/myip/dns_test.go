@@ -0,0 +1,76 @@
+package myip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/whois"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestDNSHandler(t *testing.T) {
+	s := newDefaultServer(&conf.Config{Debug: true})
+	s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		return &dns.Response{Query: addr, Names: []string{"host.example.com."}, Status: dns.StatusNoError}
+	}
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		t.Errorf("DNSHandler() looked up whois for %q, want only reverse DNS", addr)
+		return &whois.Response{Query: addr}
+	}
+
+	data := []struct {
+		url      string
+		wantCode int
+		want     map[string]interface{}
+	}{
+		{
+			url:      "/dns",
+			wantCode: http.StatusOK,
+			want: map[string]interface{}{
+				"Query":  "192.0.2.1",
+				"Names":  []interface{}{"host.example.com."},
+				"Status": dns.StatusNoError,
+			},
+		}, {
+			url:      "/dns?host=203.0.113.1",
+			wantCode: http.StatusOK,
+			want: map[string]interface{}{
+				"Query":  "203.0.113.1",
+				"Names":  []interface{}{"host.example.com."},
+				"Status": dns.StatusNoError,
+			},
+		}, {
+			url:      "/dns?host=example.com",
+			wantCode: http.StatusBadRequest,
+			want: map[string]interface{}{
+				"error": "invalid IP address \"example.com\"",
+				"code":  codeInvalidIP,
+				"value": "example.com",
+			},
+		},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", test.url, nil)
+		w := httptest.NewRecorder()
+		s.DNSHandler(w, req)
+
+		if w.Code != test.wantCode {
+			t.Errorf("DNSHandler(%q) code = %d, want %d", test.url, w.Code, test.wantCode)
+		}
+
+		var got map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Errorf("DNSHandler(%q) returned invalid json: %s", test.url, err)
+			continue
+		}
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("DNSHandler(%q) diff: (-got +want)\n%s", test.url, diff)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package myip
+
+import (
+	"context"
+
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+)
+
+// dnsCacheSize is the maximum number of addresses whose reverse DNS is cached.
+const dnsCacheSize = 10000
+
+// newDNSCache returns the cache of reverse DNS results, or nil if conf.Config.DNSCacheTTL is unset.
+// It's never compressed (see conf.Config.CompressCache), as it holds *dns.Response values, which
+// cache.Compressed can't compress.
+func newDNSCache(config *conf.Config) *cache.Cache {
+	if config.DNSCacheTTL <= 0 {
+		return nil
+	}
+	return cache.New(dnsCacheSize, config.DNSCacheTTL)
+}
+
+// cachedReverseDNS returns the reverse DNS for the address from the cache, otherwise looking it up
+// and caching the result. Lookups that failed (e.g. timed out) are not cached, so are retried.
+func (s *DefaultServer) cachedReverseDNS(ctx context.Context, addr string) *dns.Response {
+	if s.dnsCache == nil || cache.Bypassed(ctx) {
+		return s.reverseDNS(ctx, addr)
+	}
+
+	if resp, found := s.dnsCache.Get(addr); found {
+		return resp.(*dns.Response)
+	}
+
+	resp := s.reverseDNS(ctx, addr)
+	if resp.Status == dns.StatusNoError || resp.Status == dns.StatusNXDomain {
+		s.dnsCache.Set(addr, resp)
+	}
+	return resp
+}
@@ -0,0 +1,67 @@
+package myip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+)
+
+func TestLookupReverseDNSCached(t *testing.T) {
+	data := []struct {
+		status    string
+		ttl       time.Duration
+		bypass    bool
+		wantCalls int
+	}{
+		{status: dns.StatusNoError, ttl: time.Minute, wantCalls: 1},
+		{status: dns.StatusNXDomain, ttl: time.Minute, wantCalls: 1}, // Negative results are cached
+		{status: dns.StatusServFail, ttl: time.Minute, wantCalls: 2}, // Failures are retried
+		{status: dns.StatusTimeout, ttl: time.Minute, wantCalls: 2},
+		{status: dns.StatusNoError, ttl: 0, wantCalls: 2},                         // Disabled
+		{status: dns.StatusNoError, ttl: time.Minute, bypass: true, wantCalls: 2}, // Host overrides
+	}
+
+	for _, test := range data {
+		calls := 0
+		s := newDefaultServer(&conf.Config{DNSCacheTTL: test.ttl})
+		s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+			calls++
+			return &dns.Response{Query: addr, Status: test.status}
+		}
+
+		ctx := context.Background()
+		if test.bypass {
+			ctx = cache.WithBypass(ctx)
+		}
+
+		for i := 0; i < 2; i++ {
+			if got := s.lookupReverseDNS(ctx, "192.0.2.1"); got.Status != test.status {
+				t.Errorf("lookupReverseDNS(%q).Status = %q, want %q", "192.0.2.1", got.Status, test.status)
+			}
+		}
+
+		if calls != test.wantCalls {
+			t.Errorf("2 lookupReverseDNS with status %s, ttl %s, bypass %t made %d backend calls, want %d",
+				test.status, test.ttl, test.bypass, calls, test.wantCalls)
+		}
+	}
+}
+
+func TestLookupReverseDNSCacheKeepsSecondary(t *testing.T) {
+	s := newDefaultServer(&conf.Config{DNSCacheTTL: time.Minute})
+	s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		return &dns.Response{Query: addr, Status: dns.StatusNoError}
+	}
+
+	// Callers modify their copy, which must not leak into the cache.
+	first := s.lookupReverseDNS(context.Background(), "192.0.2.1")
+	first.Secondary = &dns.Response{Query: "2001:db8::1"}
+
+	if got := s.lookupReverseDNS(context.Background(), "192.0.2.1"); got.Secondary != nil {
+		t.Errorf("lookupReverseDNS(%q).Secondary = %+v, want nil", "192.0.2.1", got.Secondary)
+	}
+}
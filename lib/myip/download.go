@@ -0,0 +1,15 @@
+package myip
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// setDownload makes the response a attachment with the filename, if the "download" query param
+// is true, so browsers save the response instead of displaying it.
+func setDownload(w http.ResponseWriter, req *http.Request, filename string) {
+	if download, _ := strconv.ParseBool(req.URL.Query().Get("download")); download {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+}
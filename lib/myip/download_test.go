@@ -0,0 +1,26 @@
+package myip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetDownload(t *testing.T) {
+	data := []struct {
+		url  string
+		want string
+	}{
+		{"/json", ""},
+		{"/json?download=0", ""},
+		{"/json?download=1", `attachment; filename="myip.json"`},
+		{"/json?download=true", `attachment; filename="myip.json"`},
+	}
+
+	for _, test := range data {
+		w := httptest.NewRecorder()
+		setDownload(w, httptest.NewRequest("GET", test.url, nil), "myip.json")
+		if got := w.Header().Get("Content-Disposition"); got != test.want {
+			t.Errorf("setDownload(%q) Content-Disposition = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
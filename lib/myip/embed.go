@@ -0,0 +1,53 @@
+package myip
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+)
+
+// embedTmpl is a self-contained fragment, with no scripts or inline styles (so it works with a
+// strict Content-Security-Policy). The embedding page can style the classes.
+var embedTmpl = template.Must(template.New("embed").Parse(
+	`<div class="myip">` +
+		`<span class="myip-addr">{{.RemoteAddr}}</span>` +
+		`{{with .Location}}{{if or .City .Region .Country}}` +
+		` <span class="myip-location">{{.City}} {{.Region}} {{.Country}}</span>` +
+		`{{end}}{{end}}` +
+		`</div>` + "\n"))
+
+// EmbedHandler returns a small HTML fragment, showing the client's IP and location, suitable for
+// embedding in another page (either in a iframe, or fetched and injected).
+func (s *DefaultServer) EmbedHandler(w http.ResponseWriter, req *http.Request) {
+	host, err := s.GetRemoteAddr(req)
+	if err != nil {
+		status, _ := errResponse(err)
+		w.WriteHeader(status)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	t := newTimings(host, s.Config.SlowLookupThreshold)
+	response := &Response{
+		RemoteAddr: host,
+	}
+	t.timed("location", func() {
+		response.Location = s.locate(req.Context(), req, host)
+	})()
+
+	// Buffer the output so we can return a error if it fails
+	var buf bytes.Buffer
+	if err := embedTmpl.Execute(&buf, response); err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// This is designed to be framed, so undo the secure middleware's FrameDeny.
+	w.Header().Del("X-Frame-Options")
+
+	s.writeCORSHeaders(w, req)
+	writeServerTiming(w, s.serverTiming(t))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
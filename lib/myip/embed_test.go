@@ -0,0 +1,42 @@
+package myip
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestEmbedHandler(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Host:       "example.com",
+		CityHeader: "X-City",
+	})
+
+	req := httptest.NewRequest("GET", "/embed", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-City", "<b>Springfield</b>")
+
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Frame-Options", "DENY") // As set by the secure middleware
+	s.EmbedHandler(w, req)
+
+	if got, want := w.Header().Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("EmbedHandler() Content-Type = %q, want %q", got, want)
+	}
+	if got := w.Header().Get("X-Frame-Options"); got != "" {
+		t.Errorf("EmbedHandler() X-Frame-Options = %q, want none", got)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, `<span class="myip-addr">192.0.2.1</span>`) {
+		t.Errorf("EmbedHandler() = %q, want it to contain the address", body)
+	}
+	if !strings.Contains(body, "&lt;b&gt;Springfield&lt;/b&gt;") {
+		t.Errorf("EmbedHandler() = %q, want the city escaped", body)
+	}
+	if strings.Contains(body, "<html") || strings.Contains(body, "<script") {
+		t.Errorf("EmbedHandler() = %q, want only a fragment", body)
+	}
+}
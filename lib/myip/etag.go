@@ -0,0 +1,57 @@
+package myip
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// responseETag returns a weak ETag for the JSON response. The per request fields (such as the
+// RequestID, or the source port of each new connection) are excluded, so polling from the same
+// address gets the same ETag while the lookups return the same results. The fields and callback
+// are those requested, as they change the body.
+func responseETag(response *Response, fields map[string]bool, callback string) string {
+	stable := *response
+	stable.RequestID = ""
+	stable.RemoteAddrPort = 0
+	stable.Timings = nil
+
+	var obj interface{} = &stable
+	if fields != nil {
+		var err error
+		if obj, err = selectFields(&stable, fields); err != nil {
+			return ""
+		}
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	h.Write([]byte(callback))
+	h.Write([]byte{0})
+	h.Write(b)
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// notModified sets the ETag header, and returns true if it matches the request's If-None-Match,
+// in which case the caller should write a 304 Not Modified. Weak comparison is used, as these are
+// weak ETags.
+func notModified(w http.ResponseWriter, req *http.Request, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+
+	for _, match := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimSpace(match)
+		if match == "*" || strings.TrimPrefix(match, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package myip
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/whois"
+)
+
+func TestJSONHandlerETag(t *testing.T) {
+	s := newSlowServer(&conf.Config{}, 0, 0, 0)
+
+	port := 1000
+	get := func(requestID, ifNoneMatch string) *httptest.ResponseRecorder {
+		port++ // Each request is from a new connection
+		req := httptest.NewRequest("GET", "/json", nil)
+		req.RemoteAddr = "192.0.2.1:" + strconv.Itoa(port)
+		req.Header.Set("Origin", "https://"+req.Host)
+		req.Header.Set("X-Request-Id", requestID)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		s.JSONHandler(w, req)
+		return w
+	}
+
+	first := get("request-1", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("JSONHandler() = (%d, ETag %q), want (%d, a ETag)", first.Code, etag, http.StatusOK)
+	}
+
+	// A different request ID and source port, but the same lookups.
+	if got := get("request-2", "").Header().Get("ETag"); got != etag {
+		t.Errorf("JSONHandler() from the same address ETag = %q, want %q", got, etag)
+	}
+
+	w := get("request-3", etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("JSONHandler(If-None-Match: %s) = (%d, %q), want (%d, %q)", etag, w.Code, w.Body.String(), http.StatusNotModified, "")
+	}
+	if got, want := w.Header().Get("Access-Control-Allow-Origin"), first.Header().Get("Access-Control-Allow-Origin"); got != want || got == "" {
+		t.Errorf("JSONHandler(If-None-Match: %s) Access-Control-Allow-Origin = %q, want %q", etag, got, want)
+	}
+
+	// The whois changed, so the body did too.
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		return &whois.Response{Query: addr, Body: "OrgName: Changed"}
+	}
+	w = get("request-4", etag)
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("JSONHandler(If-None-Match: %s) after a change = (%d, %q), want %d with a body", etag, w.Code, w.Body.String(), http.StatusOK)
+	}
+	if got := w.Header().Get("ETag"); got == etag || got == "" {
+		t.Errorf("JSONHandler() after a change ETag = %q, want a new ETag", got)
+	}
+}
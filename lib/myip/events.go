@@ -0,0 +1,100 @@
+package myip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"bramp.net/myip/lib/ua"
+)
+
+// event is a single Server-Sent Event.
+type event struct {
+	name string
+	data interface{}
+}
+
+// writeEvent writes the event, and flushes it to the client.
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, e event) error {
+	data, err := json.Marshal(e.data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// EventsHandler streams the lookups as Server-Sent Events, each sent as soon as it completes.
+// The "ip" event is sent first, then one event per lookup (named "dns", "whois", "asn", "location"
+// or "ua"), and finally a "done" event. The lookups follow the same rules as MyIPHandler, see
+// planLookups, and a lookup that timed out has null data.
+func (s *DefaultServer) EventsHandler(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	host, err := s.GetRemoteAddr(req)
+	if err != nil {
+		status, resp := errResponse(err)
+		s.writeJSONStatus(w, req, status, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	s.writeCORSHeaders(w, req)
+
+	if err := writeEvent(w, flusher, event{"ip", host}); err != nil {
+		return
+	}
+
+	plan := s.planLookups(req, host)
+	ctx, lookups := plan.ctx, plan.lookups
+
+	var funcs []func() event
+	timed := func(name string, f func(ctx context.Context) interface{}) {
+		funcs = append(funcs, func() event { return event{name, s.withLookupTimeout(ctx, f)} })
+	}
+	if lookups[lookupDNS] {
+		timed(lookupDNS, func(ctx context.Context) interface{} { return s.lookupReverseDNS(ctx, host) })
+	}
+	if lookups[lookupWhois] {
+		timed(lookupWhois, func(ctx context.Context) interface{} { return s.lookupWhois(ctx, host) })
+	}
+	if lookups[lookupASN] {
+		timed(lookupASN, func(ctx context.Context) interface{} { return s.lookupASN(ctx, host) })
+	}
+	if lookups[lookupLocation] {
+		timed(lookupLocation, func(ctx context.Context) interface{} { return s.locate(ctx, req, host) })
+	}
+	if useragent := req.Header.Get("User-Agent"); lookups[lookupUA] && useragent != "" {
+		funcs = append(funcs, func() event { return event{lookupUA, ua.DetermineUA(useragent)} })
+	}
+
+	// Buffered so the lookups never block, even if the client has gone away.
+	events := make(chan event, len(funcs))
+	for _, f := range funcs {
+		go func(f func() event) {
+			events <- f()
+		}(f)
+	}
+
+	for range funcs {
+		select {
+		case e := <-events:
+			if err := writeEvent(w, flusher, e); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return // The client disconnected
+		}
+	}
+
+	writeEvent(w, flusher, event{"done", nil})
+}
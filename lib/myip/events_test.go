@@ -0,0 +1,123 @@
+package myip
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/whois"
+)
+
+func TestEventsHandler(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Debug:         true,
+		CountryHeader: "X-Country",
+	})
+
+	req := httptest.NewRequest("GET", "/events?host=192.0.2.1&include=location", nil)
+	req.Header.Set("X-Country", "GB")
+	w := httptest.NewRecorder()
+	s.EventsHandler(w, req)
+
+	if got, want := w.Header().Get("Content-Type"), "text/event-stream"; got != want {
+		t.Errorf("EventsHandler() Content-Type = %q, want %q", got, want)
+	}
+
+	body := w.Body.String()
+	want := []string{
+		"event: ip\ndata: \"192.0.2.1\"\n\n",
+		"event: location\ndata: {",
+		"event: done\ndata: null\n\n",
+	}
+	last := -1
+	for _, e := range want {
+		i := strings.Index(body, e)
+		if i <= last {
+			t.Errorf("EventsHandler() = %q, want %q after the previous event", body, e)
+		}
+		last = i
+	}
+	if strings.Contains(body, "event: whois") {
+		t.Errorf("EventsHandler() = %q, want no whois event", body)
+	}
+}
+
+func TestEventsHandlerHostOverride(t *testing.T) {
+	var dnsCalls, whoisCalls int32
+	s := newDefaultServer(&conf.Config{
+		Debug:         true,
+		DNSCacheTTL:   time.Minute,
+		WhoisCacheTTL: time.Minute,
+		LookupTimeout: 20 * time.Millisecond,
+	})
+	s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		atomic.AddInt32(&dnsCalls, 1)
+		return &dns.Response{Query: addr, Names: []string{"fake.example.com."}}
+	}
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		atomic.AddInt32(&whoisCalls, 1)
+		<-ctx.Done() // A hung whois server
+		return &whois.Response{Query: addr, Error: ctx.Err().Error()}
+	}
+
+	req := httptest.NewRequest("GET", "/events?host=8.8.8.8&include=dns,whois", nil)
+	w := httptest.NewRecorder()
+	s.EventsHandler(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event: whois\ndata: null\n\n") {
+		t.Errorf("EventsHandler() = %q, want the hung whois to time out", body)
+	}
+	if !strings.Contains(body, "event: done\n") {
+		t.Errorf("EventsHandler() = %q, want a done event", body)
+	}
+
+	// The overridden host's lookups were kept out of the shared caches.
+	s.lookupReverseDNS(context.Background(), "8.8.8.8")
+	if got := atomic.LoadInt32(&dnsCalls); got != 2 {
+		t.Errorf("lookupReverseDNS(%q) after /events?host= made %d backend calls, want 2", "8.8.8.8", got)
+	}
+}
+
+func TestEventsHandlerPrivate(t *testing.T) {
+	s := newSlowServer(&conf.Config{Debug: true}, 0, 0, 0)
+
+	req := httptest.NewRequest("GET", "/events?host=10.0.0.1", nil)
+	w := httptest.NewRecorder()
+	s.EventsHandler(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event: dns\n") {
+		t.Errorf("EventsHandler() = %q, want a dns event", body)
+	}
+	for _, name := range []string{lookupWhois, lookupASN, lookupLocation} {
+		if strings.Contains(body, "event: "+name+"\n") {
+			t.Errorf("EventsHandler() = %q, want no %s event for a private address", body, name)
+		}
+	}
+}
+
+func TestEventsHandlerThrottled(t *testing.T) {
+	s := newSlowServer(&conf.Config{EnrichmentRate: 0.001, EnrichmentBurst: 1}, 0, 0, 0)
+
+	get := func() string {
+		req := httptest.NewRequest("GET", "/events?include=dns,location", nil)
+		req.RemoteAddr = "203.0.113.1:1234"
+		w := httptest.NewRecorder()
+		s.EventsHandler(w, req)
+		return w.Body.String()
+	}
+
+	if body := get(); !strings.Contains(body, "event: dns\n") {
+		t.Errorf("EventsHandler() = %q, want a dns event", body)
+	}
+	body := get()
+	if strings.Contains(body, "event: dns\n") || !strings.Contains(body, "event: location\n") {
+		t.Errorf("EventsHandler() when throttled = %q, want just the location event", body)
+	}
+}
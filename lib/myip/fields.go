@@ -0,0 +1,68 @@
+package myip
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// fieldLookups maps each (lower cased) Response field to the lookups needed to populate it. Fields
+// not listed need no lookups.
+var fieldLookups = map[string][]string{
+	"remoteaddrreverse": {lookupDNS},
+	"remoteaddrwhois":   {lookupWhois},
+	"asn":               {lookupASN},
+	"network":           {lookupWhois, lookupASN},
+	"orglogourl":        {lookupDNS, lookupWhois},
+	"location":          {lookupLocation},
+	"nearestix":         {lookupLocation},
+	"useragent":         {lookupUA},
+}
+
+// requestedFields returns the set of (lower cased) top-level fields chosen with the "fields" query
+// parameter, e.g. "?fields=RemoteAddr,Location", or nil if all fields were requested.
+func requestedFields(req *http.Request) map[string]bool {
+	list := splitList(req.URL.Query().Get("fields"))
+	if len(list) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]bool)
+	for _, field := range list {
+		fields[strings.ToLower(field)] = true
+	}
+	return fields
+}
+
+// fieldsLookups returns the set of lookups needed to populate the fields.
+func fieldsLookups(fields map[string]bool) map[string]bool {
+	needed := make(map[string]bool)
+	for field := range fields {
+		for _, lookup := range fieldLookups[field] {
+			needed[lookup] = true
+		}
+	}
+	return needed
+}
+
+// selectFields returns the object's JSON encoding, with only the requested top-level fields. Field
+// names are matched case insensitively, and unknown names are ignored.
+func selectFields(obj interface{}, fields map[string]bool) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage)
+	for name, value := range all {
+		if fields[strings.ToLower(name)] {
+			selected[name] = value
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,56 @@
+package myip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/whois"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestJSONHandlerFields(t *testing.T) {
+	data := []struct {
+		url       string
+		wantKeys  []string
+		wantWhois int // Number of whois lookups
+	}{
+		{url: "/json?fields=RemoteAddr", wantKeys: []string{"RemoteAddr"}, wantWhois: 0},
+		{url: "/json?fields=remoteaddr,Bogus", wantKeys: []string{"RemoteAddr"}, wantWhois: 0},
+		{url: "/json?fields=RemoteAddr,RemoteAddrWhois", wantKeys: []string{"RemoteAddr", "RemoteAddrWhois"}, wantWhois: 1},
+	}
+
+	for _, test := range data {
+		calls := 0
+		s := newDefaultServer(&conf.Config{})
+		s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+			calls++
+			return &whois.Response{Query: addr}
+		}
+
+		req := httptest.NewRequest("GET", test.url, nil)
+		w := httptest.NewRecorder()
+		s.JSONHandler(w, req)
+
+		var got map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("JSONHandler(%q) returned invalid json: %s", test.url, err)
+		}
+
+		var keys []string
+		for key := range got {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if diff := pretty.Compare(keys, test.wantKeys); diff != "" {
+			t.Errorf("JSONHandler(%q) fields diff: (-got +want)\n%s", test.url, diff)
+		}
+		if calls != test.wantWhois {
+			t.Errorf("JSONHandler(%q) made %d whois lookups, want %d", test.url, calls, test.wantWhois)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package myip
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultMaxForwardedHops is used when conf.Config.MaxForwardedHops is not set.
+const defaultMaxForwardedHops = 50
+
+// parseForwarded splits a comma separated header value (such as X-Forwarded-For) into its hops,
+// parsing at most max entries. Returns true if there were more entries, which were not parsed.
+func parseForwarded(value string, max int) (hops []string, truncated bool) {
+	for entries := 0; value != ""; entries++ {
+		if entries == max {
+			return hops, true
+		}
+
+		hop := value
+		if i := strings.IndexByte(value, ','); i >= 0 {
+			hop, value = value[:i], value[i+1:]
+		} else {
+			value = ""
+		}
+
+		if hop = cleanHop(hop); hop != "" {
+			hops = append(hops, hop)
+		}
+	}
+	return hops, false
+}
+
+// cleanHop extracts the address from a single forwarded entry, tolerating the common ways
+// proxies decorate them, such as `for="[2001:db8::1]:4711";proto=https` or "192.0.2.1:8080".
+// Entries that still aren't a address are returned as is, to be rejected later.
+func cleanHop(hop string) string {
+	// Drop trailing parameters, e.g. ";proto=https"
+	if i := strings.IndexByte(hop, ';'); i >= 0 {
+		hop = hop[:i]
+	}
+	hop = strings.TrimSpace(hop)
+
+	if len(hop) > 4 && strings.EqualFold(hop[:4], "for=") {
+		hop = hop[4:]
+	}
+	hop = strings.Trim(hop, `"`)
+
+	if net.ParseIP(hop) != nil {
+		return hop
+	}
+
+	// Remove any brackets and port, e.g. "[2001:db8::1]:4711" or "192.0.2.1:8080"
+	if strings.HasPrefix(hop, "[") {
+		if i := strings.IndexByte(hop, ']'); i >= 0 {
+			return hop[1:i]
+		}
+	}
+	if host, _, err := net.SplitHostPort(hop); err == nil && net.ParseIP(host) != nil {
+		return host
+	}
+
+	return hop
+}
+
+// ipHeaders returns the headers that may contain the client's address, in priority order. The
+// conf.Config.IPHeaders are preferred over the legacy conf.Config.IPHeader.
+func (s *DefaultServer) ipHeaders() []string {
+	if len(s.Config.IPHeaders) > 0 {
+		return s.Config.IPHeaders
+	}
+	if s.Config.IPHeader != "" {
+		return []string{s.Config.IPHeader}
+	}
+	return nil
+}
+
+// defaultTrustedSecretHeader is used when conf.Config.TrustedSecretHeader is not set.
+const defaultTrustedSecretHeader = "X-Proxy-Secret"
+
+// trustedSecretHeader returns the header with the conf.Config.TrustedHeaderSecret, or "" if no
+// secret is configured.
+func (s *DefaultServer) trustedSecretHeader() string {
+	if s.Config.TrustedHeaderSecret == "" {
+		return ""
+	}
+	if s.Config.TrustedSecretHeader != "" {
+		return s.Config.TrustedSecretHeader
+	}
+	return defaultTrustedSecretHeader
+}
+
+// fromTrustedProxy returns true if the ipHeaders of this request may be honored, that is either no
+// conf.Config.TrustedHeaderSecret is configured, or the request has it.
+func (s *DefaultServer) fromTrustedProxy(req *http.Request) bool {
+	header := s.trustedSecretHeader()
+	if header == "" {
+		return true
+	}
+	secret := req.Header.Get(header)
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(s.Config.TrustedHeaderSecret)) == 1
+}
+
+// forwardedHeader returns the first of the ipHeaders with a non-empty value in this request, or ""
+// if there are none, or the request isn't fromTrustedProxy.
+func (s *DefaultServer) forwardedHeader(req *http.Request) string {
+	if !s.fromTrustedProxy(req) {
+		return ""
+	}
+	for _, name := range s.ipHeaders() {
+		for _, value := range req.Header[textproto.CanonicalMIMEHeaderKey(name)] {
+			if strings.TrimSpace(value) != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// forwardedHops returns the hops listed in the forwardedHeader, with the client first. To bound
+// the work a malicious client can cause, at most conf.Config.MaxForwardedHops are parsed, and true
+// is returned if the header was truncated.
+func (s *DefaultServer) forwardedHops(req *http.Request) (hops []string, truncated bool) {
+	header := s.forwardedHeader(req)
+	if header == "" {
+		return nil, false
+	}
+
+	max := s.Config.MaxForwardedHops
+	if max <= 0 {
+		max = defaultMaxForwardedHops
+	}
+
+	// The header may be repeated, which is equivalent to one comma separated header.
+	for _, value := range req.Header[textproto.CanonicalMIMEHeaderKey(header)] {
+		h, t := parseForwarded(value, max-len(hops))
+		hops = append(hops, h...)
+		if t {
+			return hops, true
+		}
+	}
+	return hops, false
+}
+
+// clientHop returns the client's address from the forwarded hops. The hops are walked from the
+// right (the proxy closest to us), skipping any trusted proxies, as each hop could have been
+// spoofed by the hop after it. If every hop is trusted, or no proxies are trusted, the leftmost is
+// returned.
+func (s *DefaultServer) clientHop(hops []string) string {
+	if len(s.trustedProxies) == 0 {
+		return hops[0]
+	}
+
+	for i := len(hops) - 1; i > 0; i-- {
+		if ip := net.ParseIP(hops[i]); ip == nil || !s.isTrustedProxy(ip) {
+			return hops[i]
+		}
+	}
+	return hops[0]
+}
+
+// parseCIDRs parses the list of CIDRs (or single addresses), logging and skipping any invalid.
+func parseCIDRs(cidrs []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, 8*net.IPv4len
+				}
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Errorf("Ignoring invalid CIDR %q: %s", cidr, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isTrustedProxy returns true if the address is one of the configured TrustedProxies.
+func (s *DefaultServer) isTrustedProxy(ip net.IP) bool {
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// privateNetworks are the RFC 1918 private IPv4 networks, and the RFC 4193 unique local IPv6
+// addresses.
+var privateNetworks = parseCIDRs([]string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+})
+
+// isPrivate returns true if the address is in one of the privateNetworks. This is the same as
+// net.IP.IsPrivate, which isn't available until Go 1.17.
+func isPrivate(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublic returns true if the address is globally routable.
+func isPublic(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !isPrivate(ip)
+}
+
+// suspiciousHops checks each hop of the forwarded chain is plausible, that is a valid public
+// address (or a trusted proxy). It returns a description of each implausible hop, which may
+// indicate the header was forged.
+func (s *DefaultServer) suspiciousHops(hops []string) []string {
+	var problems []string
+	for i, hop := range hops {
+		ip := net.ParseIP(hop)
+		switch {
+		case ip == nil:
+			problems = append(problems, fmt.Sprintf("hop %d %q is not a IP address", i+1, hop))
+		case isPublic(ip) || s.isTrustedProxy(ip):
+			// Plausible
+		default:
+			problems = append(problems, fmt.Sprintf("hop %d %q is not a public address", i+1, hop))
+		}
+	}
+	return problems
+}
+
+// forwardedProtoHeader is set by TLS terminating proxies to the scheme the client used.
+const forwardedProtoHeader = "X-Forwarded-Proto"
+
+// scheme returns the scheme the client connected with, "http" or "https". The X-Forwarded-Proto
+// header is only honored if the request came directly from one of the TrustedProxies, as
+// otherwise it could have been set by the client.
+func (s *DefaultServer) scheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && s.isTrustedProxy(ip) {
+		switch proto := strings.ToLower(req.Header.Get(forwardedProtoHeader)); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+	return "http"
+}
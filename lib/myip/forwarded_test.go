@@ -0,0 +1,204 @@
+package myip
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestParseForwarded(t *testing.T) {
+	data := []struct {
+		value         string
+		max           int
+		want          []string
+		wantTruncated bool
+	}{
+		{value: "", max: 10, want: nil},
+		{value: "192.0.2.1", max: 10, want: []string{"192.0.2.1"}},
+		{value: "192.0.2.1, 198.51.100.1,203.0.113.1", max: 10, want: []string{"192.0.2.1", "198.51.100.1", "203.0.113.1"}},
+		{value: "192.0.2.1, , 203.0.113.1", max: 10, want: []string{"192.0.2.1", "203.0.113.1"}},
+		{value: "for=192.0.2.1;proto=https, 198.51.100.1:443", max: 10, want: []string{"192.0.2.1", "198.51.100.1"}},
+		{value: "192.0.2.1, 198.51.100.1, 203.0.113.1", max: 2, want: []string{"192.0.2.1", "198.51.100.1"}, wantTruncated: true},
+	}
+
+	for _, test := range data {
+		got, truncated := parseForwarded(test.value, test.max)
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("parseForwarded(%q, %d) diff: (-got +want)\n%s", test.value, test.max, diff)
+		}
+		if truncated != test.wantTruncated {
+			t.Errorf("parseForwarded(%q, %d) truncated = %v, want %v", test.value, test.max, truncated, test.wantTruncated)
+		}
+	}
+}
+
+func TestCleanHop(t *testing.T) {
+	data := []struct {
+		hop  string
+		want string
+	}{
+		{hop: "192.0.2.1", want: "192.0.2.1"},
+		{hop: " 192.0.2.1 ", want: "192.0.2.1"},
+		{hop: "2001:db8::1", want: "2001:db8::1"},
+		{hop: "192.0.2.1;proto=https", want: "192.0.2.1"},
+		{hop: "for=192.0.2.1;proto=https;by=203.0.113.43", want: "192.0.2.1"},
+		{hop: `For="192.0.2.1"`, want: "192.0.2.1"},
+		{hop: `for="[2001:db8::1]:4711"`, want: "2001:db8::1"},
+		{hop: "[2001:db8::1]", want: "2001:db8::1"},
+		{hop: "192.0.2.1:8080", want: "192.0.2.1"},
+		{hop: "unknown", want: "unknown"},
+		{hop: "for=_hidden", want: "_hidden"},
+		{hop: ";proto=https", want: ""},
+	}
+
+	for _, test := range data {
+		if got := cleanHop(test.hop); got != test.want {
+			t.Errorf("cleanHop(%q) = %q, want %q", test.hop, got, test.want)
+		}
+	}
+}
+
+func TestForwardedHopsPathological(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		IPHeader: "X-Forwarded-For",
+	})
+
+	// A client sending a enormous chain, only the first few should be parsed.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", strings.Repeat("192.0.2.1, ", 100000)+"203.0.113.1")
+	req.Header.Add("X-Forwarded-For", "198.51.100.1")
+
+	hops, truncated := s.forwardedHops(req)
+	if len(hops) != defaultMaxForwardedHops || !truncated {
+		t.Errorf("forwardedHops() = %d hops (truncated %v), want %d hops (truncated true)", len(hops), truncated, defaultMaxForwardedHops)
+	}
+
+	got, err := s.GetRemoteAddr(req)
+	if err != nil || got != "192.0.2.1" {
+		t.Errorf("GetRemoteAddr() = (%q, %v), want (%q, nil)", got, err, "192.0.2.1")
+	}
+}
+
+func TestClientHop(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		TrustedProxies: []string{"10.0.0.0/8", "198.51.100.0/24"},
+	})
+
+	data := []struct {
+		hops []string
+		want string
+	}{
+		{hops: []string{"203.0.113.1"}, want: "203.0.113.1"},
+		{hops: []string{"203.0.113.1", "198.51.100.1", "10.0.0.1"}, want: "203.0.113.1"},
+		// The client spoofed the first hop, so the untrusted hop that reached our proxies is the
+		// client.
+		{hops: []string{"192.0.2.66", "203.0.113.1", "10.0.0.1"}, want: "203.0.113.1"},
+		// A untrusted proxy, so we can't trust anything it says.
+		{hops: []string{"203.0.113.1", "192.0.2.1", "198.51.100.1"}, want: "192.0.2.1"},
+		{hops: []string{"10.0.0.3", "10.0.0.2", "10.0.0.1"}, want: "10.0.0.3"},
+		{hops: []string{"203.0.113.1", "unknown", "10.0.0.1"}, want: "unknown"},
+	}
+
+	for _, test := range data {
+		if got := s.clientHop(test.hops); got != test.want {
+			t.Errorf("clientHop(%q) = %q, want %q", test.hops, got, test.want)
+		}
+	}
+
+	// Without any trusted proxies, the first is the client.
+	s = newDefaultServer(&conf.Config{})
+	if got := s.clientHop([]string{"203.0.113.1", "198.51.100.1"}); got != "203.0.113.1" {
+		t.Errorf("clientHop() with no trusted proxies = %q, want %q", got, "203.0.113.1")
+	}
+}
+
+func TestIsPrivate(t *testing.T) {
+	data := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"::ffff:192.168.1.1", true},
+		{"fd00::1", true},
+		{"fc00::1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+		{"127.0.0.1", false},
+	}
+
+	for _, test := range data {
+		if got := isPrivate(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("isPrivate(%q) = %t, want %t", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestSuspiciousHops(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+
+	data := []struct {
+		hops []string
+		want []string
+	}{
+		{hops: []string{"203.0.113.1", "198.51.100.1"}, want: nil},
+		{hops: []string{"203.0.113.1", "10.1.2.3", "192.168.1.1"}, want: nil}, // Trusted proxies
+		{hops: []string{"203.0.113.1", "172.16.0.1"}, want: []string{`hop 2 "172.16.0.1" is not a public address`}},
+		{hops: []string{"127.0.0.1", "203.0.113.1"}, want: []string{`hop 1 "127.0.0.1" is not a public address`}},
+		{hops: []string{"unknown", "203.0.113.1"}, want: []string{`hop 1 "unknown" is not a IP address`}},
+	}
+
+	for _, test := range data {
+		got := s.suspiciousHops(test.hops)
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("suspiciousHops(%q) diff: (-got +want)\n%s", test.hops, diff)
+		}
+	}
+}
+
+func TestScheme(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+
+	data := []struct {
+		name       string
+		tls        bool
+		remoteAddr string
+		proto      string // Value of X-Forwarded-Proto
+		want       string
+	}{
+		{name: "direct tls", tls: true, remoteAddr: "203.0.113.1:1234", want: "https"},
+		{name: "direct plain", remoteAddr: "203.0.113.1:1234", want: "http"},
+		{name: "proxied https", remoteAddr: "10.0.0.1:1234", proto: "https", want: "https"},
+		{name: "proxied http", remoteAddr: "10.0.0.1:1234", proto: "http", want: "http"},
+		{name: "proxied upper case", remoteAddr: "10.0.0.1", proto: "HTTPS", want: "https"},
+		{name: "proxied garbage", remoteAddr: "10.0.0.1:1234", proto: "gopher", want: "http"},
+		{name: "untrusted proxy", remoteAddr: "203.0.113.1:1234", proto: "https", want: "http"},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "/json", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		if test.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", test.proto)
+		}
+
+		if got := s.scheme(req); got != test.want {
+			t.Errorf("scheme(%s) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
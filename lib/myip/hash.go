@@ -0,0 +1,20 @@
+package myip
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+)
+
+// hashIP returns the hex encoded HMAC-SHA256 of the address, keyed with the secret. The address
+// is canonicalised first, so different spellings of the same address have the same hash.
+func hashIP(secret, addr string) string {
+	if ip := net.ParseIP(addr); ip != nil {
+		addr = ip.String()
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(addr))
+	return hex.EncodeToString(mac.Sum(nil))
+}
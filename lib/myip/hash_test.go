@@ -0,0 +1,16 @@
+package myip
+
+import "testing"
+
+func TestHashIP(t *testing.T) {
+	a := hashIP("secret", "2001:db8::1")
+	if len(a) != 64 {
+		t.Errorf("hashIP(%q, %q) = %q, want 64 hex characters", "secret", "2001:db8::1", a)
+	}
+	if b := hashIP("secret", "2001:0db8:0:0::1"); a != b {
+		t.Errorf("hashIP(%q, %q) = %q, want %q", "secret", "2001:0db8:0:0::1", b, a)
+	}
+	if b := hashIP("other", "2001:db8::1"); a == b {
+		t.Errorf("hashIP(%q, %q) = %q, want it to differ from a different secret", "other", "2001:db8::1", b)
+	}
+}
@@ -0,0 +1,17 @@
+package myip
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// writeBody writes the status and body, with the body's Content-Length. For a HEAD request just
+// the headers are written, so they are the same as the GET would have. That means the lookups
+// still run, so the length is accurate.
+func writeBody(w http.ResponseWriter, req *http.Request, status int, body []byte) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if req.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
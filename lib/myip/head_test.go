@@ -0,0 +1,53 @@
+package myip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+)
+
+func TestHead(t *testing.T) {
+	config := &conf.Config{}
+	r := mux.NewRouter()
+	register(r, newSlowServer(config, 0, 0, 0), config)
+
+	data := []struct {
+		path            string
+		wantContentType string
+	}{
+		{path: "/json", wantContentType: "application/json"},
+		{path: "/xml", wantContentType: "application/xml"},
+		{path: "/yaml", wantContentType: "application/yaml"},
+		{path: "/ip", wantContentType: "text/plain"},
+	}
+
+	for _, test := range data {
+		get := httptest.NewRecorder()
+		r.ServeHTTP(get, httptest.NewRequest("GET", "https://ip.example.com"+test.path, nil))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("HEAD", "https://ip.example.com"+test.path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("HEAD %s = %d, want %d", test.path, w.Code, http.StatusOK)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("HEAD %s body = %q, want empty", test.path, w.Body)
+		}
+		if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+			t.Errorf("HEAD %s Content-Type = %q, want %q", test.path, got, test.wantContentType)
+		}
+
+		length, err := strconv.Atoi(w.Header().Get("Content-Length"))
+		if err != nil || length == 0 {
+			t.Errorf("HEAD %s Content-Length = %q, want the length of the body", test.path, w.Header().Get("Content-Length"))
+		}
+		if test.path == "/ip" && length != get.Body.Len() {
+			t.Errorf("HEAD %s Content-Length = %d, want %d (as GET)", test.path, length, get.Body.Len())
+		}
+	}
+}
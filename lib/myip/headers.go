@@ -0,0 +1,39 @@
+package myip
+
+import (
+	"net/http"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+)
+
+// headerSize returns the size of the headers, as they were sent on the wire with HTTP/1.1.
+func headerSize(header http.Header) int {
+	size := 0
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(": ") + len(value) + len("\r\n")
+		}
+	}
+	return size
+}
+
+// MaxHeaderBytes returns middleware that rejects requests whose headers are larger than
+// conf.Config.MaxHeaderBytes, with a 431 Request Header Fields Too Large.
+func MaxHeaderBytes(config *conf.Config) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		if config.MaxHeaderBytes <= 0 {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if headerSize(req.Header) > config.MaxHeaderBytes {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusRequestHeaderFieldsTooLarge)
+				w.Write([]byte("request headers too large\n"))
+				return
+			}
+			h.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package myip
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+)
+
+func TestHeaderSize(t *testing.T) {
+	header := http.Header{
+		"Accept":     {"*/*"},
+		"User-Agent": {"curl/7.64.1", "again"},
+	}
+	want := len("Accept: */*\r\n") + len("User-Agent: curl/7.64.1\r\n") + len("User-Agent: again\r\n")
+	if got := headerSize(header); got != want {
+		t.Errorf("headerSize(%v) = %d, want %d", header, got, want)
+	}
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{MaxHeaderBytes: 1024})
+
+	req := httptest.NewRequest("GET", "https://ip.example.com/ip", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET %s with small headers = %d, want %d", req.URL, w.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest("GET", "https://ip.example.com/ip", nil)
+	for i := 0; i < 100; i++ {
+		req.Header.Set(fmt.Sprintf("X-Header-%d", i), strings.Repeat("a", 100))
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("GET %s with %d bytes of headers = %d, want %d", req.URL, headerSize(req.Header), w.Code, http.StatusRequestHeaderFieldsTooLarge)
+	}
+}
@@ -0,0 +1,47 @@
+package myip
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// healthzPath is the path of the health check, used by load balancers.
+const healthzPath = "/healthz"
+
+// HealthzHandler returns 200 OK, without doing any lookups, so is suitable as a liveness probe.
+func (s *DefaultServer) HealthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}
+
+// exempt returns middleware which applies mw to all requests, except for the given paths.
+func exempt(mw mux.MiddlewareFunc, paths ...string) mux.MiddlewareFunc {
+	skip := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		skip[path] = true
+	}
+
+	return func(h http.Handler) http.Handler {
+		wrapped := mw(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				h.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithoutHealthz returns a handler that serves health checks with next, and everything else with h.
+// This allows the health checks to skip h, for example to keep them out of the access log.
+func WithoutHealthz(h, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == healthzPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,43 @@
+package myip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+)
+
+func TestHealthz(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{}) // Not debug, so the secure middleware redirects to HTTPS
+
+	data := []struct {
+		path     string
+		wantCode int
+	}{
+		{path: "/healthz", wantCode: http.StatusOK},
+		{path: "/ip", wantCode: http.StatusMovedPermanently},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "http://ip.example.com"+test.path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != test.wantCode {
+			t.Errorf("GET %s code = %d, want %d", test.path, w.Code, test.wantCode)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "http://ip.example.com/healthz", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got, want := w.Body.String(), "ok"; got != want {
+		t.Errorf("GET /healthz = %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("GET /healthz Content-Type = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package myip
+
+import (
+	"time"
+
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+)
+
+const (
+	// countryHistorySize is the maximum number of clients remembered.
+	countryHistorySize = 100000
+
+	// countryHistoryTTL is how long a client's country is remembered for.
+	countryHistoryTTL = 24 * time.Hour
+)
+
+// countryHistory remembers the last country each client was seen in. It is best effort, as clients
+// are forgotten after countryHistoryTTL, or sooner if more than countryHistorySize are seen.
+type countryHistory struct {
+	seen *cache.Cache
+}
+
+func newCountryHistory(config *conf.Config) *countryHistory {
+	return &countryHistory{
+		seen: cache.New(countryHistorySize, countryHistoryTTL),
+	}
+}
+
+// see records the client was seen in this country, returning the previous country if it changed.
+func (h *countryHistory) see(token, country string) (previous string, changed bool) {
+	if last, found := h.seen.Get(token); found {
+		previous = last.(string)
+	}
+	h.seen.Set(token, country)
+
+	return previous, previous != "" && previous != country
+}
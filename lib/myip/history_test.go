@@ -0,0 +1,30 @@
+package myip
+
+import (
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestCountryHistorySee(t *testing.T) {
+	h := newCountryHistory(&conf.Config{})
+
+	data := []struct {
+		token, country string
+		wantPrevious   string
+		wantChanged    bool
+	}{
+		{token: "a", country: "US", wantPrevious: "", wantChanged: false},
+		{token: "a", country: "US", wantPrevious: "US", wantChanged: false},
+		{token: "b", country: "GB", wantPrevious: "", wantChanged: false},
+		{token: "a", country: "FR", wantPrevious: "US", wantChanged: true},
+		{token: "a", country: "FR", wantPrevious: "FR", wantChanged: false},
+	}
+
+	for _, test := range data {
+		previous, changed := h.see(test.token, test.country)
+		if previous != test.wantPrevious || changed != test.wantChanged {
+			t.Errorf("see(%q, %q) = (%q, %v), want (%q, %v)", test.token, test.country, previous, changed, test.wantPrevious, test.wantChanged)
+		}
+	}
+}
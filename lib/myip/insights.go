@@ -15,6 +15,7 @@
 package myip
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
@@ -31,7 +32,7 @@ func addressFamily(addr string) string {
 	return "Unknown"
 }
 
-func addInsights(req *http.Request, resp *Response) *Response {
+func (s *DefaultServer) addInsights(req *http.Request, resp *Response) *Response {
 	resp.Insights = make(map[string]string)
 
 	if s := req.Header.Get("Via"); strings.Contains(s, "Chrome-Compression-Proxy") {
@@ -43,5 +44,14 @@ func addInsights(req *http.Request, resp *Response) *Response {
 		resp.Insights["AddressMismatch"] = actual
 	}
 
+	hops, truncated := s.forwardedHops(req)
+	if truncated {
+		resp.Insights["ForwardedTruncated"] = fmt.Sprintf("only the first %d entries of %s were parsed", len(hops), s.forwardedHeader(req))
+	}
+
+	if problems := s.suspiciousHops(hops); len(problems) > 0 {
+		resp.Insights["ForwardedSuspicious"] = strings.Join(problems, "; ")
+	}
+
 	return resp
 }
@@ -0,0 +1,18 @@
+package myip
+
+import "net/http"
+
+// IPHandler returns just the client's address as plain text, without doing any lookups.
+func (s *DefaultServer) IPHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+
+	host, err := s.GetRemoteAddr(req)
+	if err != nil {
+		status, _ := errResponse(err)
+		writeBody(w, req, status, []byte(err.Error()+"\n"))
+		return
+	}
+
+	writeBody(w, req, http.StatusOK, []byte(host+"\n"))
+}
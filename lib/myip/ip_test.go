@@ -0,0 +1,43 @@
+package myip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestIPHandler(t *testing.T) {
+	data := []struct {
+		ipHeader string // Configured IPHeader
+		header   string // Value of X-Forwarded-For
+		want     string
+		wantCode int
+	}{
+		{ipHeader: "", header: "", want: "192.0.2.1\n", wantCode: http.StatusOK},
+		{ipHeader: "", header: "203.0.113.1", want: "192.0.2.1\n", wantCode: http.StatusOK},
+		{ipHeader: "X-Forwarded-For", header: "203.0.113.1, 198.51.100.1", want: "203.0.113.1\n", wantCode: http.StatusOK},
+		{ipHeader: "X-Forwarded-For", header: "", want: "192.0.2.1\n", wantCode: http.StatusOK},
+		{ipHeader: "X-Forwarded-For", header: "garbage", want: "invalid IP address \"garbage\"\n", wantCode: http.StatusBadRequest},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{IPHeader: test.ipHeader})
+
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if test.header != "" {
+			req.Header.Set("X-Forwarded-For", test.header)
+		}
+		w := httptest.NewRecorder()
+		s.IPHandler(w, req)
+
+		if w.Code != test.wantCode || w.Body.String() != test.want {
+			t.Errorf("IPHandler(IPHeader: %q, X-Forwarded-For: %q) = (%d, %q), want (%d, %q)", test.ipHeader, test.header, w.Code, w.Body, test.wantCode, test.want)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("IPHandler() Cache-Control = %q, want %q", got, "no-store")
+		}
+	}
+}
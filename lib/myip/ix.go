@@ -0,0 +1,38 @@
+package myip
+
+import (
+	"bramp.net/myip/lib/ix"
+	"bramp.net/myip/lib/location"
+)
+
+// NearestIX is the nearest major internet exchange to the client.
+type NearestIX struct {
+	Name    string
+	City    string
+	Country string
+
+	Distance float64        // In Units
+	Units    location.Units // The same as the Location's
+}
+
+// newNearestIX returns the nearest IX to the location, or nil if the location's coordinates are
+// not precise enough to tell.
+func newNearestIX(loc *location.Response) *NearestIX {
+	if loc == nil || (loc.Lat == 0 && loc.Long == 0) || loc.CentroidFallback {
+		return nil
+	}
+
+	exchange, km := ix.Nearest(loc.Lat, loc.Long)
+	if exchange == nil {
+		return nil
+	}
+
+	return &NearestIX{
+		Name:    exchange.Name,
+		City:    exchange.City,
+		Country: exchange.Country,
+
+		Distance: loc.Units.FromKm(km),
+		Units:    loc.Units,
+	}
+}
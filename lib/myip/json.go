@@ -1,43 +1,256 @@
 package myip
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 )
 
 // ErrResponse is returned in the case of a error.
 type ErrResponse struct {
-	Error string `json:"error,omitempty"`
+	XMLName xml.Name `json:"-" xml:"Error" yaml:"-"`
+
+	Error string `json:"error,omitempty" xml:",chardata" yaml:"error,omitempty"`
+
+	// Code identifies the type of error, e.g. "INVALID_IP"
+	Code string `json:"code,omitempty" xml:"code,attr,omitempty" yaml:"code,omitempty"`
+
+	// Value is the offending input, if any
+	Value string `json:"value,omitempty" xml:"value,attr,omitempty" yaml:"value,omitempty"`
 }
 
-// JSONHandler does the lookups and returns the results as a JSON object.
+// The ErrResponse.Codes.
+const (
+	codeInvalidIP       = "INVALID_IP"       // A InvalidIPError
+	codeInvalidCallback = "INVALID_CALLBACK" // The JSONP callback is not a valid name
+	codeInvalidBatch    = "INVALID_BATCH"    // The batch of addresses could not be parsed
+	codeBatchTooLarge   = "BATCH_TOO_LARGE"  // More than conf.Config.MaxBatchSize addresses
+	codeRateLimited     = "RATE_LIMITED"     // The address of a batch exceeded conf.Config.RateLimit
+)
+
+// callbackRegex matches valid JSONP callback names.
+var callbackRegex = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+
+// errResponse returns the HTTP status code and ErrResponse for this error.
+func errResponse(err error) (int, *ErrResponse) {
+	var invalid *InvalidIPError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest, &ErrResponse{
+			Error: err.Error(),
+			Code:  codeInvalidIP,
+			Value: invalid.Value,
+		}
+	}
+
+	return http.StatusInternalServerError, &ErrResponse{
+		Error: err.Error(),
+	}
+}
+
+// JSONHandler does the lookups and returns the results as a JSON object, or as JSONP if allowed
+// by conf.Config.AllowJSONP.
 func (s *DefaultServer) JSONHandler(w http.ResponseWriter, req *http.Request) {
+	s.metrics.request(formatJSON)
+
+	callback, ok := s.jsonpCallback(req)
+	if !ok {
+		// Never reflect a invalid callback, as it could be used for XSS
+		s.writeJSONStatus(w, req, http.StatusBadRequest, &ErrResponse{
+			Error: "invalid callback name",
+			Code:  codeInvalidCallback,
+		})
+		return
+	}
+
 	response, err := s.MyIPHandler(req)
-	if err != nil {
-		response = addInsights(req, response)
+	if cancelled(err) {
+		return
+	}
+	if err == nil {
+		response = s.addInsights(req, response)
 	}
 
 	if err != nil {
-		w.WriteHeader(500)
-		s.writeJSON(w, req, &ErrResponse{err.Error()})
+		s.writeJSONError(w, req, err, callback)
+		return
+	}
+
+	var meta *Meta
+	if s.Config.ResponseEnvelope {
+		meta = newMeta(response)
+	}
+
+	var data interface{} = response
+	fields := requestedFields(req)
+	if fields != nil {
+		if data, err = selectFields(response, fields); err != nil {
+			s.writeJSONError(w, req, err, callback)
+			return
+		}
+	}
+
+	writeServerTiming(w, response.serverTiming)
+	if notModified(w, req, responseETag(response, fields, callback)) {
+		s.writeCORSHeaders(w, req) // So the main site can still read the 304
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
+	setDownload(w, req, "myip.json")
+	if s.Config.ResponseEnvelope {
+		s.writeJSONPStatus(w, req, http.StatusOK, &Envelope{Meta: meta, Data: data}, callback)
+		return
+	}
+	s.writeJSONPStatus(w, req, http.StatusOK, data, callback)
+}
+
+// writeJSONError writes the error as a ErrResponse, wrapped in a Envelope (with the request ID) if
+// conf.Config.ResponseEnvelope.
+func (s *DefaultServer) writeJSONError(w http.ResponseWriter, req *http.Request, err error, callback string) {
+	status, resp := errResponse(err)
+	if s.Config.ResponseEnvelope {
+		meta := newMeta(nil)
+		meta.RequestID = req.Header.Get(requestIDHeader(s.Config))
+		s.writeJSONPStatus(w, req, status, &Envelope{Error: resp, Meta: meta}, callback)
+		return
+	}
+	s.writeJSONPStatus(w, req, status, resp, callback)
+}
+
+// jsonpCallback returns the request's "callback" query parameter, if conf.Config.AllowJSONP,
+// otherwise "". Returns false if the callback is not a valid name.
+func (s *DefaultServer) jsonpCallback(req *http.Request) (string, bool) {
+	if !s.Config.AllowJSONP {
+		return "", true
+	}
+	callback := req.URL.Query().Get("callback")
+	if callback != "" && !callbackRegex.MatchString(callback) {
+		return "", false
+	}
+	return callback, true
+}
+
+// Envelope wraps a Response (or ErrResponse) with metadata about the request, see
+// conf.Config.ResponseEnvelope.
+type Envelope struct {
+	Data  interface{}  `json:"data,omitempty"` // The Response, or just its requested fields
+	Error *ErrResponse `json:"error,omitempty"`
+	Meta  *Meta        `json:"meta"`
+}
 
-	s.writeJSON(w, req, response)
+// Meta is the metadata about the request in a Envelope.
+type Meta struct {
+	RequestID  string         `json:",omitempty"`
+	Timings    map[string]int `json:",omitempty"`
+	ServerTime time.Time
+}
+
+// newMeta returns the Meta for this response, moving the response's metadata into it. The
+// response may be nil.
+func newMeta(response *Response) *Meta {
+	meta := &Meta{
+		ServerTime: time.Now().UTC(),
+	}
+	if response != nil {
+		meta.RequestID, response.RequestID = response.RequestID, ""
+		meta.Timings, response.Timings = response.Timings, nil
+	}
+	return meta
 }
 
 func (s *DefaultServer) writeJSON(w http.ResponseWriter, req *http.Request, obj interface{}) {
-	w.Header().Set("Content-Type", "application/json")
+	s.writeJSONStatus(w, req, http.StatusOK, obj)
+}
+
+// writeJSONStatus writes the object as JSON.
+func (s *DefaultServer) writeJSONStatus(w http.ResponseWriter, req *http.Request, status int, obj interface{}) {
+	s.writeJSONPStatus(w, req, status, obj, "")
+}
 
-	scheme := "http://"
-	if req.URL.Scheme == "https" {
-		scheme = "https://"
+// writeJSONPStatus writes the object as JSON, or if the callback is set, as JSONP calling it. The
+// callback must already be validated, see jsonpCallback.
+func (s *DefaultServer) writeJSONPStatus(w http.ResponseWriter, req *http.Request, status int, obj interface{}, callback string) {
+	w.Header().Set("Content-Type", "application/json")
+	if callback != "" {
+		w.Header().Set("Content-Type", "application/javascript")
 	}
 
 	// TODO Consider setting this on all responses
-	w.Header().Set("Access-Control-Allow-Origin", scheme+s.Config.Host)
-	w.Header().Set("Vary", "Origin")
+	s.writeCORSHeaders(w, req)
 
 	// TODO Do something with the returned err
-	json.NewEncoder(w).Encode(obj)
+	var buf bytes.Buffer
+	if callback != "" {
+		b, _ := json.Marshal(obj)
+		fmt.Fprintf(&buf, "%s(%s);\n", callback, b)
+	} else {
+		json.NewEncoder(&buf).Encode(obj)
+	}
+	writeBody(w, req, status, buf.Bytes())
+}
+
+// writeCORSHeaders allows the main site to read the response. If the request's Origin is one of
+// the conf.Config.AllowedOrigins it is allowed, otherwise the origin is the configured Host,
+// falling back to the request's Host. If neither is known no origin is allowed, instead of
+// sending a malformed Access-Control-Allow-Origin.
+func (s *DefaultServer) writeCORSHeaders(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Vary", "Origin") // Add, so any Vary: Accept-Encoding remains
+
+	if origin := s.allowedOrigin(req.Header.Get("Origin")); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		return
+	}
+
+	host := s.Config.Host
+	if host == "" {
+		host = req.Host
+	}
+	if host == "" {
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", s.scheme(req)+"://"+host)
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin for this Origin if it is one of the
+// conf.Config.AllowedOrigins, otherwise "".
+func (s *DefaultServer) allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range s.Config.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// corsMethods are the methods allowed cross-origin.
+const corsMethods = "GET, HEAD, OPTIONS"
+
+// corsPreflight is middleware answering CORS preflight requests, instead of doing the lookups.
+func (s *DefaultServer) corsPreflight(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodOptions || req.Header.Get("Access-Control-Request-Method") == "" {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		s.writeCORSHeaders(w, req)
+		w.Header().Set("Access-Control-Allow-Methods", corsMethods)
+		if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+	})
 }
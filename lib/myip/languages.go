@@ -0,0 +1,56 @@
+package myip
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// acceptLanguages returns the canonical BCP 47 tags in the Accept-Language header, most preferred
+// first. Malformed entries, and the "*" wildcard, are discarded.
+func acceptLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.TrimSpace(params[0])
+		if name == "" || name == "*" {
+			continue
+		}
+
+		tag, err := language.Parse(name)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue // q=0 means not acceptable
+		}
+
+		langs = append(langs, weighted{tag.String(), q})
+	}
+
+	// Ties keep the order they were listed in
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	var tags []string
+	for _, lang := range langs {
+		tags = append(tags, lang.tag)
+	}
+	return tags
+}
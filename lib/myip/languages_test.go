@@ -0,0 +1,27 @@
+package myip
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestAcceptLanguages(t *testing.T) {
+	data := []struct {
+		header string
+		want   []string
+	}{
+		{header: "", want: nil},
+		{header: "fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5", want: []string{"fr-CH", "fr", "en"}},
+		{header: "en;q=0.5, de", want: []string{"de", "en"}},
+		{header: "EN-us, zh-hant-tw", want: []string{"en-US", "zh-Hant-TW"}},
+		{header: "en, !!!, fr;q=0", want: []string{"en"}}, // Malformed and unacceptable entries are discarded
+	}
+
+	for _, test := range data {
+		got := acceptLanguages(test.header)
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("acceptLanguages(%q) diff: (-got +want)\n%s", test.header, diff)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package myip
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/whois"
+	"golang.org/x/net/publicsuffix"
+)
+
+// emailRegex matches email addresses in whois bodies, capturing the domain.
+var emailRegex = regexp.MustCompile(`[A-Za-z0-9._%+-]+@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+
+// registrableDomain returns the registrable domain (eTLD+1) of the name, e.g. "example.co.uk"
+// for "host.example.co.uk.", or "" if there isn't one.
+func registrableDomain(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+// orgDomain returns the domain of the client's organization, taken from the reverse DNS, or
+// failing that, the first email address in the whois.
+func orgDomain(dnsResp *dns.Response, whoisResp *whois.Response) string {
+	if dnsResp != nil {
+		for _, name := range dnsResp.Names {
+			if domain := registrableDomain(name); domain != "" {
+				return domain
+			}
+		}
+	}
+
+	if whoisResp != nil {
+		for _, match := range emailRegex.FindAllStringSubmatch(whoisResp.Body, -1) {
+			if domain := registrableDomain(match[1]); domain != "" {
+				return domain
+			}
+		}
+	}
+
+	return ""
+}
+
+// orgLogoURL returns the conf.Config.OrgLogoURL for the client's organization, or "" if not
+// configured, or no domain is known. The logo is never fetched.
+func (s *DefaultServer) orgLogoURL(dnsResp *dns.Response, whoisResp *whois.Response) string {
+	if s.Config.OrgLogoURL == "" {
+		return ""
+	}
+
+	domain := orgDomain(dnsResp, whoisResp)
+	if domain == "" {
+		return ""
+	}
+	return strings.Replace(s.Config.OrgLogoURL, "{domain}", url.PathEscape(domain), -1)
+}
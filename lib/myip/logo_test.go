@@ -0,0 +1,48 @@
+package myip
+
+import (
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/whois"
+)
+
+func TestOrgLogoURL(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		OrgLogoURL: "https://logo.example/{domain}?size=64",
+	})
+
+	data := []struct {
+		dns   *dns.Response
+		whois *whois.Response
+		want  string
+	}{
+		{
+			dns:  &dns.Response{Names: []string{"c-203-0-113-1.hsd1.ca.comcast.net."}},
+			want: "https://logo.example/comcast.net?size=64",
+		},
+		{
+			dns:  &dns.Response{Names: []string{"host.example.co.uk."}},
+			want: "https://logo.example/example.co.uk?size=64",
+		},
+		{
+			dns:   &dns.Response{Names: []string{"localhost"}},
+			whois: &whois.Response{Body: "OrgName: Example\nOrgAbuseEmail: abuse@noc.example.net\n"},
+			want:  "https://logo.example/example.net?size=64",
+		},
+		{
+			whois: &whois.Response{Body: "OrgName: Example\n"},
+			want:  "",
+		},
+		{
+			want: "",
+		},
+	}
+
+	for _, test := range data {
+		if got := s.orgLogoURL(test.dns, test.whois); got != test.want {
+			t.Errorf("orgLogoURL(%+v, %+v) = %q, want %q", test.dns, test.whois, got, test.want)
+		}
+	}
+}
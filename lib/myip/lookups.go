@@ -0,0 +1,131 @@
+package myip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"bramp.net/myip/lib/cache"
+)
+
+// The names of each lookup, as used by the "include" and "exclude" query parameters, and
+// conf.Config.DisabledLookups.
+const (
+	lookupDNS      = "dns"
+	lookupWhois    = "whois"
+	lookupLocation = "location"
+	lookupUA       = "ua"
+	lookupASN      = "asn"
+)
+
+var allLookups = []string{lookupDNS, lookupWhois, lookupLocation, lookupUA, lookupASN}
+
+// legacyLookupParams are the older query parameters that disable a lookup, e.g. "?whois=false".
+var legacyLookupParams = map[string]string{
+	lookupDNS:   "reverse",
+	lookupWhois: "whois",
+	lookupUA:    "ua",
+}
+
+// enabledLookups returns the set of lookups to perform for this request. The client may choose
+// which lookups it wants with "?include=whois,location" or "?exclude=dns", or implicitly by only
+// requesting some fields with "?fields=", but can never enable a lookup disabled by the config.
+func (s *DefaultServer) enabledLookups(req *http.Request) map[string]bool {
+	q := req.URL.Query()
+
+	enabled := make(map[string]bool)
+	if include := q.Get("include"); include != "" {
+		for _, name := range splitList(include) {
+			enabled[name] = true
+		}
+	} else {
+		for _, name := range allLookups {
+			enabled[name] = true
+		}
+	}
+
+	for _, name := range splitList(q.Get("exclude")) {
+		delete(enabled, name)
+	}
+
+	for name, param := range legacyLookupParams {
+		if q.Get(param) == "false" {
+			delete(enabled, name)
+		}
+	}
+
+	for _, name := range s.Config.DisabledLookups {
+		delete(enabled, name)
+	}
+
+	// Skip the lookups for any fields not requested with "?fields=".
+	if fields := requestedFields(req); fields != nil {
+		needed := fieldsLookups(fields)
+		for name := range enabled {
+			if !needed[name] {
+				delete(enabled, name)
+			}
+		}
+	}
+
+	return enabled
+}
+
+// lookupPlan is the lookups to perform for a request's address, and the context to perform them in.
+type lookupPlan struct {
+	ctx     context.Context
+	lookups map[string]bool
+
+	override  bool   // The address came from the "?host=" override, so the shared caches are bypassed
+	scope     string // The address's scope, see addressScope
+	private   bool   // The address is local, so has no whois, ASN or location
+	throttled bool   // The client exceeded conf.Config.EnrichmentRate, so only the location is looked up
+}
+
+// planLookups returns the lookups to perform for the request's address (host), and the context to
+// perform them in. Overridden hosts bypass the shared caches, local addresses skip the whois, ASN
+// and location, and throttled clients get just the location. Every handler doing lookups should
+// use this (and bound each lookup with withLookupTimeout), so they all follow the same rules.
+func (s *DefaultServer) planLookups(req *http.Request, host string) *lookupPlan {
+	p := &lookupPlan{
+		ctx:     req.Context(),
+		lookups: s.enabledLookups(req),
+		scope:   addressScope(net.ParseIP(host)),
+	}
+
+	// Keep lookups for overridden hosts out of the caches shared with real clients.
+	p.override = s.hostOverride(req) != ""
+	if p.override {
+		p.ctx = cache.WithBypass(p.ctx)
+	}
+
+	// Local addresses have no whois or location, so don't waste time looking them up.
+	p.private = isLocalScope(p.scope)
+	if p.private {
+		delete(p.lookups, lookupWhois)
+		delete(p.lookups, lookupASN)
+		delete(p.lookups, lookupLocation)
+	}
+
+	// Skip the expensive lookups for clients making too many requests, serving just the location.
+	p.throttled = !s.throttler.allow(host)
+	if p.throttled {
+		p.lookups = map[string]bool{
+			lookupLocation: p.lookups[lookupLocation],
+		}
+	}
+
+	return p
+}
+
+// splitList splits a comma separated list, ignoring any empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
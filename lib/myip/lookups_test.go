@@ -0,0 +1,46 @@
+package myip
+
+import (
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestEnabledLookups(t *testing.T) {
+	data := []struct {
+		url      string
+		disabled []string
+		want     []string
+	}{
+		{url: "/json", want: []string{"asn", "dns", "location", "ua", "whois"}},
+		{url: "/json?include=whois,location", want: []string{"location", "whois"}},
+		{url: "/json?exclude=dns", want: []string{"asn", "location", "ua", "whois"}},
+		{url: "/json?include=dns,whois&exclude=dns", want: []string{"whois"}},
+		{url: "/json?whois=false&reverse=false", want: []string{"asn", "location", "ua"}},
+		{url: "/json?fields=RemoteAddr", want: nil},
+		{url: "/json?fields=location,Network,Unknown", want: []string{"asn", "location", "whois"}},
+		{url: "/json?fields=RemoteAddrWhois&exclude=whois", want: nil},
+
+		// The client can't enable a lookup disabled by the config.
+		{url: "/json?include=whois,location", disabled: []string{"whois"}, want: []string{"location"}},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{
+			DisabledLookups: test.disabled,
+		})
+
+		var got []string
+		for name := range s.enabledLookups(httptest.NewRequest("GET", test.url, nil)) {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("enabledLookups(%q) with disabled %q diff: (-got +want)\n%s", test.url, test.disabled, diff)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package myip
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the Prometheus metrics for this server. Each server has its own registry, which is
+// exposed on /metrics if conf.Config.MetricsEnabled is set.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests       *prometheus.CounterVec
+	lookupDuration *prometheus.HistogramVec
+	lookupFailures *prometheus.CounterVec
+
+	// failureWindows are the recent lookups of each provider, for myip_lookup_failure_ratio.
+	failureWindows map[string]*window
+}
+
+// The window the myip_lookup_failure_ratio is calculated over.
+const (
+	failureWindow        = 5 * time.Minute
+	failureWindowBuckets = 30
+)
+
+// windowedLookups are the lookups with a myip_lookup_failure_ratio.
+var windowedLookups = []string{lookupDNS, lookupWhois, lookupASN, lookupLocation}
+
+func newMetrics() *metrics {
+	start := time.Now()
+
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "myip_requests_total",
+			Help: "Number of requests, by output format.",
+		}, []string{"format"}),
+
+		lookupDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "myip_lookup_duration_seconds",
+			Help:    "How long each lookup took.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"lookup"}),
+
+		lookupFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "myip_lookup_failures_total",
+			Help: "Number of failed (or timed out) lookups.",
+		}, []string{"lookup"}),
+	}
+
+	m.registry.MustRegister(m.requests, m.lookupDuration, m.lookupFailures)
+
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "myip_uptime_seconds",
+		Help: "How long the server has been running.",
+	}, func() float64 {
+		return time.Since(start).Seconds()
+	}))
+
+	m.failureWindows = make(map[string]*window)
+	for _, name := range windowedLookups {
+		w := newWindow(failureWindow, failureWindowBuckets)
+		m.failureWindows[name] = w
+
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "myip_lookup_failure_ratio",
+			Help:        "Fraction of the lookups in the last 5 minutes that failed (or timed out).",
+			ConstLabels: prometheus.Labels{"provider": name},
+		}, w.ratio))
+	}
+
+	return m
+}
+
+// request records a request for this output format.
+func (m *metrics) request(format string) {
+	m.requests.WithLabelValues(format).Inc()
+}
+
+// lookups records the duration of each lookup, and which failed.
+func (m *metrics) lookups(t *timings, failed []string) {
+	isFailed := make(map[string]bool, len(failed))
+	for _, name := range failed {
+		m.lookupFailures.WithLabelValues(name).Inc()
+		isFailed[name] = true
+	}
+
+	t.mu.Lock()
+	for name, d := range t.durations {
+		m.lookupDuration.WithLabelValues(name).Observe(d.Seconds())
+		if w, found := m.failureWindows[name]; found {
+			w.add(isFailed[name])
+		}
+	}
+	t.mu.Unlock()
+}
+
+// handler returns the /metrics handler.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,74 @@
+package myip
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/whois"
+	"github.com/gorilla/mux"
+)
+
+func TestMetrics(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{
+		Debug:          true,
+		MetricsEnabled: true,
+	})
+
+	// A invalid host, so no lookups are performed.
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/json?host=invalid", nil))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := ioutil.ReadAll(w.Body)
+	if want := `myip_requests_total{format="json"} 1`; !strings.Contains(string(body), want) {
+		t.Errorf("GET /metrics = %q, want it to contain %q", body, want)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{Debug: true})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(w.Body.String(), "myip_requests_total") {
+		t.Errorf("GET /metrics = %q, want no metrics when disabled", w.Body)
+	}
+}
+
+func TestMetricsLookupFailureRatio(t *testing.T) {
+	m := NewMockServer(&conf.Config{MetricsEnabled: true})
+	m.RemoteAddr = "203.0.113.1:1234"
+	m.Whois = &whois.Response{Body: "OrgName: Example"}
+
+	r := mux.NewRouter()
+	m.Register(r)
+
+	for i := 0; i < 4; i++ {
+		m.Errors = nil
+		if i == 0 {
+			m.Errors = map[string]error{lookupWhois: errors.New("connection refused")}
+		}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://ip.example.com/json?include=whois", nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://ip.example.com/metrics", nil))
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`myip_lookup_failure_ratio{provider="whois"} 0.25`,
+		`myip_lookup_failure_ratio{provider="dns"} 0`, // Not looked up
+		`myip_uptime_seconds `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET /metrics = %q, want it to contain %q", body, want)
+		}
+	}
+}
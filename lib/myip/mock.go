@@ -0,0 +1,111 @@
+package myip
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"bramp.net/myip/lib/asn"
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/location"
+	"bramp.net/myip/lib/whois"
+	"github.com/gorilla/mux"
+)
+
+// MockServer is a Server whose lookups return canned responses, instead of querying DNS, whois,
+// or the location provider. It's intended for tests of code embedding this package, so they can
+// exercise the routing and the response formats deterministically.
+//
+// The fields may be changed between requests, but not while a request is being served.
+type MockServer struct {
+	*DefaultServer
+
+	// RemoteAddr, if set, replaces the address of every request served by Register.
+	RemoteAddr string
+
+	// The canned lookup responses. If nil, the lookup returns a empty response.
+	ReverseDNS *dns.Response
+	Whois      *whois.Response
+	ASN        *asn.Response
+	Location   *location.Response
+
+	// Errors fails the named lookup ("dns", "whois", "asn" or "location") with the error.
+	Errors map[string]error
+}
+
+var _ Server = (*MockServer)(nil)
+
+// NewMockServer returns a MockServer with the given config. Unlike Register, none of the local
+// data sources are periodically refreshed.
+func NewMockServer(config *conf.Config) *MockServer {
+	m := &MockServer{
+		DefaultServer: newDefaultServer(config),
+	}
+
+	m.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		if err := m.Errors[lookupDNS]; err != nil {
+			return &dns.Response{Query: addr, Error: err.Error()}
+		}
+
+		resp := &dns.Response{}
+		if m.ReverseDNS != nil {
+			*resp = *m.ReverseDNS
+		}
+		resp.Query = addr
+		return resp
+	}
+	m.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		if err := m.Errors[lookupWhois]; err != nil {
+			return &whois.Response{Query: addr, Error: err.Error()}
+		}
+
+		resp := &whois.Response{}
+		if m.Whois != nil {
+			*resp = *m.Whois
+		}
+		resp.Query = addr
+		return resp
+	}
+	m.asnLookup = func(ctx context.Context, addr string) *asn.Response {
+		if err := m.Errors[lookupASN]; err != nil {
+			return &asn.Response{Query: addr, Error: err.Error()}
+		}
+
+		resp := &asn.Response{}
+		if m.ASN != nil {
+			*resp = *m.ASN
+		}
+		resp.Query = addr
+		return resp
+	}
+	m.locator = location.ProviderFunc(func(ctx context.Context, ip net.IP) (*location.Response, error) {
+		if err := m.Errors[lookupLocation]; err != nil {
+			return nil, err
+		}
+
+		resp := &location.Response{}
+		if m.Location != nil {
+			*resp = *m.Location
+		}
+		return resp, nil
+	})
+
+	return m
+}
+
+// Register registers this MockServer on the router, the same as the package's Register.
+func (m *MockServer) Register(r *mux.Router, middleware ...mux.MiddlewareFunc) {
+	r.Use(m.setRemoteAddr)
+	register(r, m.DefaultServer, m.Config, middleware...)
+}
+
+// setRemoteAddr is middleware replacing the request's address with MockServer.RemoteAddr.
+func (m *MockServer) setRemoteAddr(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if m.RemoteAddr != "" {
+			req.RemoteAddr = m.RemoteAddr
+		}
+		h.ServeHTTP(w, req)
+	})
+}
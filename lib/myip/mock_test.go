@@ -0,0 +1,66 @@
+package myip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/location"
+	"bramp.net/myip/lib/whois"
+	"github.com/gorilla/mux"
+)
+
+func ExampleMockServer() {
+	mock := NewMockServer(&conf.Config{})
+	mock.RemoteAddr = "203.0.113.9:54321"
+
+	r := mux.NewRouter()
+	mock.Register(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://ip.example.com/ip", nil))
+	fmt.Print(w.Body.String())
+	// Output: 203.0.113.9
+}
+
+func TestMockServer(t *testing.T) {
+	mock := NewMockServer(&conf.Config{})
+	mock.RemoteAddr = "203.0.113.9:54321"
+	mock.ReverseDNS = &dns.Response{Names: []string{"host.example.com."}}
+	mock.Whois = &whois.Response{Body: "OrgName: Example\n"}
+	mock.Location = &location.Response{Country: "GB"}
+	mock.Errors = map[string]error{
+		lookupASN: errors.New("asn is down"),
+	}
+
+	r := mux.NewRouter()
+	mock.Register(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://ip.example.com/json", nil))
+
+	var got Response
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("GET /json returned invalid json: %s", err)
+	}
+
+	if got.RemoteAddr != "203.0.113.9" {
+		t.Errorf("GET /json RemoteAddr = %q, want %q", got.RemoteAddr, "203.0.113.9")
+	}
+	if got.RemoteAddrReverse == nil || len(got.RemoteAddrReverse.Names) != 1 || got.RemoteAddrReverse.Names[0] != "host.example.com." {
+		t.Errorf("GET /json RemoteAddrReverse = %+v, want the canned names", got.RemoteAddrReverse)
+	}
+	if got.RemoteAddrWhois == nil || got.RemoteAddrWhois.Query != "203.0.113.9" || got.RemoteAddrWhois.Body != mock.Whois.Body {
+		t.Errorf("GET /json RemoteAddrWhois = %+v, want the canned whois", got.RemoteAddrWhois)
+	}
+	if got.Location == nil || got.Location.Country != "GB" {
+		t.Errorf("GET /json Location = %+v, want the canned location", got.Location)
+	}
+	if got.ASN != nil {
+		t.Errorf("GET /json ASN = %+v, want nil as the lookup failed", got.ASN)
+	}
+}
@@ -17,12 +17,16 @@
 package myip
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"sync"
 
 	"github.com/ua-parser/uap-go/uaparser"
+	"golang.org/x/sync/errgroup"
 
+	"bramp.net/myip/lib/asn"
 	"bramp.net/myip/lib/dns"
 	"bramp.net/myip/lib/location"
 	"bramp.net/myip/lib/ua"
@@ -31,110 +35,385 @@ import (
 
 // Response is a normal response.
 type Response struct {
-	RequestID string `json:",omitempty"`
+	RequestID string `json:",omitempty" yaml:"requestid,omitempty"`
 
-	RemoteAddr        string
-	RemoteAddrFamily  string
-	RemoteAddrReverse *dns.Response   `json:",omitempty"`
-	RemoteAddrWhois   *whois.Response `json:",omitempty"`
+	// ServedBy is the node that served the request, see conf.Config.NodeName.
+	ServedBy string `json:",omitempty" yaml:"servedby,omitempty"`
 
-	ActualRemoteAddr string `json:",omitempty"` // The actual one we observed
+	RemoteAddr        string          `yaml:"remoteaddr"`
+	RemoteAddrFamily  string          `yaml:"remoteaddrfamily"`
+	RemoteAddrReverse *dns.Response   `json:",omitempty" xml:"ReverseDNS,omitempty" yaml:"remoteaddrreverse,omitempty"`
+	RemoteAddrWhois   *whois.Response `json:",omitempty" xml:"Whois,omitempty" yaml:"remoteaddrwhois,omitempty"`
 
-	Method string
-	URL    string
-	Proto  string
+	// RemoteAddrPort is the client's source port, omitted if it's not known (e.g. when behind a
+	// proxy). Unlike ActualRemoteAddr's port, it's included regardless of
+	// conf.Config.KeepActualRemotePort.
+	RemoteAddrPort int `json:",omitempty" xml:",omitempty" yaml:"remoteaddrport,omitempty"`
 
-	Header http.Header
+	// RemoteAddrScope is one of "global", "private", "loopback", "link-local" or "bogon".
+	RemoteAddrScope string `yaml:"remoteaddrscope"`
 
-	Location  *location.Response `json:",omitempty"`
-	UserAgent *uaparser.Client   `json:",omitempty"` // TODO Create a ua.Response
+	// RemoteAddrIsPrivate is set if RemoteAddr is only meaningful on the local network (such as a
+	// private or loopback address), so the whois, ASN and location lookups were skipped.
+	RemoteAddrIsPrivate bool `json:",omitempty" yaml:"remoteaddrisprivate,omitempty"`
 
-	Insights map[string]string `json:",omitempty"`
+	// IPHash is a keyed hash of RemoteAddr, see conf.Config.IncludeIPHash.
+	IPHash string `json:",omitempty" yaml:"iphash,omitempty"`
+
+	// ASN is the Autonomous System announcing RemoteAddr, omitted if it could not be determined.
+	ASN *asn.Response `json:",omitempty" xml:",omitempty" yaml:"asn,omitempty"`
+
+	// Network is the most specific network containing RemoteAddr, from either the whois or the ASN
+	// prefix, e.g. "203.0.113.0/24".
+	Network string `json:",omitempty" yaml:"network,omitempty"`
+
+	// ActualRemoteAddr is the address we observed on the connection, which is the proxy's when the
+	// RemoteAddr came from a header. Its port is only kept with conf.Config.KeepActualRemotePort.
+	ActualRemoteAddr string `json:",omitempty" yaml:"actualremoteaddr,omitempty"`
+
+	Organization         string `json:",omitempty" yaml:"organization,omitempty"`
+	OrganizationOverride bool   `json:",omitempty" yaml:"organizationoverride,omitempty"` // Organization came from conf.Config.Organizations
+	OrgLogoURL           string `json:",omitempty" yaml:"orglogourl,omitempty"`           // See conf.Config.OrgLogoURL
+
+	Method string `yaml:"method"`
+	URL    string `yaml:"url"`
+	Proto  string `yaml:"proto"`
+
+	// ForwardedScheme is the scheme the client connected with, "http" or "https". Unlike URL and
+	// Proto it honors the X-Forwarded-Proto header from a trusted proxy terminating TLS.
+	ForwardedScheme string `json:",omitempty" yaml:"forwardedscheme,omitempty"`
+
+	// TLSALPN is the application protocol negotiated over TLS (e.g. "h2"), omitted for plain HTTP.
+	TLSALPN string `json:",omitempty" yaml:"tlsalpn,omitempty"`
+
+	// TLS is the connection's TLS details, omitted for plain HTTP or when TLS is terminated by a
+	// proxy.
+	TLS *TLS `json:",omitempty" yaml:"tls,omitempty"`
+
+	ConnID string `json:",omitempty" yaml:"connid,omitempty"` // Only in debug mode, and over HTTP/2
+
+	SecurityPosture *SecurityPosture `json:",omitempty" yaml:"securityposture,omitempty"`
+
+	Header http.Header `yaml:"header"`
+
+	// Languages are the client's preferred languages (from the Accept-Language header) as BCP 47
+	// tags, most preferred first.
+	Languages []string `json:",omitempty" xml:"Languages>Language,omitempty" yaml:"languages,omitempty"`
+
+	Location  *location.Response `json:",omitempty" yaml:"location,omitempty"`
+	NearestIX *NearestIX         `json:",omitempty" yaml:"nearestix,omitempty"`
+	UserAgent *uaparser.Client   `json:",omitempty" yaml:"useragent,omitempty"` // TODO Create a ua.Response
+
+	// UserAgentIsBot is set if the user agent is a known bot or crawler.
+	UserAgentIsBot bool `json:",omitempty" yaml:"useragentisbot,omitempty"`
+
+	// ResolverAddr is the address of the DNS resolver that looked up the client's hostname, and
+	// ResolverClientSubnet the client subnet it sent, see conf.Config.DNSResolverCheck.
+	ResolverAddr         string `json:",omitempty" yaml:"resolveraddr,omitempty"`
+	ResolverClientSubnet string `json:",omitempty" yaml:"resolverclientsubnet,omitempty"`
+
+	Insights map[string]string `json:",omitempty" yaml:"insights,omitempty"`
+
+	// Truncated lists the fields that were trimmed to fit in conf.Config.MaxResponseBytes.
+	Truncated []string `json:",omitempty" xml:"Truncated>Field,omitempty" yaml:"truncated,omitempty"`
+
+	// Throttled is set if the client exceeded conf.Config.EnrichmentRate, so the expensive lookups
+	// (such as whois) were skipped.
+	Throttled bool `json:",omitempty" yaml:"throttled,omitempty"`
+
+	// Timings is how long each lookup took in milliseconds. Only included in debug mode, or if
+	// conf.Config.IncludeTimings is set.
+	Timings map[string]int `json:",omitempty" yaml:"timings,omitempty"`
+
+	// serverTiming is the Server-Timing header, see conf.Config.ServerTiming.
+	serverTiming string
 }
 
 // MyIPHandler is the main code to handle a IP lookup.
 func (s *DefaultServer) MyIPHandler(req *http.Request) (*Response, error) {
-	ctx := req.Context()
-	wg := &sync.WaitGroup{}
-
 	host, err := s.GetRemoteAddr(req)
 	if err != nil {
-		return nil, fmt.Errorf("getting remote addr: %s", err)
+		return nil, fmt.Errorf("getting remote addr: %w", err)
 	}
 
-	family := "IPv4"
-	if f := req.URL.Query().Get("family"); f != "" {
-		// TODO Change this to actually lookup the family of `host`
-		family = f
-	}
+	plan := s.planLookups(req, host)
+	lookups := plan.lookups
+
+	// The lookups never fail (their errors are part of their responses), so the group's context
+	// is only cancelled once they have all returned, stopping any left in the background.
+	g, ctx := errgroup.WithContext(plan.ctx)
+
+	t := newTimings(host, s.Config.SlowLookupThreshold)
+
+	family := addressFamily(host)
 
 	var dnsResp *dns.Response
 	var whoisResp *whois.Response
+	var asnResp *asn.Response
 	var locationResponse *location.Response
 	var userAgentClient *uaparser.Client // TODO change this to be a ua.Response
 
 	if host != "" {
-		if req.URL.Query().Get("reverse") != "false" {
-			addToWg(wg, func() {
-				dnsResp = dns.HandleReverseDNS(ctx, host)
-			})
+		if lookups[lookupDNS] {
+			other := s.correlatedAddr(req, host)
+			goLookup(g, t.timed("dns", func() {
+				dnsResp, _ = s.withLookupTimeout(ctx, func(ctx context.Context) interface{} {
+					resp := s.lookupReverseDNS(ctx, host)
+					if other != "" {
+						resp.Secondary = s.lookupReverseDNS(ctx, other)
+					}
+					return resp
+				}).(*dns.Response)
+			}))
 		}
 
-		if req.URL.Query().Get("whois") != "false" {
-			addToWg(wg, func() {
-				whoisResp = whois.Handle(ctx, host)
-			})
+		if lookups[lookupWhois] {
+			goLookup(g, t.timed("whois", func() {
+				whoisResp, _ = s.withLookupTimeout(ctx, func(ctx context.Context) interface{} {
+					return s.lookupWhois(ctx, host)
+				}).(*whois.Response)
+			}))
+		}
+
+		if lookups[lookupASN] {
+			goLookup(g, t.timed("asn", func() {
+				asnResp, _ = s.withLookupTimeout(ctx, func(ctx context.Context) interface{} {
+					return s.lookupASN(ctx, host)
+				}).(*asn.Response)
+			}))
 		}
 	}
 
-	if req.URL.Query().Get("ua") != "false" {
+	if lookups[lookupUA] {
 		if useragent := req.Header.Get("User-Agent"); useragent != "" {
-			addToWg(wg, func() {
+			goLookup(g, func() {
 				userAgentClient = ua.DetermineUA(useragent)
 			})
 		}
 	}
 
-	addToWg(wg, func() {
-		locationResponse = location.Handle(s.Config, req)
-	})
+	if lookups[lookupLocation] {
+		goLookup(g, t.timed("location", func() {
+			locationResponse, _ = s.withLookupTimeout(ctx, func(ctx context.Context) interface{} {
+				return s.locate(ctx, req, host)
+			}).(*location.Response)
+		}))
+	}
 
-	requestID := req.Header.Get(s.Config.RequestIDHeader)
+	requestID := req.Header.Get(requestIDHeader(s.Config))
 
-	// Remove all headers we don't want to display to the user
+	// Remove all headers we don't want to display to the user. This is done on a copy, as lookups
+	// that timed out may still be reading the request.
+	header := s.redactHeaders(s.echoHeaders(req.Header))
 	for _, remove := range s.Config.DisallowedHeaders {
-		req.Header.Del(remove)
+		header.Del(remove)
+	}
+
+	// Wait for all the responses to come back. The lookups run concurrently, so this takes as long
+	// as the slowest, which is bounded by conf.Config.LookupTimeout.
+	g.Wait()
+
+	// The client has gone away, so there is no one to send the response to.
+	if err := req.Context().Err(); err != nil {
+		return nil, fmt.Errorf("request cancelled: %w", err)
+	}
+
+	var failed []string
+	if lookups[lookupDNS] && (dnsResp == nil || dnsResp.Error != "") {
+		failed = append(failed, lookupDNS)
+	}
+	if lookups[lookupWhois] && (whoisResp == nil || whoisResp.Error != "") {
+		failed = append(failed, lookupWhois)
+
+		// A whois server that timed out has nothing useful to show.
+		var timeout *whois.TimeoutError
+		if whoisResp != nil && errors.As(whoisResp.Err(), &timeout) {
+			whoisResp = nil
+		}
+	}
+	if lookups[lookupASN] && (asnResp == nil || asnResp.Error != "") {
+		failed = append(failed, lookupASN)
+		asnResp = nil // A partial ASN is not useful to clients
+	}
+	if lookups[lookupLocation] && locationResponse == nil {
+		failed = append(failed, lookupLocation)
+	}
+	s.metrics.lookups(t, failed)
+
+	if s.Config.TrackCountryChanges && !plan.override && locationResponse != nil && locationResponse.Country != "" {
+		if token := req.URL.Query().Get("token"); token != "" {
+			if previous, changed := s.countries.see(token, locationResponse.Country); changed {
+				locationResponse.CountryChanged = true
+				locationResponse.PreviousCountry = previous
+			}
+		}
+	}
+
+	var network string
+	if whoisResp != nil {
+		network = whois.Network(whoisResp.Body, host)
+	}
+	if asnResp != nil {
+		network = moreSpecific(network, asnResp.Prefix)
+	}
+
+	org, orgOverride := s.organizationOverride(host)
+	logo := s.orgLogoURL(dnsResp, whoisResp)
+
+	actual := req.RemoteAddr
+	if !s.Config.KeepActualRemotePort {
+		if h, _, err := net.SplitHostPort(actual); err == nil {
+			actual = h
+		}
+	}
+
+	var ipHash string
+	if s.Config.IncludeIPHash {
+		ipHash = hashIP(s.Config.IPHashSecret, host)
 	}
 
-	// Wait for all the responses to come back
-	wg.Wait()
+	var alpn string
+	if req.TLS != nil {
+		alpn = req.TLS.NegotiatedProtocol
+	}
+
+	var conn string
+	if s.Config.Debug {
+		conn = connID(req)
+	}
+
+	var posture *SecurityPosture
+	if s.Config.IncludeSecurityPosture {
+		posture = s.securityPosture(req)
+	}
+
+	var nearestIX *NearestIX
+	if s.Config.IncludeNearestIX {
+		nearestIX = newNearestIX(locationResponse)
+	}
+
+	var resolver dns.ResolverReport
+	if report := s.resolverReport(req); report != nil {
+		resolver = *report
+	}
 
-	return &Response{
+	var durations map[string]int
+	if s.Config.Debug || s.Config.IncludeTimings {
+		durations = t.milliseconds()
+	}
+
+	return s.truncate(&Response{
 		RequestID: requestID,
+		ServedBy:  s.nodeName,
 
 		RemoteAddr:        host,
 		RemoteAddrFamily:  family,
 		RemoteAddrReverse: dnsResp,
 		RemoteAddrWhois:   whoisResp,
 
-		ActualRemoteAddr: req.RemoteAddr,
+		RemoteAddrPort: s.GetRemotePort(req),
+
+		RemoteAddrScope:     plan.scope,
+		RemoteAddrIsPrivate: plan.private,
+
+		ASN: asnResp,
+
+		IPHash: ipHash,
+
+		Network: network,
+
+		ActualRemoteAddr: actual,
+
+		Organization:         org,
+		OrganizationOverride: orgOverride,
+		OrgLogoURL:           logo,
 
 		UserAgent: userAgentClient,
 		Location:  locationResponse,
+		NearestIX: nearestIX,
+
+		UserAgentIsBot: ua.IsBot(req.Header.Get("User-Agent"), userAgentClient),
+
+		ResolverAddr:         resolver.Addr,
+		ResolverClientSubnet: resolver.ClientSubnet,
 
 		Method: req.Method,
 		URL:    req.URL.String(),
 		Proto:  req.Proto,
-		Header: req.Header,
-	}, nil
+		Header: header,
+
+		ForwardedScheme: s.scheme(req),
+
+		Languages: acceptLanguages(req.Header.Get("Accept-Language")),
+
+		TLSALPN: alpn,
+		TLS:     newTLS(req.TLS),
+
+		ConnID: conn,
+
+		SecurityPosture: posture,
+
+		Throttled: plan.throttled,
+
+		Timings: durations,
+
+		serverTiming: s.serverTiming(t),
+	}), nil
 }
 
-// addToWg executes the function in a new gorountine and adds it to the WaitGroup, calling wg.Done
-// when finished. This makes it a little eaiser to use the WaitGroup.
-func addToWg(wg *sync.WaitGroup, f func()) {
-	wg.Add(1)
+// cancelled returns true if the error is because the client went away, in which case the handlers
+// should stop without writing a response.
+func cancelled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// withLookupTimeout returns the result of f, or nil if it takes longer than
+// conf.Config.LookupTimeout. f's context is cancelled when the timeout expires, but if f ignores
+// it, it is left to finish in the background, and its result discarded.
+func (s *DefaultServer) withLookupTimeout(ctx context.Context, f func(ctx context.Context) interface{}) interface{} {
+	if s.Config.LookupTimeout <= 0 {
+		return f(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Config.LookupTimeout)
+	defer cancel()
+
+	result := make(chan interface{}, 1) // Buffered, so f can always finish
 	go func() {
-		defer wg.Done()
-		f()
+		result <- f(ctx)
 	}()
+
+	select {
+	case r := <-result:
+		return r
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// goLookup executes the lookup in a new goroutine of the group, which never returns a error.
+func goLookup(g *errgroup.Group, f func()) {
+	g.Go(func() error {
+		f()
+		return nil
+	})
+}
+
+// moreSpecific returns whichever of the CIDRs has the longer prefix, preferring a if they're
+// equal. Invalid (or empty) CIDRs are ignored, returning "" if both are.
+func moreSpecific(a, b string) string {
+	prefix := func(cidr string) int {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			ones, _ := n.Mask.Size()
+			return ones
+		}
+		return -1
+	}
+
+	if pa, pb := prefix(a), prefix(b); pb > pa {
+		return b
+	} else if pa >= 0 {
+		return a
+	}
+	return ""
 }
@@ -0,0 +1,293 @@
+package myip
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/asn"
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/location"
+	"bramp.net/myip/lib/whois"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// newSlowServer returns a server whose lookups take the given times, ignoring their context.
+func newSlowServer(config *conf.Config, dnsDelay, whoisDelay, locationDelay time.Duration) *DefaultServer {
+	s := newDefaultServer(config)
+	s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		time.Sleep(dnsDelay)
+		return &dns.Response{Query: addr}
+	}
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		time.Sleep(whoisDelay)
+		return &whois.Response{Query: addr}
+	}
+	s.locator = location.ProviderFunc(func(ctx context.Context, ip net.IP) (*location.Response, error) {
+		time.Sleep(locationDelay)
+		return &location.Response{Country: "GB"}, nil
+	})
+	return s
+}
+
+func TestMyIPHandlerConcurrentLookups(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	s := newSlowServer(&conf.Config{}, delay, delay, delay)
+
+	req := httptest.NewRequest("GET", "/json?include=dns,whois,location", nil)
+
+	start := time.Now()
+	resp, err := s.MyIPHandler(req)
+	took := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("MyIPHandler() err = %s, want nil", err)
+	}
+	if resp.RemoteAddrReverse == nil || resp.RemoteAddrWhois == nil || resp.Location == nil {
+		t.Errorf("MyIPHandler() = %+v, want all lookups", resp)
+	}
+	if took >= 3*delay {
+		t.Errorf("MyIPHandler() took %s, want less than the sum of the lookups %s", took, 3*delay)
+	}
+}
+
+func TestJSONHandlerServerTiming(t *testing.T) {
+	data := []struct {
+		config  *conf.Config
+		enabled bool
+	}{
+		{config: &conf.Config{}, enabled: false},
+		{config: &conf.Config{ServerTiming: true}, enabled: true},
+		{config: &conf.Config{Debug: true}, enabled: true},
+		{config: &conf.Config{IncludeTimings: true}, enabled: false}, // Only the body's Timings
+	}
+
+	for _, test := range data {
+		enabled := test.enabled
+		s := newSlowServer(test.config, 0, 0, 0)
+
+		req := httptest.NewRequest("GET", "/json?include=dns,whois,location", nil)
+		w := httptest.NewRecorder()
+		s.JSONHandler(w, req)
+
+		header := w.Header().Get("Server-Timing")
+		if !enabled {
+			if header != "" {
+				t.Errorf("JSONHandler() with %+v Server-Timing = %q, want none when disabled", test.config, header)
+			}
+			continue
+		}
+
+		var got []string
+		for _, metric := range strings.Split(header, ",") {
+			parts := strings.Split(strings.TrimSpace(metric), ";")
+			if len(parts) != 2 || !strings.HasPrefix(parts[1], "dur=") {
+				t.Errorf("JSONHandler() Server-Timing metric %q, want name;dur=ms", metric)
+				continue
+			}
+			got = append(got, parts[0])
+		}
+		want := []string{"dns", "location", "whois"}
+		if diff := pretty.Compare(got, want); diff != "" {
+			t.Errorf("JSONHandler() Server-Timing %q metrics diff: (-got +want)\n%s", header, diff)
+		}
+	}
+}
+
+func TestMyIPHandlerUserAgentIsBot(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+
+	data := []struct {
+		useragent string
+		want      bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/60.0.3112.113 Safari/537.36", false},
+		{"", false},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "/json?include=ua", nil)
+		req.Header.Set("User-Agent", test.useragent)
+
+		got, err := s.MyIPHandler(req)
+		if err != nil {
+			t.Fatalf("MyIPHandler(%q) err = %s, want nil", test.useragent, err)
+		}
+		if got.UserAgentIsBot != test.want {
+			t.Errorf("MyIPHandler(%q).UserAgentIsBot = %t, want %t", test.useragent, got.UserAgentIsBot, test.want)
+		}
+	}
+}
+
+func TestMyIPHandlerLookupTimeout(t *testing.T) {
+	s := newSlowServer(&conf.Config{
+		LookupTimeout: 50 * time.Millisecond,
+	}, 0, time.Second, 0)
+
+	req := httptest.NewRequest("GET", "/json?include=dns,whois,location", nil)
+
+	start := time.Now()
+	resp, err := s.MyIPHandler(req)
+	took := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("MyIPHandler() err = %s, want nil", err)
+	}
+	if resp.RemoteAddrWhois != nil {
+		t.Errorf("MyIPHandler().RemoteAddrWhois = %+v, want nil as it timed out", resp.RemoteAddrWhois)
+	}
+	if resp.RemoteAddrReverse == nil || resp.Location == nil {
+		t.Errorf("MyIPHandler() = %+v, want the DNS and location lookups", resp)
+	}
+	if took >= 500*time.Millisecond {
+		t.Errorf("MyIPHandler() took %s, want it bounded by the timeout", took)
+	}
+}
+
+func TestMyIPHandlerASN(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+	s.asnLookup = func(ctx context.Context, addr string) *asn.Response {
+		if addr != "8.8.8.8" {
+			return &asn.Response{Query: addr, Error: "no AS found"}
+		}
+		return &asn.Response{
+			Query:        addr,
+			Number:       15169,
+			Organization: "GOOGLE, US",
+			Prefix:       "8.8.8.0/24",
+		}
+	}
+
+	data := []struct {
+		remoteAddr  string
+		want        *asn.Response
+		wantNetwork string
+	}{
+		{
+			remoteAddr: "8.8.8.8:1234",
+			want: &asn.Response{
+				Query:        "8.8.8.8",
+				Number:       15169,
+				Organization: "GOOGLE, US",
+				Prefix:       "8.8.8.0/24",
+			},
+			wantNetwork: "8.8.8.0/24",
+		},
+		{remoteAddr: "192.0.2.1:1234", want: nil}, // Failures leave the field nil
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "/json?include=asn", nil)
+		req.RemoteAddr = test.remoteAddr
+
+		resp, err := s.MyIPHandler(req)
+		if err != nil {
+			t.Fatalf("MyIPHandler(%q) err = %s, want nil", test.remoteAddr, err)
+		}
+		if diff := pretty.Compare(resp.ASN, test.want); diff != "" {
+			t.Errorf("MyIPHandler(%q).ASN diff: (-got +want)\n%s", test.remoteAddr, diff)
+		}
+		if resp.Network != test.wantNetwork {
+			t.Errorf("MyIPHandler(%q).Network = %q, want %q", test.remoteAddr, resp.Network, test.wantNetwork)
+		}
+	}
+}
+
+func TestMyIPHandlerNetwork(t *testing.T) {
+	data := []struct {
+		whoisBody   string
+		asnPrefix   string
+		wantNetwork string
+	}{
+		{whoisBody: "CIDR: 8.0.0.0/9", asnPrefix: "8.8.8.0/24", wantNetwork: "8.8.8.0/24"}, // The ASN is more specific
+		{whoisBody: "CIDR: 8.8.8.0/24", asnPrefix: "8.0.0.0/9", wantNetwork: "8.8.8.0/24"},
+		{whoisBody: "CIDR: 8.8.8.0/24", asnPrefix: "", wantNetwork: "8.8.8.0/24"},
+		{whoisBody: "", asnPrefix: "8.0.0.0/9", wantNetwork: "8.0.0.0/9"},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{})
+		s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+			return &whois.Response{Query: addr, Body: test.whoisBody}
+		}
+		s.asnLookup = func(ctx context.Context, addr string) *asn.Response {
+			return &asn.Response{Query: addr, Number: 15169, Prefix: test.asnPrefix}
+		}
+
+		req := httptest.NewRequest("GET", "/json?include=whois,asn", nil)
+		req.RemoteAddr = "8.8.8.8:1234"
+
+		resp, err := s.MyIPHandler(req)
+		if err != nil {
+			t.Fatalf("MyIPHandler() err = %s, want nil", err)
+		}
+		if resp.Network != test.wantNetwork {
+			t.Errorf("MyIPHandler() with whois %q and ASN prefix %q Network = %q, want %q", test.whoisBody, test.asnPrefix, resp.Network, test.wantNetwork)
+		}
+	}
+}
+
+func TestMyIPHandlerLocationProvider(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+
+	var lookedUp net.IP
+	s.locator = location.ProviderFunc(func(ctx context.Context, ip net.IP) (*location.Response, error) {
+		lookedUp = ip
+		return &location.Response{City: "London", Country: "GB"}, nil
+	})
+
+	req := httptest.NewRequest("GET", "/json?include=location", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	resp, err := s.MyIPHandler(req)
+	if err != nil {
+		t.Fatalf("MyIPHandler(%q) err = %s, want nil", req.RemoteAddr, err)
+	}
+	if !lookedUp.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("MyIPHandler(%q) located %q, want %q", req.RemoteAddr, lookedUp, "192.0.2.1")
+	}
+	if resp.Location == nil || resp.Location.City != "London" || resp.Location.Currency != "GBP" {
+		t.Errorf("MyIPHandler(%q).Location = %+v, want London with currency GBP", req.RemoteAddr, resp.Location)
+	}
+}
+
+func TestJSONHandlerCancelled(t *testing.T) {
+	started := make(chan struct{})
+	lookupErr := make(chan error, 1)
+
+	s := newDefaultServer(&conf.Config{})
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		close(started)
+		select {
+		case <-ctx.Done():
+			lookupErr <- ctx.Err()
+		case <-time.After(time.Second):
+			lookupErr <- nil
+		}
+		return &whois.Response{Query: addr, Error: "cancelled"}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/json?include=whois", nil).WithContext(ctx)
+	req.RemoteAddr = "8.8.8.8:1234"
+
+	go func() {
+		<-started
+		cancel() // The client goes away mid lookup
+	}()
+
+	w := httptest.NewRecorder()
+	s.JSONHandler(w, req)
+
+	if err := <-lookupErr; err != context.Canceled {
+		t.Errorf("JSONHandler() whois lookup ctx.Err() = %v, want %v", err, context.Canceled)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("JSONHandler() = %q, want nothing written after the client went away", w.Body.String())
+	}
+}
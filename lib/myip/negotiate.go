@@ -0,0 +1,81 @@
+package myip
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The formats responses can be served in.
+const (
+	formatHTML = "html"
+	formatJSON = "json"
+	formatText = "text"
+	formatXML  = "xml"
+	formatYAML = "yaml"
+)
+
+// acceptFormats maps media ranges in the Accept header to the format served.
+var acceptFormats = map[string]string{
+	"text/html":        formatHTML,
+	"application/json": formatJSON,
+	"text/plain":       formatText,
+	"text/*":           formatHTML,
+	"application/*":    formatJSON,
+}
+
+// negotiateFormat returns the format best matching the Accept header, or "" if the header has no
+// usable preference. "*/*" on its own is not considered a preference, as most clients (including
+// curl) send it by default.
+func negotiateFormat(accept string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+
+		format, found := acceptFormats[mediaRange]
+		if !found {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		// Ties go to the first listed
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// IndexHandler serves the index page, as HTML, JSON or plain text chosen by the Accept header. If
+// the client has no preference, CLI tools get plain text, and everyone else HTML.
+func (s *DefaultServer) IndexHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Vary", "Accept")
+
+	format := negotiateFormat(req.Header.Get("Accept"))
+	if format == "" {
+		format = formatHTML
+		if s.isCLI(req, nil) {
+			format = formatText
+		}
+	}
+
+	switch format {
+	case formatJSON:
+		s.JSONHandler(w, req)
+	case formatText:
+		s.CLIHandler(w, req)
+	default:
+		s.metrics.request(formatHTML)
+		s.static.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,67 @@
+package myip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	data := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"*/*", ""},
+		{"image/png", ""},
+		{"application/json", formatJSON},
+		{"text/plain", formatText},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", formatHTML},
+		{"text/html;q=0.1, application/json;q=0.9", formatJSON},
+		{"application/json;q=0, text/plain", formatText},
+		{"TEXT/PLAIN", formatText},
+		{"text/*", formatHTML},
+	}
+
+	for _, test := range data {
+		if got := negotiateFormat(test.accept); got != test.want {
+			t.Errorf("negotiateFormat(%q) = %q, want %q", test.accept, got, test.want)
+		}
+	}
+}
+
+func TestIndexHandler(t *testing.T) {
+	s := newDefaultServer(&conf.Config{Debug: true})
+	s.static = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+	})
+
+	data := []struct {
+		accept    string
+		userAgent string
+		want      string // Content-Type
+	}{
+		{accept: "application/json", userAgent: "curl/7.64.1", want: "application/json"},
+		{accept: "text/plain", userAgent: "Mozilla/5.0", want: "text/plain"},
+		{accept: "text/html", userAgent: "curl/7.64.1", want: "text/html"},
+		{accept: "*/*", userAgent: "curl/7.64.1", want: "text/plain"},
+		{accept: "*/*", userAgent: "Mozilla/5.0", want: "text/html"},
+		{accept: "", userAgent: "Wget/1.20.3", want: "text/plain"},
+	}
+
+	for _, test := range data {
+		// A invalid host, so no lookups are performed, and a error is returned in the chosen
+		// format.
+		req := httptest.NewRequest("GET", "/?host=invalid", nil)
+		req.Header.Set("Accept", test.accept)
+		req.Header.Set("User-Agent", test.userAgent)
+		w := httptest.NewRecorder()
+		s.IndexHandler(w, req)
+
+		if got := w.Header().Get("Content-Type"); got != test.want {
+			t.Errorf("IndexHandler(Accept: %q, User-Agent: %q) Content-Type = %q, want %q", test.accept, test.userAgent, got, test.want)
+		}
+	}
+}
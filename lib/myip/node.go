@@ -0,0 +1,30 @@
+package myip
+
+import (
+	"net/http"
+	"os"
+
+	"bramp.net/myip/lib/conf"
+)
+
+// servedByHeader is the response header naming the node that served the request.
+const servedByHeader = "X-Served-By"
+
+// nodeName returns the name of this node, conf.Config.NodeName, falling back to the hostname.
+func nodeName(config *conf.Config) string {
+	if config.NodeName != "" {
+		return config.NodeName
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// servedBy is middleware which names this node in the X-Served-By response header.
+func (s *DefaultServer) servedBy(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if s.nodeName != "" {
+			w.Header().Set(servedByHeader, s.nodeName)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,47 @@
+package myip
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+)
+
+func TestNodeName(t *testing.T) {
+	if got := nodeName(&conf.Config{NodeName: "edge-1"}); got != "edge-1" {
+		t.Errorf("nodeName(NodeName: %q) = %q, want %q", "edge-1", got, "edge-1")
+	}
+
+	hostname, _ := os.Hostname()
+	if got := nodeName(&conf.Config{}); got != hostname {
+		t.Errorf("nodeName() = %q, want the hostname %q", got, hostname)
+	}
+}
+
+func TestServedBy(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{NodeName: "edge-1"})
+
+	for _, url := range []string{"https://ip.example.com/json?include=none", "https://ip.example.com/ip"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+
+		if got := w.Header().Get("X-Served-By"); got != "edge-1" {
+			t.Errorf("GET %s X-Served-By = %q, want %q", url, got, "edge-1")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://ip.example.com/json?include=none", nil))
+
+	var got Response
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("GET /json returned invalid json: %s", err)
+	}
+	if got.ServedBy != "edge-1" {
+		t.Errorf("GET /json ServedBy = %q, want %q", got.ServedBy, "edge-1")
+	}
+}
@@ -0,0 +1,51 @@
+package myip
+
+import (
+	"net"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// orgOverride is a operator supplied owner of a network.
+type orgOverride struct {
+	network *net.IPNet
+	name    string
+}
+
+// parseOrgOverrides parses the CIDR to organization map, returning the overrides ordered most
+// specific first. Invalid CIDRs are logged and skipped.
+func parseOrgOverrides(orgs map[string]string) []orgOverride {
+	var overrides []orgOverride
+	for cidr, name := range orgs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Errorf("Ignoring invalid organization override %q: %s", cidr, err)
+			continue
+		}
+		overrides = append(overrides, orgOverride{network, name})
+	}
+
+	sort.Slice(overrides, func(i, j int) bool {
+		a, _ := overrides[i].network.Mask.Size()
+		b, _ := overrides[j].network.Mask.Size()
+		return a > b
+	})
+
+	return overrides
+}
+
+// organizationOverride returns the operator supplied organization for this address, if any.
+func (s *DefaultServer) organizationOverride(addr string) (string, bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", false
+	}
+
+	for _, o := range s.orgOverrides {
+		if o.network.Contains(ip) {
+			return o.name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,37 @@
+package myip
+
+import (
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestOrganizationOverride(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Organizations: map[string]string{
+			"203.0.113.0/24":  "Example Corp",
+			"203.0.113.64/26": "Example Corp Lab",
+			"2001:db8::/32":   "Example IPv6",
+			"not a cidr":      "Ignored",
+		},
+	})
+
+	data := []struct {
+		addr      string
+		want      string
+		wantFound bool
+	}{
+		{addr: "203.0.113.1", want: "Example Corp", wantFound: true},
+		{addr: "203.0.113.65", want: "Example Corp Lab", wantFound: true}, // Most specific wins
+		{addr: "2001:db8::1", want: "Example IPv6", wantFound: true},
+		{addr: "198.51.100.1", want: "", wantFound: false},
+		{addr: "", want: "", wantFound: false},
+	}
+
+	for _, test := range data {
+		got, found := s.organizationOverride(test.addr)
+		if got != test.want || found != test.wantFound {
+			t.Errorf("organizationOverride(%q) = (%q, %v), want (%q, %v)", test.addr, got, found, test.want, test.wantFound)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package myip
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// staticRoute is the name of the route serving the static files.
+const staticRoute = "static"
+
+// rateLimit is middleware rejecting requests from clients exceeding conf.Config.RateLimit with
+// 429 Too Many Requests, and a Retry-After header. Static files are exempt, as they are cheap.
+func (s *DefaultServer) rateLimit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if route := mux.CurrentRoute(req); s.rateLimiter == nil || (route != nil && route.GetName() == staticRoute) {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		// Invalid addresses are left for the handler to reject.
+		host, err := s.GetRemoteAddr(req)
+		if err != nil {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		if ok, delay := s.rateLimiter.reserve(host); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,55 @@
+package myip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+)
+
+func TestRateLimit(t *testing.T) {
+	const burst = 3
+
+	r := mux.NewRouter()
+	Register(r, &conf.Config{
+		Debug:          true,
+		RateLimit:      1,
+		RateLimitBurst: burst,
+	})
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < burst; i++ {
+		if w := get("/ip", "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("GET /ip request %d = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+
+	w := get("/ip", "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("GET /ip request %d = %d, want %d", burst+1, w.Code, http.StatusTooManyRequests)
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry < 1 {
+		t.Errorf("GET /ip request %d Retry-After = %q, want a positive number of seconds", burst+1, w.Header().Get("Retry-After"))
+	}
+
+	// Other clients, health checks, and static files are not limited.
+	if w := get("/ip", "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("GET /ip from another client = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := get(healthzPath, "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("GET %s = %d, want %d", healthzPath, w.Code, http.StatusOK)
+	}
+	if w := get("/robots.txt", "192.0.2.1:1234"); w.Code == http.StatusTooManyRequests {
+		t.Errorf("GET /robots.txt = %d, want it not rate limited", w.Code)
+	}
+}
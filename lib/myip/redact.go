@@ -0,0 +1,72 @@
+package myip
+
+import "net/http"
+
+// defaultRedactedHeaders are always redacted, in addition to conf.Config.RedactedHeaders.
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"X-Api-Key",
+}
+
+// redacted replaces the values of redacted headers.
+const redacted = "[redacted]"
+
+// redactHeaders returns a copy of the headers, with the value of any sensitive header replaced,
+// so they are not reflected back in the response.
+func (s *DefaultServer) redactHeaders(header http.Header) http.Header {
+	header = header.Clone()
+	secret := []string{s.trustedSecretHeader()}
+	for _, lists := range [][]string{defaultRedactedHeaders, s.Config.RedactedHeaders, secret} {
+		for _, name := range lists {
+			if name == "" {
+				continue
+			}
+			if _, found := header[http.CanonicalHeaderKey(name)]; found {
+				header.Set(name, redacted)
+			}
+		}
+	}
+	return header
+}
+
+// defaultEchoHeaders are the headers echoed back in the response, if conf.Config.EchoHeaders is
+// not set.
+var defaultEchoHeaders = []string{
+	"User-Agent",
+	"Accept",
+	"Accept-Language",
+	"X-Forwarded-For",
+}
+
+// maxEchoValues bounds how many values of a repeated header are echoed back.
+const maxEchoValues = 10
+
+// echoHeaders returns a copy of just the headers that should be echoed back in the response, that
+// is those in conf.Config.EchoHeaders (or defaultEchoHeaders), with at most maxEchoValues values
+// each. The defaultRedactedHeaders and the trustedSecretHeader are never echoed, even if listed.
+func (s *DefaultServer) echoHeaders(header http.Header) http.Header {
+	allowed := s.Config.EchoHeaders
+	if len(allowed) == 0 {
+		allowed = defaultEchoHeaders
+	}
+
+	echo := make(http.Header)
+	for _, name := range allowed {
+		name = http.CanonicalHeaderKey(name)
+		if values, found := header[name]; found {
+			if len(values) > maxEchoValues {
+				values = values[:maxEchoValues]
+			}
+			echo[name] = append([]string(nil), values...)
+		}
+	}
+	for _, name := range defaultRedactedHeaders {
+		echo.Del(name)
+	}
+	if header := s.trustedSecretHeader(); header != "" {
+		echo.Del(header)
+	}
+	return echo
+}
@@ -0,0 +1,111 @@
+package myip
+
+import (
+	"net/http"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		RedactedHeaders: []string{"x-secret"},
+	})
+
+	header := http.Header{
+		"Authorization": {"Bearer abc"},
+		"Cookie":        {"a=1", "b=2"},
+		"X-Secret":      {"shh"},
+		"User-Agent":    {"curl/7.64.1"},
+	}
+
+	got := s.redactHeaders(header)
+	want := http.Header{
+		"Authorization": {"[redacted]"},
+		"Cookie":        {"[redacted]"},
+		"X-Secret":      {"[redacted]"},
+		"User-Agent":    {"curl/7.64.1"},
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("redactHeaders(...) diff: (-got +want)\n%s", diff)
+	}
+
+	if header.Get("Authorization") != "Bearer abc" {
+		t.Errorf("redactHeaders(...) modified the original headers")
+	}
+}
+
+func TestEchoHeaders(t *testing.T) {
+	header := http.Header{
+		"Accept":        {"*/*"},
+		"Authorization": {"Bearer abc"},
+		"Cookie":        {"a=1"},
+		"User-Agent":    {"curl/7.64.1"},
+		"X-Custom":      {"hello"},
+		"X-Api-Key":     {"secret"},
+	}
+
+	tests := []struct {
+		echo []string
+		want http.Header
+	}{
+		{
+			// Defaults
+			want: http.Header{
+				"Accept":     {"*/*"},
+				"User-Agent": {"curl/7.64.1"},
+			},
+		}, {
+			echo: []string{"x-custom", "cookie", "Authorization", "X-API-KEY"},
+			want: http.Header{
+				"X-Custom": {"hello"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s := newDefaultServer(&conf.Config{
+			EchoHeaders: test.echo,
+		})
+
+		got := s.echoHeaders(header)
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("echoHeaders(...) with %q diff: (-got +want)\n%s", test.echo, diff)
+		}
+		if _, found := got["Cookie"]; found {
+			t.Errorf("echoHeaders(...) with %q echoed the Cookie header", test.echo)
+		}
+	}
+}
+
+func TestEchoHeadersMaxValues(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+
+	header := make(http.Header)
+	for i := 0; i < 100; i++ {
+		header.Add("X-Forwarded-For", "192.0.2.1")
+	}
+
+	if got := len(s.echoHeaders(header)["X-Forwarded-For"]); got != maxEchoValues {
+		t.Errorf("echoHeaders(100 X-Forwarded-For) echoed %d values, want %d", got, maxEchoValues)
+	}
+}
+
+func TestTrustedHeaderSecretNotEchoed(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		TrustedHeaderSecret: "s3cret",
+		EchoHeaders:         []string{"User-Agent", "X-Proxy-Secret"},
+	})
+
+	header := http.Header{}
+	header.Set("User-Agent", "curl/7.64.1")
+	header.Set("X-Proxy-Secret", "s3cret")
+
+	if got := s.echoHeaders(header).Get("X-Proxy-Secret"); got != "" {
+		t.Errorf("echoHeaders(%v)[X-Proxy-Secret] = %q, want \"\"", header, got)
+	}
+	if got := s.redactHeaders(header).Get("X-Proxy-Secret"); got != redacted {
+		t.Errorf("redactHeaders(%v)[X-Proxy-Secret] = %q, want %q", header, got, redacted)
+	}
+}
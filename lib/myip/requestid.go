@@ -0,0 +1,56 @@
+package myip
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"bramp.net/myip/lib/conf"
+)
+
+const (
+	// defaultRequestIDHeader is used if conf.Config.RequestIDHeader is unset.
+	defaultRequestIDHeader = "X-Request-Id"
+
+	// requestIDResponseHeader is the response header the request ID is returned in.
+	requestIDResponseHeader = "X-Request-Id"
+)
+
+// requestIDHeader returns the request header holding the request ID.
+func requestIDHeader(config *conf.Config) string {
+	if config.RequestIDHeader != "" {
+		return config.RequestIDHeader
+	}
+	return defaultRequestIDHeader
+}
+
+// newRequestID returns a random (version 4) UUID, e.g. "0b9e0f3c-5c1a-4b8e-9d4f-2a6c3e1f7b8d".
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %s", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// RequestID returns middleware which ensures every request has a request ID, generating one if the
+// request didn't include it, and returns it in the X-Request-Id response header. The ID is stored
+// in the request's header, so the handlers and access log can find it.
+func RequestID(config *conf.Config) func(http.Handler) http.Handler {
+	header := requestIDHeader(config)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			id := req.Header.Get(header)
+			if id == "" {
+				id = newRequestID()
+				req.Header.Set(header, id)
+			}
+			w.Header().Set(requestIDResponseHeader, id)
+
+			h.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package myip
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	data := []struct {
+		config   *conf.Config
+		header   string // Request header to set
+		supplied string
+	}{
+		{config: &conf.Config{}, header: "X-Request-Id", supplied: "abc-123"},
+		{config: &conf.Config{RequestIDHeader: "Cf-Ray"}, header: "Cf-Ray", supplied: "5f1c2a3b4d5e6f7a-SJC"},
+		{config: &conf.Config{}}, // Generated
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(test.config)
+		h := RequestID(test.config)(http.HandlerFunc(s.JSONHandler))
+
+		req := httptest.NewRequest("GET", "/json?include=none", nil)
+		if test.supplied != "" {
+			req.Header.Set(test.header, test.supplied)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var resp Response
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("RequestID(%q) returned invalid json: %s", test.supplied, err)
+		}
+
+		got := w.Header().Get("X-Request-Id")
+		if test.supplied != "" && got != test.supplied {
+			t.Errorf("RequestID(%q) X-Request-Id = %q, want %q", test.supplied, got, test.supplied)
+		}
+		if test.supplied == "" && (len(got) != 36 || !uuidRegex.MatchString(got)) {
+			t.Errorf("RequestID() X-Request-Id = %q, want a 36 char UUID", got)
+		}
+		if resp.RequestID != got {
+			t.Errorf("RequestID(%q) Response.RequestID = %q, want %q", test.supplied, resp.RequestID, got)
+		}
+	}
+}
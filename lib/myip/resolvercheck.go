@@ -0,0 +1,63 @@
+package myip
+
+import (
+	"net"
+	"net/http"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultResolverListen is used when conf.Config.ResolverListen is not set.
+const defaultResolverListen = ":53"
+
+// newResolverLog returns the log of resolver reports, or nil if conf.Config.DNSResolverCheck is
+// not enabled.
+func newResolverLog(config *conf.Config) *dns.ResolverLog {
+	if !config.DNSResolverCheck {
+		return nil
+	}
+	return dns.NewResolverLog()
+}
+
+// serveResolverCheck runs the conf.Config.DNSResolverCheck name server, logging if it fails.
+func (s *DefaultServer) serveResolverCheck() {
+	var answers []net.IP
+	for _, addr := range s.Config.ResolverAnswers {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			log.Errorf("Ignoring invalid ResolverAnswers address %q", addr)
+			continue
+		}
+		answers = append(answers, ip)
+	}
+
+	listen := s.Config.ResolverListen
+	if listen == "" {
+		listen = defaultResolverListen
+	}
+
+	n := &dns.NameServer{
+		Zone:    s.Config.ResolverZone,
+		Answers: answers,
+		Log:     s.resolverLog,
+	}
+	if err := n.ListenAndServe(listen); err != nil {
+		log.Errorf("DNS resolver check stopped: %s", err)
+	}
+}
+
+// resolverReport returns the report of the resolver that looked up the hostname of the request's
+// "token" query parameter, or nil if there is none (or it's disabled).
+func (s *DefaultServer) resolverReport(req *http.Request) *dns.ResolverReport {
+	if s.resolverReports == nil || s.hostOverride(req) != "" {
+		return nil
+	}
+
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		return nil
+	}
+	return s.resolverReports.Report(token)
+}
@@ -0,0 +1,54 @@
+package myip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+)
+
+// fakeResolverReports are canned reports, keyed by token.
+type fakeResolverReports map[string]*dns.ResolverReport
+
+func (f fakeResolverReports) Report(token string) *dns.ResolverReport {
+	return f[token]
+}
+
+func TestMyIPHandlerResolverAddr(t *testing.T) {
+	s := newDefaultServer(&conf.Config{Debug: true})
+	s.resolverReports = fakeResolverReports{
+		"abc123": {Addr: "198.51.100.53", ClientSubnet: "203.0.113.0/24"},
+	}
+
+	data := []struct {
+		url        string
+		wantAddr   string
+		wantSubnet string
+	}{
+		{url: "/json?include=ua&token=abc123", wantAddr: "198.51.100.53", wantSubnet: "203.0.113.0/24"},
+		{url: "/json?include=ua&token=unknown"},
+		{url: "/json?include=ua"},
+		{url: "/json?include=ua&token=abc123&host=192.0.2.1"}, // Not the client's resolver
+	}
+
+	for _, test := range data {
+		got, err := s.MyIPHandler(httptest.NewRequest("GET", test.url, nil))
+		if err != nil {
+			t.Fatalf("MyIPHandler(%q) err = %s, want nil", test.url, err)
+		}
+		if got.ResolverAddr != test.wantAddr || got.ResolverClientSubnet != test.wantSubnet {
+			t.Errorf("MyIPHandler(%q) resolver = (%q, %q), want (%q, %q)",
+				test.url, got.ResolverAddr, got.ResolverClientSubnet, test.wantAddr, test.wantSubnet)
+		}
+	}
+}
+
+func TestResolverCheckDisabled(t *testing.T) {
+	if s := newDefaultServer(&conf.Config{}); s.resolverReports != nil {
+		t.Errorf("newDefaultServer() resolverReports = %v, want nil when DNSResolverCheck is disabled", s.resolverReports)
+	}
+	if s := newDefaultServer(&conf.Config{DNSResolverCheck: true}); s.resolverReports == nil {
+		t.Errorf("newDefaultServer(DNSResolverCheck) resolverReports = nil, want the resolver log")
+	}
+}
@@ -0,0 +1,55 @@
+package myip
+
+import "net"
+
+// The scopes of a address, as returned in Response.RemoteAddrScope.
+const (
+	scopeGlobal    = "global"
+	scopePrivate   = "private"    // RFC 1918 and RFC 4193 unique local addresses
+	scopeLoopback  = "loopback"   // e.g. 127.0.0.1 and ::1
+	scopeLinkLocal = "link-local" // e.g. 169.254.0.1 and fe80::1
+	scopeBogon     = "bogon"      // Reserved, so should never be seen on the internet
+)
+
+// bogons are the reserved ranges (other than the private, loopback and link-local ones) that
+// should never appear as a source address on the internet.
+var bogons = parseCIDRs([]string{
+	"0.0.0.0/8",       // "This" network
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // TEST-NET-1
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"224.0.0.0/4",     // Multicast
+	"240.0.0.0/4",     // Reserved, including broadcast
+	"::/128",          // Unspecified
+	"100::/64",        // Discard-only
+	"2001:db8::/32",   // Documentation
+	"ff00::/8",        // Multicast
+})
+
+// addressScope returns the scope of the address, one of the scope constants.
+func addressScope(ip net.IP) string {
+	switch {
+	case ip.IsLoopback():
+		return scopeLoopback
+	case isPrivate(ip):
+		return scopePrivate
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return scopeLinkLocal
+	}
+
+	for _, bogon := range bogons {
+		if bogon.Contains(ip) {
+			return scopeBogon
+		}
+	}
+	return scopeGlobal
+}
+
+// isLocalScope returns true if addresses in this scope are only meaningful on the local network,
+// so can't be located, or have a whois record.
+func isLocalScope(scope string) bool {
+	return scope == scopePrivate || scope == scopeLoopback || scope == scopeLinkLocal
+}
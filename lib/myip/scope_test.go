@@ -0,0 +1,83 @@
+package myip
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/location"
+	"bramp.net/myip/lib/whois"
+)
+
+func TestAddressScope(t *testing.T) {
+	data := []struct {
+		addr string
+		want string
+	}{
+		{"8.8.8.8", scopeGlobal},
+		{"2001:4860:4860::8888", scopeGlobal},
+		{"10.0.0.1", scopePrivate},
+		{"192.168.1.1", scopePrivate},
+		{"fd00::1", scopePrivate},
+		{"127.0.0.1", scopeLoopback},
+		{"::1", scopeLoopback},
+		{"169.254.0.1", scopeLinkLocal},
+		{"fe80::1", scopeLinkLocal},
+		{"100.64.0.1", scopeBogon},
+		{"192.0.2.1", scopeBogon},
+		{"2001:db8::1", scopeBogon},
+	}
+
+	for _, test := range data {
+		if got := addressScope(net.ParseIP(test.addr)); got != test.want {
+			t.Errorf("addressScope(%q) = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestMyIPHandlerPrivate(t *testing.T) {
+	data := []struct {
+		remoteAddr  string
+		wantScope   string
+		wantPrivate bool
+	}{
+		{remoteAddr: "10.0.0.1:1234", wantScope: scopePrivate, wantPrivate: true},
+		{remoteAddr: "127.0.0.1:1234", wantScope: scopeLoopback, wantPrivate: true},
+		{remoteAddr: "8.8.8.8:1234", wantScope: scopeGlobal, wantPrivate: false},
+	}
+
+	for _, test := range data {
+		whoisCalls, locationCalls := 0, 0
+
+		s := newDefaultServer(&conf.Config{})
+		s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+			whoisCalls++
+			return &whois.Response{Query: addr}
+		}
+		s.locator = location.ProviderFunc(func(ctx context.Context, ip net.IP) (*location.Response, error) {
+			locationCalls++
+			return &location.Response{}, nil
+		})
+
+		req := httptest.NewRequest("GET", "/json?include=whois,location", nil)
+		req.RemoteAddr = test.remoteAddr
+
+		resp, err := s.MyIPHandler(req)
+		if err != nil {
+			t.Fatalf("MyIPHandler(%q) err = %s, want nil", test.remoteAddr, err)
+		}
+		if resp.RemoteAddrScope != test.wantScope || resp.RemoteAddrIsPrivate != test.wantPrivate {
+			t.Errorf("MyIPHandler(%q) = (%q, %t), want (%q, %t)", test.remoteAddr, resp.RemoteAddrScope, resp.RemoteAddrIsPrivate, test.wantScope, test.wantPrivate)
+		}
+
+		wantCalls := 1
+		if test.wantPrivate {
+			wantCalls = 0
+		}
+		if whoisCalls != wantCalls || locationCalls != wantCalls {
+			t.Errorf("MyIPHandler(%q) made (%d, %d) whois and location lookups, want (%d, %d)", test.remoteAddr, whoisCalls, locationCalls, wantCalls, wantCalls)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package myip
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
+
+// SecurityPosture summarises how secure the client's connection to us is.
+type SecurityPosture struct {
+	// Grade is "A" (modern TLS, strong cipher, and HSTS), "B" (HTTPS, but missing one of those, or
+	// the TLS details are unknown as it was terminated by a proxy), "C" (TLS older than 1.2), or
+	// "F" (plain HTTP).
+	Grade string
+
+	HTTPS        bool // Reached us over HTTPS, either directly or via a proxy
+	ModernTLS    bool // TLS 1.2 or later
+	StrongCipher bool // Forward secret, and authenticated encryption
+	HSTS         bool // Strict-Transport-Security is sent on this connection
+}
+
+// securityPosture computes the SecurityPosture for this request.
+func (s *DefaultServer) securityPosture(req *http.Request) *SecurityPosture {
+	opts := secureOptions(s.Config)
+
+	p := &SecurityPosture{
+		HTTPS: req.TLS != nil || req.URL.Scheme == "https",
+	}
+	p.HSTS = p.HTTPS && opts.STSSeconds > 0 && !opts.IsDevelopment
+
+	if req.TLS != nil {
+		p.ModernTLS = req.TLS.Version >= tls.VersionTLS12
+		p.StrongCipher = isStrongCipher(req.TLS)
+	}
+
+	switch {
+	case !p.HTTPS:
+		p.Grade = "F"
+	case req.TLS != nil && !p.ModernTLS:
+		p.Grade = "C"
+	case p.ModernTLS && p.StrongCipher && p.HSTS:
+		p.Grade = "A"
+	default:
+		p.Grade = "B"
+	}
+
+	return p
+}
+
+// isStrongCipher returns true if the connection's cipher suite is forward secret and uses
+// authenticated encryption (AEAD).
+func isStrongCipher(state *tls.ConnectionState) bool {
+	if state.Version >= tls.VersionTLS13 {
+		return true // All TLS 1.3 suites are
+	}
+
+	name := tls.CipherSuiteName(state.CipherSuite)
+	return strings.Contains(name, "_ECDHE_") &&
+		(strings.Contains(name, "_GCM_") || strings.Contains(name, "_CHACHA20_"))
+}
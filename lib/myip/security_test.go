@@ -0,0 +1,60 @@
+package myip
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestSecurityPosture(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+
+	data := []struct {
+		url  string
+		tls  *tls.ConnectionState
+		want *SecurityPosture
+	}{
+		{
+			url:  "http://example.com/",
+			want: &SecurityPosture{Grade: "F"},
+		}, {
+			url: "https://example.com/",
+			tls: &tls.ConnectionState{
+				Version:     tls.VersionTLS13,
+				CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+			},
+			want: &SecurityPosture{Grade: "A", HTTPS: true, ModernTLS: true, StrongCipher: true, HSTS: true},
+		}, {
+			url: "https://example.com/",
+			tls: &tls.ConnectionState{
+				Version:     tls.VersionTLS12,
+				CipherSuite: tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+			},
+			want: &SecurityPosture{Grade: "B", HTTPS: true, ModernTLS: true, HSTS: true},
+		}, {
+			url: "https://example.com/",
+			tls: &tls.ConnectionState{
+				Version:     tls.VersionTLS10,
+				CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			},
+			want: &SecurityPosture{Grade: "C", HTTPS: true, HSTS: true},
+		}, {
+			// Terminated by a proxy, so we don't know the TLS details.
+			url:  "https://example.com/",
+			want: &SecurityPosture{Grade: "B", HTTPS: true, HSTS: true},
+		},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.TLS = test.tls
+
+		got := s.securityPosture(req)
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("securityPosture(%q, %+v) diff: (-got +want)\n%s", test.url, test.tls, diff)
+		}
+	}
+}
@@ -1,12 +1,25 @@
 package myip
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"bramp.net/myip/lib/asn"
+	"bramp.net/myip/lib/cache"
 	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/location"
+	"bramp.net/myip/lib/refresh"
+	"bramp.net/myip/lib/whois"
 	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 	"github.com/unrolled/secure"
+	"golang.org/x/sync/singleflight"
 )
 
 // Server is the interface all instances of the myip application should implement.
@@ -15,6 +28,15 @@ type Server interface {
 
 	MyIPHandler(req *http.Request) (*Response, error)
 
+	// Index page, in the format chosen by the Accept header
+	IndexHandler(w http.ResponseWriter, req *http.Request)
+
+	// Health check for load balancers
+	HealthzHandler(w http.ResponseWriter, req *http.Request)
+
+	// Just the client's address, as plain text
+	IPHandler(w http.ResponseWriter, req *http.Request)
+
 	// TODO Merge CLI and JSON together, and use a different marshallers.
 	// CLI index page
 	CLIHandler(w http.ResponseWriter, req *http.Request)
@@ -22,8 +44,41 @@ type Server interface {
 	// JSON index page
 	JSONHandler(w http.ResponseWriter, req *http.Request)
 
+	// XML index page
+	XMLHandler(w http.ResponseWriter, req *http.Request)
+
+	// YAML index page
+	YAMLHandler(w http.ResponseWriter, req *http.Request)
+
+	// Server-Sent Events of each lookup, as they complete
+	EventsHandler(w http.ResponseWriter, req *http.Request)
+
+	// Newline delimited JSON of the lookups of each posted address, as they complete
+	BatchHandler(w http.ResponseWriter, req *http.Request)
+
 	// Web-app config
 	ConfigJSHandler(w http.ResponseWriter, _ *http.Request)
+
+	// Just the AS number
+	ASNHandler(w http.ResponseWriter, req *http.Request)
+
+	// Just the reverse DNS
+	DNSHandler(w http.ResponseWriter, req *http.Request)
+
+	// HTML fragment for embedding in other sites
+	EmbedHandler(w http.ResponseWriter, req *http.Request)
+
+	// Internal stats, such as when data sources were last refreshed
+	StatsHandler(w http.ResponseWriter, req *http.Request)
+
+	// The build's version
+	VersionHandler(w http.ResponseWriter, req *http.Request)
+
+	// The effective config, only available in debug mode or with the debug token
+	DebugConfigHandler(w http.ResponseWriter, req *http.Request)
+
+	// The geo location data's metadata, only available in debug mode or with the debug token
+	DebugGeoDataHandler(w http.ResponseWriter, req *http.Request)
 }
 
 const host = "Host"
@@ -31,6 +86,97 @@ const host = "Host"
 // DefaultServer is a default implementation of Server with some good defaults.
 type DefaultServer struct {
 	Config *conf.Config
+
+	// Refresher periodically reloads any local data sources.
+	Refresher *refresh.Scheduler
+
+	orgOverrides   []orgOverride
+	trustedProxies []*net.IPNet
+	correlator     *correlator
+	countries      *countryHistory
+	throttler      *throttler
+	rateLimiter    *throttler
+	metrics        *metrics
+	whois          *whois.Client
+	dnsCache       *cache.Cache     // nil if disabled
+	whoisCache     *cache.Cache     // nil if disabled
+	resolverLog    *dns.ResolverLog // nil if disabled, see conf.Config.DNSResolverCheck
+	nodeName       string           // See conf.Config.NodeName
+
+	// The lookups, which can be replaced in tests.
+	reverseDNS  func(ctx context.Context, addr string) *dns.Response
+	whoisLookup func(ctx context.Context, addr string) *whois.Response
+	asnLookup   func(ctx context.Context, addr string) *asn.Response
+
+	// resolverReports is the resolverLog, or nil if disabled.
+	resolverReports dns.ResolverReports
+
+	// locator is the conf.Config.LocationProvider.
+	locator location.Provider
+
+	// isCLI matches requests from cli tools, see conf.Config.CLIUserAgents.
+	isCLI mux.MatcherFunc
+
+	// static serves the web-app's static files.
+	static http.Handler
+
+	// flight deduplicates concurrent lookups for the same address.
+	flight singleflight.Group
+}
+
+// newDefaultServer returns a DefaultServer for this config.
+func newDefaultServer(config *conf.Config) *DefaultServer {
+	s := &DefaultServer{
+		Config:    config,
+		Refresher: refresh.NewScheduler(config.RefreshInterval),
+
+		orgOverrides:   parseOrgOverrides(config.Organizations),
+		trustedProxies: parseCIDRs(config.TrustedProxies),
+		correlator:     newCorrelator(config),
+		countries:      newCountryHistory(config),
+		throttler:      newThrottler(config),
+		rateLimiter:    newRateThrottler(config.RateLimit, config.RateLimitBurst),
+		metrics:        newMetrics(),
+		whois:          whois.NewClient(config),
+		dnsCache:       newDNSCache(config),
+		whoisCache:     newWhoisCache(config),
+		resolverLog:    newResolverLog(config),
+		nodeName:       nodeName(config),
+
+		reverseDNS: dns.HandleReverseDNS,
+		asnLookup:  asn.Handle,
+
+		isCLI:  newCLIMatcher(config.CLIUserAgents),
+		static: http.FileServer(http.Dir("./static/")),
+	}
+	s.whoisLookup = s.whois.Handle
+	s.locator = newLocator(config, s.Refresher) // Registers any location database to be refreshed
+	if s.resolverLog != nil {
+		s.resolverReports = s.resolverLog
+	}
+	if config.VerifyReverseDNS {
+		s.reverseDNS = dns.HandleVerifiedReverseDNS
+	}
+	return s
+}
+
+// newLocator returns the configured location.Provider, with any database reloaded by the
+// refresher. If it can't be created (e.g. the database is missing), the error is logged, and
+// locations are omitted (until a refresh loads the database), instead of failing every request.
+func newLocator(config *conf.Config, refresher *refresh.Scheduler) location.Provider {
+	locator, err := location.NewProvider(config, refresher)
+	if err != nil {
+		log.Errorf("Failed to create the location provider: %s", err)
+	}
+	if locator == nil {
+		return location.NoopProvider{}
+	}
+	return locator
+}
+
+// locate returns the location of the address, or nil if it could not be determined.
+func (s *DefaultServer) locate(ctx context.Context, req *http.Request, addr string) *location.Response {
+	return location.Handle(ctx, s.locator, s.Config, req, net.ParseIP(addr))
 }
 
 // URLHeaders sets both the scheme and host in the Request.URL
@@ -58,63 +204,263 @@ func URLHeaders(h http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
-// Register this myip.Server. Should only be called once.
-func Register(r *mux.Router, config *conf.Config) { // TODO Refactor so we don't need config here
-	app := &DefaultServer{
-		Config: config,
+// defaultSTSSeconds is the Strict-Transport-Security max-age, if conf.Config.STSSeconds is unset.
+const defaultSTSSeconds = 365 * 24 * 60 * 60
+
+// secureOptions returns the security settings (HSTS, CSP, etc) for this config.
+func secureOptions(config *conf.Config) secure.Options {
+	sts := config.STSSeconds
+	switch {
+	case sts == 0:
+		sts = defaultSTSSeconds
+	case sts < 0:
+		sts = 0 // Omits the header
 	}
 
 	// Documented here: https://godoc.org/github.com/unrolled/secure#Options
-	secureConfig := secure.Options{
+	return secure.Options{
 		IsDevelopment: config.Debug,
 
-		SSLRedirect: true,
+		SSLRedirect: !config.DisableSSLRedirect,
 		SSLHost:     "", // Use same host
 
 		// Ensure the client is using HTTPS
-		STSSeconds:           365 * 24 * 60 * 60,
+		STSSeconds:           sts,
 		STSIncludeSubdomains: true,
-		STSPreload:           true,
+		STSPreload:           !config.DisableSTSPreload,
+
+		// Don't allow the page embedded in a frame.
+		FrameDeny: !config.DisableFrameDeny,
 
-		FrameDeny:          true, // Don't allow the page embedded in a frame.
 		ContentTypeNosniff: true, // Trust the Content-Type and don't second guess them.
 		BrowserXssFilter:   true,
 
-		// TODO Find CSP generator to make the next line shorter, and less error prone
-		ContentSecurityPolicy: "default-src 'self';" +
-			" connect-src *;" +
-			" script-src 'self' www.google-analytics.com;" +
-			" img-src data: 'self' www.google-analytics.com maps.googleapis.com;",
+		ContentSecurityPolicy: contentSecurityPolicy(config),
+	}
+}
+
+// defaultAnalyticsHosts are used if conf.Config.AnalyticsHosts is empty.
+var defaultAnalyticsHosts = []string{"www.google-analytics.com"}
+
+// contentSecurityPolicy returns the configured Content-Security-Policy, or one allowing the
+// web-app's analytics and maps hosts.
+func contentSecurityPolicy(config *conf.Config) string {
+	if config.ContentSecurityPolicy != "" {
+		return config.ContentSecurityPolicy
+	}
+
+	analytics := config.AnalyticsHosts
+	if len(analytics) == 0 {
+		analytics = defaultAnalyticsHosts
+	}
+
+	img := append([]string{"data:", "'self'"}, analytics...)
+	if config.MapsAPIKey != "" {
+		img = append(img, "maps.googleapis.com")
+	}
+
+	return "default-src 'self';" +
+		" connect-src *;" +
+		" script-src " + strings.Join(append([]string{"'self'"}, analytics...), " ") + ";" +
+		" img-src " + strings.Join(img, " ") + ";"
+}
+
+// defaultMethods are the methods allowed on a endpoint, unless it's registered with its own.
+var defaultMethods = []string{http.MethodGet, http.MethodHead}
+
+// endpointRegistrar returns a function that registers the handler for a path, unless the path is
+// one of the config's DisabledEndpoints, which are registered to 404 instead. They must still be
+// registered, as otherwise the request would fall through to the CLI handler or the static files.
+// The handler only serves the given methods (by default GET and HEAD), and any other method is 405
+// Method Not Allowed.
+func endpointRegistrar(r *mux.Router, config *conf.Config) func(path string, f http.HandlerFunc, methods ...string) {
+	disabled := make(map[string]bool)
+	for _, path := range config.DisabledEndpoints {
+		disabled[path] = true
 	}
 
+	return func(path string, f http.HandlerFunc, methods ...string) {
+		if disabled[path] {
+			r.HandleFunc(path, http.NotFound)
+			return
+		}
+		if len(methods) == 0 {
+			methods = defaultMethods
+		}
+		r.HandleFunc(path, f).Methods(methods...)
+
+		// Explicitly, as otherwise other methods fall through to the static files. This also
+		// means the middleware (such as the CORS preflight) still runs for them.
+		r.HandleFunc(path, methodNotAllowed(methods))
+	}
+}
+
+// methodNotAllowed returns a handler rejecting the request, as only the methods are allowed.
+func methodNotAllowed(methods []string) http.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// Register this myip.Server. Should only be called once. Any middleware (such as the embedder's
+// own auth or tracing) is applied in order, after the built-in middleware, just before the
+// endpoints.
+func Register(r *mux.Router, config *conf.Config, middleware ...mux.MiddlewareFunc) { // TODO Refactor so we don't need config here
+	app := newDefaultServer(config)
+	app.Refresher.Start()
+	if config.DNSResolverCheck {
+		go app.serveResolverCheck()
+	}
+
+	register(r, app, config, middleware...)
+}
+
+// register the middleware and endpoints of app on the router, followed by the extra middleware.
+func register(r *mux.Router, app *DefaultServer, config *conf.Config, middleware ...mux.MiddlewareFunc) {
+	r.Use(RequestID(config))
+	r.Use(app.servedBy)
 	r.Use(URLHeaders)
-	r.Use(secure.New(secureConfig).Handler)
+	r.Use(MaxHeaderBytes(config))
+	// The streaming endpoints may rightly take longer, and can't be buffered
+	r.Use(exempt(RequestTimeout(config), healthzPath, "/events", "/batch"))
+	if config.CompressResponses {
+		// The plain text endpoints are tiny, so not worth compressing
+		r.Use(exempt(Compress(config), "/ip", healthzPath))
+	}
+	// Health checks are often over plain HTTP, so must not be redirected
+	r.Use(exempt(secure.New(secureOptions(config)).Handler, healthzPath))
+	r.Use(app.corsPreflight) // Before the rate limit, as preflights are cheap
+	r.Use(exempt(app.rateLimit, healthzPath))
+	r.Use(middleware...)
 
-	// Fetching with `curl`
-	r.MatcherFunc(isCLI).HandlerFunc(app.CLIHandler)
+	// The endpoints are registered before the CLI matcher, so they work the same with `curl`
+	handle := endpointRegistrar(r, config)
+	handle("/", app.IndexHandler)
+	handle(healthzPath, app.HealthzHandler)
+	handle("/ip", app.IPHandler)
+	handle("/json", app.JSONHandler)
+	handle("/xml", app.XMLHandler)
+	handle("/yaml", app.YAMLHandler)
+	handle("/events", app.EventsHandler)
+	handle("/batch", app.BatchHandler, http.MethodPost)
+	handle("/config.js", app.ConfigJSHandler)
+	handle("/embed", app.EmbedHandler)
+	handle("/asn", app.ASNHandler)
+	handle("/dns", app.DNSHandler)
+	handle("/stats", app.StatsHandler)
+	handle("/version", app.VersionHandler)
+	handle("/debug/config", app.DebugConfigHandler)
+	handle("/debug/geodata", app.DebugGeoDataHandler)
+	if config.MetricsEnabled {
+		handle("/metrics", app.metrics.handler().ServeHTTP)
+	}
 
-	r.HandleFunc("/json", app.JSONHandler)
-	r.HandleFunc("/config.js", app.ConfigJSHandler)
+	// Fetching with `curl`
+	r.MatcherFunc(app.isCLI).HandlerFunc(app.CLIHandler)
 
 	// Serve the static content
-	fs := http.FileServer(http.Dir("./static/"))
-	r.PathPrefix("/").Handler(fs)
+	r.PathPrefix("/").Handler(app.static).Name(staticRoute)
 }
 
-// GetRemoteAddr returns the remote address, either the real one, or if in debug mode one passed as a query param.
+// NewServer registers myip on the router (see Register), and returns a http.Server serving it.
+// All requests, except health checks, are logged to out in the conf.Config.LogFormat.
+func NewServer(r *mux.Router, config *conf.Config, out io.Writer, middleware ...mux.MiddlewareFunc) *http.Server {
+	Register(r, config, middleware...)
+
+	return &http.Server{
+		Handler: WithoutHealthz(AccessLogHandler(config, out, r), r),
+
+		// The MaxHeaderBytes middleware gives the exact limit, this just stops the server from
+		// reading more.
+		MaxHeaderBytes: config.MaxHeaderBytes,
+
+		// Tag each connection, so HTTP/2 requests can be associated in debug mode.
+		ConnContext: ConnContext,
+	}
+}
+
+// Shutdown gracefully shuts down the server, that is it stops accepting new connections, and waits
+// for the in-flight requests (such as slow whois lookups) to finish. If ctx is done first, the
+// remaining connections are closed, and ctx's error returned.
+func Shutdown(ctx context.Context, s *http.Server) error {
+	if err := s.Shutdown(ctx); err != nil {
+		s.Close()
+		return err
+	}
+	return nil
+}
+
+// InvalidIPError is returned when the client's address is not a valid IP address.
+type InvalidIPError struct {
+	Value string
+}
+
+func (e *InvalidIPError) Error() string {
+	return fmt.Sprintf("invalid IP address %q", e.Value)
+}
+
+// GetRemoteAddr returns the remote address, either the real one (taken from the first of the
+// configured IPHeaders present), or if in debug mode one passed as a query param. A InvalidIPError
+// is returned if the address is not a valid IP address. The address is returned in its canonical
+// form, so IPv6 addresses are compressed (e.g. "2001:db8::1"), and IPv4-mapped IPv6 addresses
+// (e.g. "::ffff:192.0.2.1") are returned as IPv4.
 func (s *DefaultServer) GetRemoteAddr(req *http.Request) (string, error) {
+	host := s.getRemoteAddr(req)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", &InvalidIPError{host}
+	}
+	return ip.String(), nil // Formats IPv4-mapped addresses as IPv4
+}
+
+// GetRemotePort returns the client's source port, or 0 if it's not known. The port is only known
+// for direct connections, as when the address came from a proxy's header (or the "host" override)
+// the observed port is the proxy's.
+func (s *DefaultServer) GetRemotePort(req *http.Request) int {
+	if s.hostOverride(req) != "" {
+		return 0
+	}
+	if hops, _ := s.forwardedHops(req); len(hops) > 0 {
+		return 0
+	}
+
+	_, port, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return 0
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return 0
+	}
+	return p
+}
+
+// hostOverride returns the address passed as the "host" query param, only if in debug mode.
+func (s *DefaultServer) hostOverride(req *http.Request) string {
+	if !s.Config.Debug {
+		return ""
+	}
+	return req.URL.Query().Get("host")
+}
+
+func (s *DefaultServer) getRemoteAddr(req *http.Request) string {
 	// If debug allow replacing the host
-	if host := req.URL.Query().Get("host"); host != "" && s.Config.Debug {
-		return host, nil
+	if host := s.hostOverride(req); host != "" {
+		return host
+	}
+
+	if hops, _ := s.forwardedHops(req); len(hops) > 0 {
+		return s.clientHop(hops)
 	}
 
 	// Some systems (namely App Engine Flex) encode the remoteAddr with a port
 	host, _, err := net.SplitHostPort(req.RemoteAddr)
 	if err != nil {
 		// For now assume the RemoteAddr was just a addr (with no port)
-		// TODO check if remoteAddr is a valid IPv6/IPv4 address
-		return req.RemoteAddr, nil
+		return req.RemoteAddr
 	}
 
-	return host, err
+	return host
 }
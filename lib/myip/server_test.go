@@ -0,0 +1,821 @@
+package myip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestGetRemoteAddr(t *testing.T) {
+	data := []struct {
+		url        string
+		remoteAddr string
+		header     string // Value of X-Forwarded-For
+		want       string
+		wantErr    bool
+	}{
+		{url: "/", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{url: "/", remoteAddr: "192.0.2.1", want: "192.0.2.1"},
+		{url: "/", remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		{url: "/", remoteAddr: "192.0.2.1:1234", header: "198.51.100.1", want: "198.51.100.1"},
+		{url: "/?host=203.0.113.1", remoteAddr: "192.0.2.1:1234", want: "203.0.113.1"},
+		{url: "/", remoteAddr: "[::ffff:192.0.2.1]:1234", want: "192.0.2.1"},
+		{url: "/", remoteAddr: "192.0.2.1:1234", header: "::ffff:198.51.100.1", want: "198.51.100.1"},
+
+		// Invalid addresses
+		{url: "/", remoteAddr: "", wantErr: true},
+		{url: "/", remoteAddr: "192.0.2", wantErr: true},
+		{url: "/", remoteAddr: "192.0.2.1:1234", header: "garbage", wantErr: true},
+		{url: "/?host=example.com", remoteAddr: "192.0.2.1:1234", wantErr: true},
+	}
+
+	s := newDefaultServer(&conf.Config{
+		Debug:    true,
+		IPHeader: "X-Forwarded-For",
+	})
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.header != "" {
+			req.Header.Set("X-Forwarded-For", test.header)
+		}
+
+		got, err := s.GetRemoteAddr(req)
+		if test.wantErr {
+			var invalid *InvalidIPError
+			if !errors.As(err, &invalid) {
+				t.Errorf("GetRemoteAddr(%q, %q, %q) err = %v, want InvalidIPError", test.url, test.remoteAddr, test.header, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("GetRemoteAddr(%q, %q, %q) = (%q, %v), want (%q, nil)", test.url, test.remoteAddr, test.header, got, err, test.want)
+		}
+	}
+}
+
+func TestGetRemoteAddrIPHeaders(t *testing.T) {
+	data := []struct {
+		config *conf.Config
+		header map[string]string
+		want   string
+	}{
+		{
+			config: &conf.Config{IPHeaders: []string{"Cf-Connecting-Ip", "True-Client-Ip", "X-Forwarded-For"}},
+			header: map[string]string{"Cf-Connecting-Ip": "203.0.113.1", "True-Client-Ip": "203.0.113.2", "X-Forwarded-For": "203.0.113.3"},
+			want:   "203.0.113.1",
+		}, {
+			config: &conf.Config{IPHeaders: []string{"Cf-Connecting-Ip", "True-Client-Ip", "X-Forwarded-For"}},
+			header: map[string]string{"Cf-Connecting-Ip": "", "True-Client-Ip": "203.0.113.2", "X-Forwarded-For": "203.0.113.3"},
+			want:   "203.0.113.2",
+		}, {
+			config: &conf.Config{IPHeaders: []string{"cf-connecting-ip", "X-Forwarded-For"}},
+			header: map[string]string{"X-Forwarded-For": "203.0.113.3, 198.51.100.1"},
+			want:   "203.0.113.3",
+		}, {
+			// None present, so the connection's address.
+			config: &conf.Config{IPHeaders: []string{"Cf-Connecting-Ip", "X-Forwarded-For"}},
+			want:   "192.0.2.1",
+		}, {
+			// The legacy single header.
+			config: &conf.Config{IPHeader: "X-Forwarded-For"},
+			header: map[string]string{"Cf-Connecting-Ip": "203.0.113.1", "X-Forwarded-For": "203.0.113.3"},
+			want:   "203.0.113.3",
+		}, {
+			// IPHeaders takes precedence over the legacy IPHeader.
+			config: &conf.Config{IPHeader: "X-Forwarded-For", IPHeaders: []string{"Cf-Connecting-Ip"}},
+			header: map[string]string{"Cf-Connecting-Ip": "203.0.113.1", "X-Forwarded-For": "203.0.113.3"},
+			want:   "203.0.113.1",
+		}, {
+			config: &conf.Config{},
+			header: map[string]string{"X-Forwarded-For": "203.0.113.3"},
+			want:   "192.0.2.1",
+		},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(test.config)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		for name, value := range test.header {
+			req.Header.Set(name, value)
+		}
+
+		got, err := s.GetRemoteAddr(req)
+		if err != nil || got != test.want {
+			t.Errorf("GetRemoteAddr(%q, headers %v) = (%q, %v), want (%q, nil)", test.header, test.config.IPHeaders, got, err, test.want)
+		}
+	}
+}
+
+func TestGetRemoteAddrTrustedHeaderSecret(t *testing.T) {
+	data := []struct {
+		name   string
+		config *conf.Config
+		header map[string]string
+		want   string
+	}{
+		{
+			name:   "spoofed without secret",
+			config: &conf.Config{IPHeader: "X-Forwarded-For", TrustedHeaderSecret: "s3cret"},
+			header: map[string]string{"X-Forwarded-For": "203.0.113.1"},
+			want:   "192.0.2.1",
+		}, {
+			name:   "spoofed with wrong secret",
+			config: &conf.Config{IPHeader: "X-Forwarded-For", TrustedHeaderSecret: "s3cret"},
+			header: map[string]string{"X-Forwarded-For": "203.0.113.1", "X-Proxy-Secret": "guess"},
+			want:   "192.0.2.1",
+		}, {
+			name:   "legitimate with secret",
+			config: &conf.Config{IPHeader: "X-Forwarded-For", TrustedHeaderSecret: "s3cret"},
+			header: map[string]string{"X-Forwarded-For": "203.0.113.1", "X-Proxy-Secret": "s3cret"},
+			want:   "203.0.113.1",
+		}, {
+			name:   "custom secret header",
+			config: &conf.Config{IPHeader: "X-Forwarded-For", TrustedHeaderSecret: "s3cret", TrustedSecretHeader: "X-Origin-Auth"},
+			header: map[string]string{"X-Forwarded-For": "203.0.113.1", "X-Origin-Auth": "s3cret"},
+			want:   "203.0.113.1",
+		}, {
+			name:   "no secret configured",
+			config: &conf.Config{IPHeader: "X-Forwarded-For"},
+			header: map[string]string{"X-Forwarded-For": "203.0.113.1"},
+			want:   "203.0.113.1",
+		},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(test.config)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		for name, value := range test.header {
+			req.Header.Set(name, value)
+		}
+
+		got, err := s.GetRemoteAddr(req)
+		if err != nil || got != test.want {
+			t.Errorf("%s: GetRemoteAddr(%q) = (%q, %v), want (%q, nil)", test.name, test.header, got, err, test.want)
+		}
+	}
+}
+
+func TestGetRemotePort(t *testing.T) {
+	data := []struct {
+		url        string
+		remoteAddr string
+		header     string // Value of X-Forwarded-For
+		want       int
+	}{
+		{url: "/", remoteAddr: "203.0.113.9:54321", want: 54321},
+		{url: "/", remoteAddr: "[2001:db8::1]:1234", want: 1234},
+		{url: "/", remoteAddr: "203.0.113.9", want: 0},
+		{url: "/", remoteAddr: "203.0.113.9:http", want: 0},
+		{url: "/", remoteAddr: "192.0.2.1:1234", header: "198.51.100.1", want: 0},
+		{url: "/?host=203.0.113.1", remoteAddr: "192.0.2.1:1234", want: 0},
+	}
+
+	s := newDefaultServer(&conf.Config{
+		Debug:    true,
+		IPHeader: "X-Forwarded-For",
+	})
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.header != "" {
+			req.Header.Set("X-Forwarded-For", test.header)
+		}
+
+		if got := s.GetRemotePort(req); got != test.want {
+			t.Errorf("GetRemotePort(%q, %q, %q) = %d, want %d", test.url, test.remoteAddr, test.header, got, test.want)
+		}
+	}
+}
+
+func TestMyIPHandlerRemotePort(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+
+	req := httptest.NewRequest("GET", "/json?include=none", nil)
+	req.RemoteAddr = "203.0.113.9:54321"
+
+	got, err := s.MyIPHandler(req)
+	if err != nil {
+		t.Fatalf("MyIPHandler(...) err = %s, want nil", err)
+	}
+	if got.RemoteAddrPort != 54321 {
+		t.Errorf("MyIPHandler(...).RemoteAddrPort = %d, want %d", got.RemoteAddrPort, 54321)
+	}
+}
+
+func TestMyIPHandlerKeepActualRemotePort(t *testing.T) {
+	data := []struct {
+		keepPort   bool
+		header     string // Value of X-Forwarded-For
+		wantActual string
+		wantPort   int
+	}{
+		{keepPort: false, wantActual: "203.0.113.9", wantPort: 54321},
+		{keepPort: true, wantActual: "203.0.113.9:54321", wantPort: 54321},
+		{keepPort: false, header: "198.51.100.1", wantActual: "203.0.113.9", wantPort: 0},
+		{keepPort: true, header: "198.51.100.1", wantActual: "203.0.113.9:54321", wantPort: 0}, // The proxy's port
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{
+			IPHeader:             "X-Forwarded-For",
+			KeepActualRemotePort: test.keepPort,
+		})
+
+		req := httptest.NewRequest("GET", "/json?include=none", nil)
+		req.RemoteAddr = "203.0.113.9:54321"
+		if test.header != "" {
+			req.Header.Set("X-Forwarded-For", test.header)
+		}
+
+		got, err := s.MyIPHandler(req)
+		if err != nil {
+			t.Fatalf("MyIPHandler(KeepActualRemotePort %t, %q) err = %s, want nil", test.keepPort, test.header, err)
+		}
+		if got.ActualRemoteAddr != test.wantActual || got.RemoteAddrPort != test.wantPort {
+			t.Errorf("MyIPHandler(KeepActualRemotePort %t, %q) = (ActualRemoteAddr %q, RemoteAddrPort %d), want (%q, %d)",
+				test.keepPort, test.header, got.ActualRemoteAddr, got.RemoteAddrPort, test.wantActual, test.wantPort)
+		}
+	}
+}
+
+func TestMyIPHandlerIPv4Mapped(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+
+	req := httptest.NewRequest("GET", "/json?include=none", nil)
+	req.RemoteAddr = "[::ffff:192.0.2.1]:1234"
+
+	got, err := s.MyIPHandler(req)
+	if err != nil {
+		t.Fatalf("MyIPHandler(%q) err = %s, want nil", req.RemoteAddr, err)
+	}
+	if got.RemoteAddr != "192.0.2.1" || got.RemoteAddrFamily != "IPv4" {
+		t.Errorf("MyIPHandler(%q) = (%q, %q), want (%q, %q)", req.RemoteAddr, got.RemoteAddr, got.RemoteAddrFamily, "192.0.2.1", "IPv4")
+	}
+}
+
+func TestMyIPHandlerCanonicalIPv6(t *testing.T) {
+	s := newDefaultServer(&conf.Config{IPHeader: "X-Forwarded-For"})
+
+	data := []struct {
+		remoteAddr string
+		header     string // Value of X-Forwarded-For
+		want       string
+	}{
+		{remoteAddr: "[2001:0db8:0000:0000:0000:0000:0000:0001]:1234", want: "2001:db8::1"},
+		{remoteAddr: "[2001:DB8::A]:1234", want: "2001:db8::a"},
+		{remoteAddr: "192.0.2.1:1234", header: "2001:0db8:0:0:0:0:0:0001", want: "2001:db8::1"},
+		{remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "/json?include=none", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.header != "" {
+			req.Header.Set("X-Forwarded-For", test.header)
+		}
+
+		got, err := s.MyIPHandler(req)
+		if err != nil {
+			t.Fatalf("MyIPHandler(%q, %q) err = %s, want nil", test.remoteAddr, test.header, err)
+		}
+		if got.RemoteAddr != test.want {
+			t.Errorf("MyIPHandler(%q, %q).RemoteAddr = %q, want %q", test.remoteAddr, test.header, got.RemoteAddr, test.want)
+		}
+	}
+}
+
+func TestJSONHandlerInvalidIP(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Debug: true,
+	})
+
+	req := httptest.NewRequest("GET", "/json?host=example.com", nil)
+	w := httptest.NewRecorder()
+	s.JSONHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("JSONHandler(%q) code = %d, want %d", req.URL, w.Code, http.StatusBadRequest)
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("JSONHandler(%q) Content-Type = %q, want %q", req.URL, got, want)
+	}
+
+	var got ErrResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("JSONHandler(%q) returned invalid json: %s", req.URL, err)
+	}
+	want := ErrResponse{
+		Error: `getting remote addr: invalid IP address "example.com"`,
+		Code:  "INVALID_IP",
+		Value: "example.com",
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("JSONHandler(%q) diff: (-got +want)\n%s", req.URL, diff)
+	}
+}
+
+func TestJSONHandlerEnvelope(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Debug:            true,
+		ResponseEnvelope: true,
+	})
+
+	req := httptest.NewRequest("GET", "/json?host=example.com", nil)
+	req.Header.Set("X-Request-Id", "request-1")
+	w := httptest.NewRecorder()
+	s.JSONHandler(w, req)
+
+	var got Envelope
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("JSONHandler(%q) returned invalid json: %s", req.URL, err)
+	}
+	if got.Data != nil || got.Error == nil || got.Error.Code != codeInvalidIP {
+		t.Errorf("JSONHandler(%q) = %+v, want a %s error", req.URL, got, codeInvalidIP)
+	}
+	if got.Meta == nil || got.Meta.ServerTime.IsZero() || got.Meta.RequestID != "request-1" {
+		t.Errorf("JSONHandler(%q) Meta = %+v, want the server time, and RequestID %q", req.URL, got.Meta, "request-1")
+	}
+}
+
+func TestJSONHandlerEnvelopeSuccess(t *testing.T) {
+	s := newSlowServer(&conf.Config{ResponseEnvelope: true}, 0, 0, 0)
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.RemoteAddr = "203.0.113.1:1234"
+	req.Header.Set("X-Request-Id", "request-1")
+	w := httptest.NewRecorder()
+	s.JSONHandler(w, req)
+
+	var got struct {
+		Data  *Response
+		Error *ErrResponse
+		Meta  *Meta
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("JSONHandler(%q) returned invalid json: %s", req.URL, err)
+	}
+	if got.Error != nil || got.Data == nil || got.Data.RemoteAddr != "203.0.113.1" {
+		t.Errorf("JSONHandler(%q) = %+v, want the data for %q", req.URL, got, "203.0.113.1")
+	}
+	if got.Data != nil && got.Data.RequestID != "" {
+		t.Errorf("JSONHandler(%q) Data.RequestID = %q, want it moved to the Meta", req.URL, got.Data.RequestID)
+	}
+	if got.Meta == nil || got.Meta.RequestID != "request-1" {
+		t.Errorf("JSONHandler(%q) Meta = %+v, want RequestID %q", req.URL, got.Meta, "request-1")
+	}
+}
+
+func TestJSONHandlerCallback(t *testing.T) {
+	data := []struct {
+		allowJSONP      bool
+		url             string
+		wantCode        int
+		wantContentType string
+		wantPrefix      string
+	}{
+		{allowJSONP: true, url: "/json", wantCode: http.StatusOK, wantContentType: "application/json", wantPrefix: "{"},
+		{allowJSONP: true, url: "/json?callback=my_Func$1", wantCode: http.StatusOK, wantContentType: "application/javascript", wantPrefix: "my_Func$1({"},
+		{allowJSONP: true, url: "/json?callback=alert(1)//", wantCode: http.StatusBadRequest, wantContentType: "application/json", wantPrefix: "{\"error\":\"invalid callback name\",\"code\":\"INVALID_CALLBACK\"}\n"},
+		{allowJSONP: true, url: "/json?callback=1abc", wantCode: http.StatusBadRequest, wantContentType: "application/json", wantPrefix: "{\"error\":\"invalid callback name\",\"code\":\"INVALID_CALLBACK\"}\n"},
+
+		// Unless allowed, the callback is ignored, as JSONP bypasses the AllowedOrigins.
+		{url: "/json?callback=my_Func$1", wantCode: http.StatusOK, wantContentType: "application/json", wantPrefix: "{"},
+		{url: "/json?callback=alert(1)//", wantCode: http.StatusOK, wantContentType: "application/json", wantPrefix: "{"},
+	}
+
+	for _, test := range data {
+		s := newSlowServer(&conf.Config{AllowJSONP: test.allowJSONP}, 0, 0, 0)
+		req := httptest.NewRequest("GET", test.url, nil)
+		w := httptest.NewRecorder()
+		s.JSONHandler(w, req)
+
+		if w.Code != test.wantCode {
+			t.Errorf("JSONHandler(%q) AllowJSONP %t code = %d, want %d", test.url, test.allowJSONP, w.Code, test.wantCode)
+		}
+		if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+			t.Errorf("JSONHandler(%q) AllowJSONP %t Content-Type = %q, want %q", test.url, test.allowJSONP, got, test.wantContentType)
+		}
+		if got := w.Body.String(); !strings.HasPrefix(got, test.wantPrefix) {
+			t.Errorf("JSONHandler(%q) AllowJSONP %t = %q, want prefix %q", test.url, test.allowJSONP, got, test.wantPrefix)
+		}
+	}
+}
+
+func TestWriteJSONIgnoresCallback(t *testing.T) {
+	s := newDefaultServer(&conf.Config{AllowJSONP: true})
+
+	// Only the /json endpoint supports JSONP.
+	req := httptest.NewRequest("GET", "/stats?callback=my_Func", nil)
+	w := httptest.NewRecorder()
+	s.writeJSON(w, req, map[string]int{"a": 1})
+
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("writeJSON(%q) Content-Type = %q, want %q", req.URL, got, want)
+	}
+	if got, want := w.Body.String(), "{\"a\":1}\n"; got != want {
+		t.Errorf("writeJSON(%q) = %q, want %q", req.URL, got, want)
+	}
+}
+
+func TestWriteCORSHeaders(t *testing.T) {
+	data := []struct {
+		configHost string
+		reqHost    string
+		want       string
+	}{
+		{configHost: "ip.example.com", reqHost: "ip.example.com", want: "http://ip.example.com"},
+		{configHost: "ip.example.com", reqHost: "", want: "http://ip.example.com"},
+		{configHost: "", reqHost: "other.example.com", want: "http://other.example.com"},
+		{configHost: "", reqHost: "", want: ""},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{Host: test.configHost})
+
+		req := httptest.NewRequest("GET", "/json", nil)
+		req.Host = test.reqHost
+		w := httptest.NewRecorder()
+		s.writeCORSHeaders(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.want {
+			t.Errorf("writeCORSHeaders(config %q, host %q) Access-Control-Allow-Origin = %q, want %q", test.configHost, test.reqHost, got, test.want)
+		}
+	}
+}
+
+func TestWriteCORSHeadersForwardedScheme(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Host:           "ip.example.com",
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	s.writeCORSHeaders(w, req)
+
+	want := "https://ip.example.com"
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+		t.Errorf("writeCORSHeaders(proxied https) Access-Control-Allow-Origin = %q, want %q", got, want)
+	}
+}
+
+func TestDisabledEndpoints(t *testing.T) {
+	r := mux.NewRouter()
+	handle := endpointRegistrar(r, &conf.Config{
+		DisabledEndpoints: []string{"/asn"},
+	})
+
+	ok := func(w http.ResponseWriter, _ *http.Request) {}
+	handle("/json", ok)
+	handle("/asn", ok)
+
+	data := []struct {
+		path string
+		want int
+	}{
+		{"/json", http.StatusOK},
+		{"/asn", http.StatusNotFound},
+	}
+
+	for _, test := range data {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.want {
+			t.Errorf("GET %s = %d, want %d", test.path, w.Code, test.want)
+		}
+	}
+}
+
+func TestRegisterDisabledEndpoints(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{
+		DisabledEndpoints: []string{"/", "/json"},
+	})
+
+	data := []struct {
+		path      string
+		userAgent string
+		want      int
+	}{
+		{path: "/json", userAgent: "curl/7.64.1", want: http.StatusNotFound}, // Not the CLI handler
+		{path: "/json", userAgent: "Mozilla/5.0", want: http.StatusNotFound}, // Not the static files
+		{path: "/", userAgent: "curl/7.64.1", want: http.StatusNotFound},
+		{path: "/", userAgent: "Mozilla/5.0", want: http.StatusNotFound},
+		{path: "/ip", userAgent: "curl/7.64.1", want: http.StatusOK},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", "https://ip.example.com"+test.path, nil)
+		req.Header.Set("User-Agent", test.userAgent)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != test.want {
+			t.Errorf("GET %s (User-Agent: %q) = %d, want %d", test.path, test.userAgent, w.Code, test.want)
+		}
+	}
+}
+
+func TestEndpointMethods(t *testing.T) {
+	r := mux.NewRouter()
+	handle := endpointRegistrar(r, &conf.Config{})
+
+	ok := func(w http.ResponseWriter, _ *http.Request) {}
+	handle("/json", ok)
+	handle("/batch", ok, http.MethodPost)
+	r.PathPrefix("/").HandlerFunc(ok) // Like the static files, which must not serve other methods
+
+	data := []struct {
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+	}{
+		{method: "GET", path: "/json", wantCode: http.StatusOK},
+		{method: "HEAD", path: "/json", wantCode: http.StatusOK},
+		{method: "DELETE", path: "/json", wantCode: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{method: "POST", path: "/json", wantCode: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{method: "POST", path: "/batch", wantCode: http.StatusOK},
+		{method: "GET", path: "/batch", wantCode: http.StatusMethodNotAllowed, wantAllow: "POST"},
+	}
+
+	for _, test := range data {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.wantCode {
+			t.Errorf("%s %s = %d, want %d", test.method, test.path, w.Code, test.wantCode)
+		}
+		if got := w.Header().Get("Allow"); got != test.wantAllow {
+			t.Errorf("%s %s Allow = %q, want %q", test.method, test.path, got, test.wantAllow)
+		}
+	}
+}
+
+func TestRegisterMethodNotAllowed(t *testing.T) {
+	r := mux.NewRouter()
+	register(r, newDefaultServer(&conf.Config{}), &conf.Config{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "https://ip.example.com/json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /json = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+
+	// CORS preflights are still answered.
+	req := httptest.NewRequest("OPTIONS", "https://ip.example.com/json", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("OPTIONS /json (preflight) = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestRegisterMiddleware(t *testing.T) {
+	var order []string
+	header := func(name string) mux.MiddlewareFunc {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				order = append(order, name)
+				w.Header().Set("X-"+name, "1")
+				h.ServeHTTP(w, req)
+			})
+		}
+	}
+
+	r := mux.NewRouter()
+	Register(r, &conf.Config{}, header("First"), header("Second"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://ip.example.com/json?include=ua", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /json = %d, want %d", w.Code, http.StatusOK)
+	}
+	for _, name := range []string{"X-First", "X-Second"} {
+		if got := w.Header().Get(name); got != "1" {
+			t.Errorf("GET /json %s = %q, want %q", name, got, "1")
+		}
+	}
+	if diff := pretty.Compare(order, []string{"First", "Second"}); diff != "" {
+		t.Errorf("GET /json middleware order diff: (-got +want)\n%s", diff)
+	}
+
+	// The built-in middleware still runs first, so insecure requests are redirected before them.
+	order = nil
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://ip.example.com/json", nil))
+	if w.Code != http.StatusMovedPermanently || len(order) != 0 {
+		t.Errorf("GET http://.../json = %d, ran %v, want %d before the middleware", w.Code, order, http.StatusMovedPermanently)
+	}
+}
+
+func TestContentSecurityPolicy(t *testing.T) {
+	data := []struct {
+		config *conf.Config
+		want   string
+	}{
+		{
+			config: &conf.Config{MapsAPIKey: "key"},
+			want:   "default-src 'self'; connect-src *; script-src 'self' www.google-analytics.com; img-src data: 'self' www.google-analytics.com maps.googleapis.com;",
+		},
+		{
+			config: &conf.Config{AnalyticsHosts: []string{"plausible.example.com"}},
+			want:   "default-src 'self'; connect-src *; script-src 'self' plausible.example.com; img-src data: 'self' plausible.example.com;",
+		},
+		{
+			config: &conf.Config{ContentSecurityPolicy: "default-src 'none';", MapsAPIKey: "key"},
+			want:   "default-src 'none';",
+		},
+	}
+
+	for _, test := range data {
+		r := mux.NewRouter()
+		Register(r, test.config)
+
+		req := httptest.NewRequest("GET", "https://ip.example.com/ip", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Security-Policy"); got != test.want {
+			t.Errorf("Register(%+v) Content-Security-Policy = %q, want %q", test.config, got, test.want)
+		}
+	}
+}
+
+func TestWriteCORSHeadersAllowedOrigins(t *testing.T) {
+	data := []struct {
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{allowed: []string{"https://example.com"}, origin: "https://example.com", want: "https://example.com"},
+		{allowed: []string{"https://example.com"}, origin: "https://evil.example.net", want: "http://ip.example.com"}, // The same host fallback
+		{allowed: []string{"*"}, origin: "https://other.example.org", want: "*"},
+		{allowed: nil, origin: "https://example.com", want: "http://ip.example.com"},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{Host: "ip.example.com", AllowedOrigins: test.allowed})
+
+		req := httptest.NewRequest("GET", "/json", nil)
+		req.Header.Set("Origin", test.origin)
+		w := httptest.NewRecorder()
+		s.writeCORSHeaders(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.want {
+			t.Errorf("writeCORSHeaders(allowed %q, origin %q) Access-Control-Allow-Origin = %q, want %q", test.allowed, test.origin, got, test.want)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r, &conf.Config{
+		Debug:          true,
+		AllowedOrigins: []string{"https://example.com"},
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/json", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "X-Request-Id")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("OPTIONS /json = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://example.com",
+		"Access-Control-Allow-Methods": corsMethods,
+		"Access-Control-Allow-Headers": "X-Request-Id",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("OPTIONS /json %s = %q, want %q", header, got, want)
+		}
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("OPTIONS /json = %q, want no body", w.Body.String())
+	}
+}
+
+func TestNewServerShutdown(t *testing.T) {
+	var log bytes.Buffer
+	s := NewServer(mux.NewRouter(), &conf.Config{Debug: true}, &log)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() err = %s", err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/ip")
+	if err != nil {
+		t.Fatalf("GET /ip err = %s, want nil", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "127.0.0.1\n" {
+		t.Errorf("GET /ip = (%d, %q), want (%d, %q)", resp.StatusCode, body, http.StatusOK, "127.0.0.1\n")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx, s); err != nil {
+		t.Errorf("Shutdown() err = %s, want nil", err)
+	}
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("Serve() err = %v, want %v", err, http.ErrServerClosed)
+	}
+
+	if log.Len() == 0 {
+		t.Errorf("NewServer() logged nothing, want the request logged")
+	}
+}
+
+func TestSecureOptions(t *testing.T) {
+	data := []struct {
+		name          string
+		config        *conf.Config
+		url           string
+		wantCode      int
+		wantSTS       string
+		wantFrameDeny bool
+	}{
+		{
+			name:     "default plain",
+			config:   &conf.Config{},
+			url:      "http://ip.example.com/ip",
+			wantCode: http.StatusMovedPermanently,
+		}, {
+			name:          "ssl redirect disabled",
+			config:        &conf.Config{DisableSSLRedirect: true},
+			url:           "http://ip.example.com/ip",
+			wantCode:      http.StatusOK,
+			wantFrameDeny: true,
+		}, {
+			name:          "default https",
+			config:        &conf.Config{},
+			url:           "https://ip.example.com/ip",
+			wantCode:      http.StatusOK,
+			wantSTS:       "max-age=31536000; includeSubDomains; preload",
+			wantFrameDeny: true,
+		}, {
+			name:          "short sts without preload",
+			config:        &conf.Config{STSSeconds: 300, DisableSTSPreload: true},
+			url:           "https://ip.example.com/ip",
+			wantCode:      http.StatusOK,
+			wantSTS:       "max-age=300; includeSubDomains",
+			wantFrameDeny: true,
+		}, {
+			name:     "no sts or frame deny",
+			config:   &conf.Config{STSSeconds: -1, DisableFrameDeny: true},
+			url:      "https://ip.example.com/ip",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range data {
+		r := mux.NewRouter()
+		Register(r, test.config)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+
+		if w.Code != test.wantCode {
+			t.Errorf("GET %s (%s) = %d, want %d", test.url, test.name, w.Code, test.wantCode)
+		}
+		if got := w.Header().Get("Strict-Transport-Security"); got != test.wantSTS {
+			t.Errorf("GET %s (%s) Strict-Transport-Security = %q, want %q", test.url, test.name, got, test.wantSTS)
+		}
+		if got := w.Header().Get("X-Frame-Options") != ""; w.Code == http.StatusOK && got != test.wantFrameDeny {
+			t.Errorf("GET %s (%s) has X-Frame-Options = %t, want %t", test.url, test.name, got, test.wantFrameDeny)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package myip
+
+import (
+	"net/http"
+
+	"bramp.net/myip/lib/refresh"
+)
+
+// StatsResponse contains internal stats about this instance.
+type StatsResponse struct {
+	Refresh []refresh.Stat
+}
+
+// StatsHandler returns the internal stats as a JSON object.
+func (s *DefaultServer) StatsHandler(w http.ResponseWriter, req *http.Request) {
+	s.writeJSON(w, req, &StatsResponse{
+		Refresh: s.Refresher.Stats(),
+	})
+}
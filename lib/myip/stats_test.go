@@ -0,0 +1,58 @@
+package myip
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/location/mmdbtest"
+	"github.com/gorilla/mux"
+)
+
+func TestStatsHandlerRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatalf("TempDir() err = %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.mmdb")
+	if err := mmdbtest.Write(path, "GB", time.Now()); err != nil {
+		t.Fatalf("mmdbtest.Write(%q) err = %s", path, err)
+	}
+
+	r := mux.NewRouter()
+	Register(r, &conf.Config{
+		LocationProvider: "mmdb",
+		LocationDatabase: path,
+	})
+
+	req := httptest.NewRequest("GET", "https://ip.example.com/stats", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /stats = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var got StatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("GET /stats unmarshal err = %s, body %q", err, w.Body.String())
+	}
+	if len(got.Refresh) != 1 {
+		t.Fatalf("GET /stats Refresh = %+v, want one data source", got.Refresh)
+	}
+
+	stat := got.Refresh[0]
+	if want := "location database " + path; stat.Name != want {
+		t.Errorf("GET /stats Refresh[0].Name = %q, want %q", stat.Name, want)
+	}
+	if stat.LastRefresh == nil || stat.LastError != "" {
+		t.Errorf("GET /stats Refresh[0] = %+v, want a successful refresh", stat)
+	}
+}
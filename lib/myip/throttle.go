@@ -0,0 +1,84 @@
+package myip
+
+import (
+	"sync"
+	"time"
+
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// throttleSize is the maximum number of client addresses tracked.
+	throttleSize = 100000
+
+	// throttleTTL is how long a idle client's rate is remembered for.
+	throttleTTL = 10 * time.Minute
+)
+
+// throttler tracks the request rate of each client address, so expensive lookups can be skipped
+// for clients making too many requests. Clients are forgotten when idle, or sooner if more than
+// throttleSize are seen.
+type throttler struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters *cache.Cache
+}
+
+// newThrottler returns a throttler for conf.Config.EnrichmentRate, or nil if it is disabled.
+func newThrottler(config *conf.Config) *throttler {
+	return newRateThrottler(config.EnrichmentRate, config.EnrichmentBurst)
+}
+
+// newRateThrottler returns a throttler allowing perSecond requests, after a initial burst
+// (defaulting to 1), or nil if perSecond is zero.
+func newRateThrottler(perSecond float64, burst int) *throttler {
+	if perSecond <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &throttler{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		limiters: cache.New(throttleSize, throttleTTL),
+	}
+}
+
+// allow records a request from the address, returning false if it exceeds the request rate.
+func (t *throttler) allow(addr string) bool {
+	ok, _ := t.reserve(addr)
+	return ok
+}
+
+// reserve records a request from the address, returning false if it exceeds the request rate,
+// along with how long until the next request would be allowed.
+func (t *throttler) reserve(addr string) (bool, time.Duration) {
+	if t == nil {
+		return true, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var limiter *rate.Limiter
+	if l, found := t.limiters.Get(addr); found {
+		limiter = l.(*rate.Limiter)
+	} else {
+		limiter = rate.NewLimiter(t.limit, t.burst)
+	}
+	t.limiters.Set(addr, limiter) // Refresh the TTL
+
+	r := limiter.Reserve()
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel() // Rejected requests don't count against the client
+		return false, delay
+	}
+	return true, 0
+}
@@ -0,0 +1,28 @@
+package myip
+
+import (
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+)
+
+func TestThrottlerAllow(t *testing.T) {
+	if th := newThrottler(&conf.Config{}); !th.allow("192.0.2.1") {
+		t.Errorf("allow() = false, want true when throttling is disabled")
+	}
+
+	th := newThrottler(&conf.Config{
+		EnrichmentRate:  0.001, // Effectively no refill during the test
+		EnrichmentBurst: 2,
+	})
+
+	for i, want := range []bool{true, true, false, false} {
+		if got := th.allow("192.0.2.1"); got != want {
+			t.Errorf("allow(%q) request %d = %v, want %v", "192.0.2.1", i+1, got, want)
+		}
+	}
+
+	if !th.allow("192.0.2.2") {
+		t.Errorf("allow(%q) = false, want true for a different client", "192.0.2.2")
+	}
+}
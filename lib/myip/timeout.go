@@ -0,0 +1,23 @@
+package myip
+
+import (
+	"net/http"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/gorilla/mux"
+)
+
+// requestTimeoutMessage is the body of the response to a request exceeding the timeout.
+const requestTimeoutMessage = "request timed out\n"
+
+// RequestTimeout returns middleware that cancels requests taking longer than
+// conf.Config.RequestTimeout, answering them with a 503 Service Unavailable. The response is
+// buffered until the handler returns, so it must not wrap streaming handlers.
+func RequestTimeout(config *conf.Config) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		if config.RequestTimeout <= 0 {
+			return h
+		}
+		return http.TimeoutHandler(h, config.RequestTimeout, requestTimeoutMessage)
+	}
+}
@@ -0,0 +1,63 @@
+package myip
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"github.com/gorilla/mux"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	config := &conf.Config{RequestTimeout: 50 * time.Millisecond}
+	s := newDefaultServer(config)
+
+	cancelled := make(chan error, 1)
+	s.reverseDNS = func(ctx context.Context, addr string) *dns.Response {
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		case <-time.After(time.Second):
+			cancelled <- nil
+		}
+		return &dns.Response{Query: addr}
+	}
+
+	r := mux.NewRouter()
+	register(r, s, config)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://ip.example.com/json?include=dns", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /json with a slow lookup = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Body.String(); got != requestTimeoutMessage {
+		t.Errorf("GET /json with a slow lookup body = %q, want %q", got, requestTimeoutMessage)
+	}
+
+	select {
+	case err := <-cancelled:
+		if err != context.DeadlineExceeded {
+			t.Errorf("slow lookup ctx.Err() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("slow lookup never returned")
+	}
+}
+
+func TestRequestTimeoutExempt(t *testing.T) {
+	config := &conf.Config{RequestTimeout: time.Nanosecond}
+	r := mux.NewRouter()
+	register(r, newDefaultServer(config), config)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "https://ip.example.com"+healthzPath, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET %s = %d, want %d", healthzPath, w.Code, http.StatusOK)
+	}
+}
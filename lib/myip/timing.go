@@ -0,0 +1,95 @@
+package myip
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// timings records how long each lookup took. It is safe for concurrent use.
+type timings struct {
+	// Lookups of target taking longer than slow are logged. Zero disables the logging.
+	target string
+	slow   time.Duration
+
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+func newTimings(target string, slow time.Duration) *timings {
+	return &timings{
+		target:    target,
+		slow:      slow,
+		durations: make(map[string]time.Duration),
+	}
+}
+
+// timed wraps f, so when it's called the duration is recorded under this name.
+func (t *timings) timed(name string, f func()) func() {
+	return func() {
+		start := time.Now()
+		f()
+		t.add(name, time.Since(start))
+	}
+}
+
+func (t *timings) add(name string, d time.Duration) {
+	if t.slow > 0 && d > t.slow {
+		log.Warningf("Slow %s lookup for %q took %s", name, t.target, d)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations[name] = d
+}
+
+// milliseconds returns the duration of each lookup in milliseconds.
+func (t *timings) milliseconds() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ms := make(map[string]int, len(t.durations))
+	for name, d := range t.durations {
+		ms[name] = int(d / time.Millisecond)
+	}
+	return ms
+}
+
+// serverTiming returns the durations formatted as a Server-Timing header, e.g.
+// "dns;dur=12, whois;dur=340". The metrics are sorted by name.
+func (t *timings) serverTiming() string {
+	ms := t.milliseconds()
+
+	names := make([]string, 0, len(ms))
+	for name := range ms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]string, len(names))
+	for i, name := range names {
+		metrics[i] = fmt.Sprintf("%s;dur=%d", name, ms[name])
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// serverTiming returns the Server-Timing header for these timings, or "" if not enabled by
+// conf.Config.ServerTiming.
+func (s *DefaultServer) serverTiming(t *timings) string {
+	if !s.Config.Debug && !s.Config.ServerTiming {
+		return ""
+	}
+	return t.serverTiming()
+}
+
+// writeServerTiming sets the Server-Timing header, if there are any timings to report.
+func writeServerTiming(w http.ResponseWriter, serverTiming string) {
+	if serverTiming != "" {
+		w.Header().Set("Server-Timing", serverTiming)
+	}
+}
@@ -0,0 +1,108 @@
+package myip
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestTimingsLogsSlowLookups(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	defer log.SetOutput(out)
+
+	timings := newTimings("192.0.2.1", 10*time.Millisecond)
+	timings.add("dns", time.Millisecond)
+	timings.add("whois", time.Second)
+
+	got := buf.String()
+	if strings.Contains(got, "dns") {
+		t.Errorf("fast dns lookup was logged: %q", got)
+	}
+	if !strings.Contains(got, "Slow whois lookup") || !strings.Contains(got, "192.0.2.1") {
+		t.Errorf("slow whois lookup was not logged: %q", got)
+	}
+
+	if got := timings.milliseconds(); got["dns"] != 1 || got["whois"] != 1000 {
+		t.Errorf("milliseconds() = %v, want dns:1 whois:1000", got)
+	}
+}
+
+func TestTimingsServerTiming(t *testing.T) {
+	timings := newTimings("192.0.2.1", 0)
+	timings.add("whois", 340*time.Millisecond)
+	timings.add("dns", 12*time.Millisecond)
+	timings.add("location", 8*time.Millisecond)
+
+	want := "dns;dur=12, location;dur=8, whois;dur=340"
+	if got := timings.serverTiming(); got != want {
+		t.Errorf("serverTiming() = %q, want %q", got, want)
+	}
+
+	if got := newTimings("192.0.2.1", 0).serverTiming(); got != "" {
+		t.Errorf("serverTiming() with no timings = %q, want %q", got, "")
+	}
+}
+
+func TestJSONHandlerTimings(t *testing.T) {
+	data := []struct {
+		config      *conf.Config
+		wantTimings bool
+	}{
+		{config: &conf.Config{}, wantTimings: false}, // Production payloads stay lean
+		{config: &conf.Config{IncludeTimings: true}, wantTimings: true},
+		{config: &conf.Config{Debug: true}, wantTimings: true},
+	}
+
+	for _, test := range data {
+		s := newSlowServer(test.config, 0, 0, 0)
+		req := httptest.NewRequest("GET", "/json?include=dns,whois", nil)
+		w := httptest.NewRecorder()
+		s.JSONHandler(w, req)
+
+		var got struct {
+			Timings map[string]int
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("JSONHandler() returned invalid json: %s", err)
+		}
+		if _, found := got.Timings["whois"]; found != test.wantTimings {
+			t.Errorf("JSONHandler() with %+v Timings = %v, want timings %t", test.config, got.Timings, test.wantTimings)
+		}
+	}
+}
+
+func TestMyIPHandlerSlowLookupThreshold(t *testing.T) {
+	data := []struct {
+		threshold  time.Duration
+		wantLogged bool
+	}{
+		{threshold: 0, wantLogged: false}, // Disabled
+		{threshold: time.Millisecond, wantLogged: true},
+		{threshold: time.Minute, wantLogged: false},
+	}
+
+	for _, test := range data {
+		var buf bytes.Buffer
+		out := log.StandardLogger().Out
+		log.SetOutput(&buf)
+
+		s := newSlowServer(&conf.Config{SlowLookupThreshold: test.threshold}, 20*time.Millisecond, 0, 0)
+		req := httptest.NewRequest("GET", "/json?include=dns", nil)
+		if _, err := s.MyIPHandler(req); err != nil {
+			t.Fatalf("MyIPHandler() err = %s, want nil", err)
+		}
+		log.SetOutput(out)
+
+		if got := strings.Contains(buf.String(), "Slow dns lookup"); got != test.wantLogged {
+			t.Errorf("MyIPHandler() with SlowLookupThreshold %s logged %q, want logged %t", test.threshold, buf.String(), test.wantLogged)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package myip
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// TLS describes the client's TLS connection.
+type TLS struct {
+	Version     string // e.g. "TLS 1.3"
+	CipherSuite string // e.g. "TLS_AES_128_GCM_SHA256"
+	ALPN        string `json:",omitempty"` // The negotiated application protocol, e.g. "h2"
+	ServerName  string `json:",omitempty"` // The SNI requested by the client
+}
+
+// tlsVersions maps the tls.ConnectionState.Version to a human readable name.
+var tlsVersions = map[uint16]string{
+	tls.VersionSSL30: "SSL 3.0",
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// newTLS returns the TLS details of the connection, or nil if the request was not over TLS (which
+// includes when TLS is terminated by a proxy).
+func newTLS(state *tls.ConnectionState) *TLS {
+	if state == nil {
+		return nil
+	}
+
+	version, found := tlsVersions[state.Version]
+	if !found {
+		version = fmt.Sprintf("0x%04X", state.Version)
+	}
+
+	return &TLS{
+		Version:     version,
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+	}
+}
@@ -0,0 +1,115 @@
+package myip
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestNewTLS(t *testing.T) {
+	data := []struct {
+		state *tls.ConnectionState
+		want  *TLS
+	}{
+		{state: nil, want: nil},
+		{
+			state: &tls.ConnectionState{
+				Version:            tls.VersionTLS13,
+				CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+				NegotiatedProtocol: "h2",
+				ServerName:         "ip.example.com",
+			},
+			want: &TLS{
+				Version:     "TLS 1.3",
+				CipherSuite: "TLS_AES_128_GCM_SHA256",
+				ALPN:        "h2",
+				ServerName:  "ip.example.com",
+			},
+		},
+		{
+			state: &tls.ConnectionState{
+				Version:     0x7f00,
+				CipherSuite: 0x1234,
+			},
+			want: &TLS{
+				Version:     "0x7F00",
+				CipherSuite: "0x1234",
+			},
+		},
+	}
+
+	for _, test := range data {
+		got := newTLS(test.state)
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("newTLS(%+v) diff: (-got +want)\n%s", test.state, diff)
+		}
+	}
+}
+
+func TestJSONHandlerTLS(t *testing.T) {
+	s := newDefaultServer(&conf.Config{})
+
+	req := httptest.NewRequest("GET", "/json?include=none", nil)
+	req.TLS = &tls.ConnectionState{
+		Version:     tls.VersionTLS12,
+		CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	}
+	w := httptest.NewRecorder()
+	s.JSONHandler(w, req)
+
+	var got Response
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("JSONHandler(%q) returned invalid json: %s", req.URL, err)
+	}
+	if got.TLS == nil || got.TLS.Version != "TLS 1.2" || got.TLS.CipherSuite != "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" {
+		t.Errorf("JSONHandler(%q).TLS = %+v, want TLS 1.2 and TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", req.URL, got.TLS)
+	}
+
+	// Plain HTTP omits the field
+	req = httptest.NewRequest("GET", "/json?include=none", nil)
+	w = httptest.NewRecorder()
+	s.JSONHandler(w, req)
+
+	var raw map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&raw); err != nil {
+		t.Fatalf("JSONHandler(%q) returned invalid json: %s", req.URL, err)
+	}
+	if _, found := raw["TLS"]; found {
+		t.Errorf("JSONHandler(%q) included TLS over plain HTTP", req.URL)
+	}
+}
+
+func TestMyIPHandlerTLSALPN(t *testing.T) {
+	data := []struct {
+		tls  *tls.ConnectionState
+		want string
+	}{
+		{tls: &tls.ConnectionState{NegotiatedProtocol: "h2"}, want: "h2"},
+		{tls: nil, want: ""}, // Plain HTTP
+	}
+
+	for _, test := range data {
+		s := newSlowServer(&conf.Config{}, 0, 0, 0)
+		req := httptest.NewRequest("GET", "/json?include=none", nil)
+		req.TLS = test.tls
+
+		got, err := s.MyIPHandler(req)
+		if err != nil {
+			t.Fatalf("MyIPHandler() err = %s, want nil", err)
+		}
+		if got.TLSALPN != test.want {
+			t.Errorf("MyIPHandler(TLS %+v).TLSALPN = %q, want %q", test.tls, got.TLSALPN, test.want)
+		}
+
+		w := httptest.NewRecorder()
+		s.JSONHandler(w, req)
+		if omitted := !strings.Contains(w.Body.String(), `"TLSALPN"`); omitted != (test.want == "") {
+			t.Errorf("JSONHandler(TLS %+v) = %q, want TLSALPN omitted %t", test.tls, w.Body.String(), test.want == "")
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package myip
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"bramp.net/myip/lib/dns"
+)
+
+// jsonSize returns the size of the response encoded as JSON.
+func jsonSize(resp *Response) int {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// truncate trims the response's largest variable fields, until it fits in
+// conf.Config.MaxResponseBytes. The whois body is trimmed first, then the reverse DNS names.
+// Each trimmed field is listed in the response's Truncated.
+func (s *DefaultServer) truncate(resp *Response) *Response {
+	max := s.Config.MaxResponseBytes
+	if max <= 0 {
+		return resp
+	}
+
+	excess := jsonSize(resp) - max
+	if excess <= 0 {
+		return resp
+	}
+
+	if whois := resp.RemoteAddrWhois; whois != nil && whois.Body != "" {
+		resp.Truncated = append(resp.Truncated, "RemoteAddrWhois.Body")
+		for excess > 0 && whois.Body != "" {
+			whois.Body = truncateString(whois.Body, len(whois.Body)-excess)
+			excess = jsonSize(resp) - max
+		}
+	}
+
+	for _, names := range []*dnsNames{
+		{"RemoteAddrReverse.Secondary.Names", secondaryReverse(resp)},
+		{"RemoteAddrReverse.Names", resp.RemoteAddrReverse},
+	} {
+		if excess <= 0 || names.resp == nil || len(names.resp.Names) == 0 {
+			continue
+		}
+
+		resp.Truncated = append(resp.Truncated, names.field)
+		for excess > 0 && len(names.resp.Names) > 0 {
+			n := len(names.resp.Names) - 1
+			names.resp.Names = names.resp.Names[:n]
+			if len(names.resp.UnicodeNames) > n {
+				names.resp.UnicodeNames = names.resp.UnicodeNames[:n] // Kept parallel to the Names
+			}
+			excess = jsonSize(resp) - max
+		}
+	}
+
+	return resp
+}
+
+type dnsNames struct {
+	field string
+	resp  *dns.Response
+}
+
+func secondaryReverse(resp *Response) *dns.Response {
+	if resp.RemoteAddrReverse == nil {
+		return nil
+	}
+	return resp.RemoteAddrReverse.Secondary
+}
+
+// truncateString returns at most the first n bytes of s, without splitting a UTF-8 character.
+func truncateString(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,66 @@
+package myip
+
+import (
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/whois"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestTruncate(t *testing.T) {
+	newResponse := func() *Response {
+		return &Response{
+			RemoteAddr: "192.0.2.1",
+			RemoteAddrReverse: &dns.Response{
+				Names: []string{"a.example.com.", "b.example.com.", "c.example.com."},
+			},
+			RemoteAddrWhois: &whois.Response{
+				Body: strings.Repeat("whois ", 1000),
+			},
+		}
+	}
+
+	data := []struct {
+		max  int
+		want []string
+	}{
+		{max: 0, want: nil},
+		{max: 100000, want: nil},
+		{max: 1000, want: []string{"RemoteAddrWhois.Body"}},
+		{max: 260, want: []string{"RemoteAddrWhois.Body", "RemoteAddrReverse.Names"}},
+	}
+
+	for _, test := range data {
+		s := newDefaultServer(&conf.Config{MaxResponseBytes: test.max})
+
+		got := s.truncate(newResponse())
+		if diff := pretty.Compare(got.Truncated, test.want); diff != "" {
+			t.Errorf("truncate(max %d).Truncated diff: (-got +want)\n%s", test.max, diff)
+		}
+		if size := jsonSize(got); test.max > 0 && size > test.max {
+			t.Errorf("truncate(max %d) size = %d, want <= %d", test.max, size, test.max)
+		}
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	data := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"hello", -1, ""},
+		{"héllo", 2, "h"}, // Doesn't split the é
+	}
+
+	for _, test := range data {
+		if got := truncateString(test.s, test.n); got != test.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", test.s, test.n, got, test.want)
+		}
+	}
+}
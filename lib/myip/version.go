@@ -0,0 +1,56 @@
+package myip
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// The build's version and git commit, set at build time with -ldflags, for example
+// "-X bramp.net/myip/lib/myip.Version=v1.2.3 -X bramp.net/myip/lib/myip.Commit=abc1234".
+var (
+	Version = "dev"
+	Commit  = "dev"
+)
+
+// VersionResponse describes this build.
+type VersionResponse struct {
+	Version   string
+	Commit    string
+	BuildTime string `json:",omitempty"`
+	Go        string // The Go runtime version
+}
+
+// newVersionResponse returns the VersionResponse for this build. conf.Config.Version, if set,
+// overrides the Version.
+func (s *DefaultServer) newVersionResponse() *VersionResponse {
+	version := s.Config.Version
+	if version == "" {
+		version = Version
+	}
+
+	return &VersionResponse{
+		Version:   version,
+		Commit:    Commit,
+		BuildTime: s.Config.BuildTime,
+		Go:        runtime.Version(),
+	}
+}
+
+// VersionHandler returns the build's version as plain text, or as JSON if chosen by the Accept
+// header.
+func (s *DefaultServer) VersionHandler(w http.ResponseWriter, req *http.Request) {
+	response := s.newVersionResponse()
+
+	if negotiateFormat(req.Header.Get("Accept")) == formatJSON {
+		s.writeJSON(w, req, response)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "Version: %s\nCommit: %s\n", response.Version, response.Commit)
+	if response.BuildTime != "" {
+		fmt.Fprintf(w, "BuildTime: %s\n", response.BuildTime)
+	}
+	fmt.Fprintf(w, "Go: %s\n", response.Go)
+}
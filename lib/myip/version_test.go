@@ -0,0 +1,49 @@
+package myip
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestVersionHandler(t *testing.T) {
+	oldVersion, oldCommit := Version, Commit
+	defer func() { Version, Commit = oldVersion, oldCommit }()
+	Version, Commit = "v1.2.3", "abc1234"
+
+	s := newDefaultServer(&conf.Config{})
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	s.VersionHandler(w, req)
+
+	wantText := "Version: v1.2.3\nCommit: abc1234\nGo: " + runtime.Version() + "\n"
+	if got := w.Body.String(); got != wantText {
+		t.Errorf("VersionHandler() = %q, want %q", got, wantText)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/plain" {
+		t.Errorf("VersionHandler() Content-Type = %q, want %q", got, "text/plain")
+	}
+
+	req = httptest.NewRequest("GET", "/version", nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	s.VersionHandler(w, req)
+
+	var got VersionResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("VersionHandler(Accept: application/json) returned invalid json: %s", err)
+	}
+	want := VersionResponse{
+		Version: "v1.2.3",
+		Commit:  "abc1234",
+		Go:      runtime.Version(),
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("VersionHandler(Accept: application/json) diff: (-got +want)\n%s", diff)
+	}
+}
@@ -0,0 +1,116 @@
+package myip
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/whois"
+)
+
+// whoisCacheSize is the maximum number of networks (or addresses) whose whois is cached.
+const whoisCacheSize = 10000
+
+// The prefixes of the whois cache's keys.
+const (
+	whoisNetworkKey = "net/"  // A successful whois, shared by all addresses in the network
+	whoisAddrKey    = "addr/" // A whois for just this address, such as a failure
+)
+
+// newWhoisCache returns the cache of whois results, or nil if conf.Config.WhoisCacheTTL is unset.
+// The bodies are compressed if conf.Config.CompressCache is set.
+func newWhoisCache(config *conf.Config) *cache.Cache {
+	if config.WhoisCacheTTL <= 0 {
+		return nil
+	}
+	if config.CompressCache {
+		return cache.New(whoisCacheSize, config.WhoisCacheTTL, cache.Compressed())
+	}
+	return cache.New(whoisCacheSize, config.WhoisCacheTTL)
+}
+
+// cachedWhois returns the whois for the address from the cache, otherwise looking it up and caching
+// the result. A successful whois is cached by the network it describes, so other addresses in the
+// same network are answered from the cache. Failures (and empty responses) are cached for just the
+// address, for the shorter conf.Config.WhoisErrorCacheTTL. Failures, such as timeouts, that say
+// nothing about the address are never cached.
+func (s *DefaultServer) cachedWhois(ctx context.Context, addr string) *whois.Response {
+	if s.whoisCache == nil || cache.Bypassed(ctx) {
+		return s.whoisLookup(ctx, addr)
+	}
+
+	if resp := s.cachedWhoisGet(addr); resp != nil {
+		return resp
+	}
+
+	resp := s.whoisLookup(ctx, addr)
+	switch {
+	case resp.Error == "" && resp.Body != "":
+		key := whoisAddrKey + addr
+		if network := whois.Network(resp.Body, addr); network != "" {
+			key = whoisNetworkKey + network
+		}
+		s.whoisCache.Set(key, resp.Body) // Just the body, so it may be compressed
+
+	case transientWhoisError(ctx, resp):
+		// Retried the next time
+
+	case s.Config.WhoisErrorCacheTTL > 0:
+		s.whoisCache.SetWithTTL(whoisAddrKey+addr, resp, s.Config.WhoisErrorCacheTTL)
+	}
+	return resp
+}
+
+// transientWhoisError returns true if the whois failed because the request was cancelled or timed
+// out, instead of because of the address.
+func transientWhoisError(ctx context.Context, resp *whois.Response) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+
+	err := resp.Err()
+	var timeout *whois.TimeoutError
+	var netErr net.Error
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &timeout) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// cachedWhoisGet returns the cached whois for the address, or nil if there is none. The networks
+// containing the address are tried from the most specific.
+func (s *DefaultServer) cachedWhoisGet(addr string) *whois.Response {
+	if value, found := s.whoisCache.Get(whoisAddrKey + addr); found {
+		return whoisFromCache(addr, value)
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+
+	for ones := bits; ones > 0; ones-- {
+		mask := net.CIDRMask(ones, bits)
+		network := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+		if value, found := s.whoisCache.Get(whoisNetworkKey + network.String()); found {
+			return whoisFromCache(addr, value)
+		}
+	}
+	return nil
+}
+
+// whoisFromCache returns the whois.Response for the address from the cached value.
+func whoisFromCache(addr string, value interface{}) *whois.Response {
+	if body, ok := value.(string); ok {
+		return &whois.Response{Query: addr, Body: body, AbuseContact: whois.ParseAbuseContact(body)}
+	}
+
+	// Copy, so the cached response is never modified.
+	resp := *value.(*whois.Response)
+	resp.Query = addr
+	return &resp
+}
@@ -0,0 +1,184 @@
+package myip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/cache"
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/whois"
+)
+
+const testWhoisBody = "NetRange:       203.0.113.0 - 203.0.113.255\nOrgName:        Example\n"
+
+func TestLookupWhoisCached(t *testing.T) {
+	data := []struct {
+		name      string
+		resp      whois.Response
+		ttl       time.Duration
+		errorTTL  time.Duration
+		bypass    bool
+		addrs     []string
+		wantCalls int
+	}{
+		{
+			name:      "same address",
+			resp:      whois.Response{Body: testWhoisBody},
+			ttl:       time.Minute,
+			addrs:     []string{"203.0.113.1", "203.0.113.1"},
+			wantCalls: 1,
+		}, {
+			name:      "same network",
+			resp:      whois.Response{Body: testWhoisBody},
+			ttl:       time.Minute,
+			addrs:     []string{"203.0.113.1", "203.0.113.200"},
+			wantCalls: 1,
+		}, {
+			name:      "other network",
+			resp:      whois.Response{Body: testWhoisBody},
+			ttl:       time.Minute,
+			addrs:     []string{"203.0.113.1", "198.51.100.1"},
+			wantCalls: 2,
+		}, {
+			name:      "no network",
+			resp:      whois.Response{Body: "OrgName: Example\n"},
+			ttl:       time.Minute,
+			addrs:     []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"},
+			wantCalls: 2,
+		}, {
+			name:      "error cached",
+			resp:      whois.Response{Error: "connection refused"},
+			ttl:       time.Minute,
+			errorTTL:  time.Minute,
+			addrs:     []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"},
+			wantCalls: 2,
+		}, {
+			name:      "empty cached",
+			resp:      whois.Response{},
+			ttl:       time.Minute,
+			errorTTL:  time.Minute,
+			addrs:     []string{"203.0.113.1", "203.0.113.1"},
+			wantCalls: 1,
+		}, {
+			name:      "error not cached",
+			resp:      whois.Response{Error: "connection refused"},
+			ttl:       time.Minute,
+			addrs:     []string{"203.0.113.1", "203.0.113.1"},
+			wantCalls: 2,
+		}, {
+			name:      "disabled",
+			resp:      whois.Response{Body: testWhoisBody},
+			errorTTL:  time.Minute,
+			addrs:     []string{"203.0.113.1", "203.0.113.1"},
+			wantCalls: 2,
+		}, {
+			name:      "bypass",
+			resp:      whois.Response{Body: testWhoisBody},
+			ttl:       time.Minute,
+			bypass:    true,
+			addrs:     []string{"203.0.113.1", "203.0.113.1"},
+			wantCalls: 2,
+		},
+	}
+
+	for _, test := range data {
+		calls := 0
+		s := newDefaultServer(&conf.Config{WhoisCacheTTL: test.ttl, WhoisErrorCacheTTL: test.errorTTL})
+		s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+			calls++
+			resp := test.resp
+			resp.Query = addr
+			return &resp
+		}
+
+		ctx := context.Background()
+		if test.bypass {
+			ctx = cache.WithBypass(ctx)
+		}
+
+		for _, addr := range test.addrs {
+			want := test.resp
+			want.Query = addr
+			if got := s.lookupWhois(ctx, addr); *got != want {
+				t.Errorf("%s: lookupWhois(%q) = %+v, want %+v", test.name, addr, got, want)
+			}
+		}
+
+		if calls != test.wantCalls {
+			t.Errorf("%s: lookupWhois(%q) made %d backend calls, want %d", test.name, test.addrs, calls, test.wantCalls)
+		}
+	}
+}
+
+func TestLookupWhoisErrorExpires(t *testing.T) {
+	calls := 0
+	s := newDefaultServer(&conf.Config{WhoisCacheTTL: time.Minute, WhoisErrorCacheTTL: 20 * time.Millisecond})
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		calls++
+		return &whois.Response{Query: addr, Error: "connection refused"}
+	}
+
+	s.lookupWhois(context.Background(), "203.0.113.1")
+	s.lookupWhois(context.Background(), "203.0.113.1")
+	if calls != 1 {
+		t.Errorf("lookupWhois(%q) before the error expired made %d backend calls, want 1", "203.0.113.1", calls)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	s.lookupWhois(context.Background(), "203.0.113.1")
+	if calls != 2 {
+		t.Errorf("lookupWhois(%q) after the error expired made %d backend calls, want 2", "203.0.113.1", calls)
+	}
+}
+
+func TestLookupWhoisTransientErrorNotCached(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	data := []struct {
+		name string
+		ctx  context.Context
+		err  error
+	}{
+		{name: "cancelled", ctx: context.Background(), err: context.Canceled},
+		{name: "deadline", ctx: context.Background(), err: fmt.Errorf("dial: %w", context.DeadlineExceeded)},
+		{name: "timeout", ctx: context.Background(), err: &whois.TimeoutError{Host: "whois.example.com", Timeout: time.Second}},
+		{name: "request cancelled", ctx: cancelled, err: errors.New("connection closed")},
+	}
+
+	for _, test := range data {
+		calls := 0
+		s := newDefaultServer(&conf.Config{WhoisCacheTTL: time.Minute, WhoisErrorCacheTTL: time.Minute})
+		s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+			calls++
+			return whois.ErrorResponse(addr, test.err)
+		}
+
+		s.lookupWhois(test.ctx, "203.0.113.1")
+		s.lookupWhois(test.ctx, "203.0.113.1")
+		if calls != 2 {
+			t.Errorf("%s: lookupWhois(%q) made %d backend calls, want 2 (the error isn't cached)", test.name, "203.0.113.1", calls)
+		}
+	}
+}
+
+func TestLookupWhoisCompressed(t *testing.T) {
+	body := testWhoisBody + strings.Repeat("Comment:        Long enough to be compressed\n", 10)
+
+	calls := 0
+	s := newDefaultServer(&conf.Config{WhoisCacheTTL: time.Minute, CompressCache: true})
+	s.whoisLookup = func(ctx context.Context, addr string) *whois.Response {
+		calls++
+		return &whois.Response{Query: addr, Body: body}
+	}
+
+	s.lookupWhois(context.Background(), "203.0.113.1")
+	got := s.lookupWhois(context.Background(), "203.0.113.2")
+	if calls != 1 || got.Body != body {
+		t.Errorf("lookupWhois() from the compressed cache = %q after %d backend calls, want %q after 1", got.Body, calls, body)
+	}
+}
@@ -0,0 +1,76 @@
+package myip
+
+import (
+	"sync"
+	"time"
+)
+
+// window counts events, and how many of them failed, over a sliding window of time. The window is
+// split into a ring of buckets, each covering an equal slice of it, so old events expire a bucket
+// at a time. It is safe for concurrent use.
+type window struct {
+	width time.Duration // Of each bucket
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	slot     int64 // The number of bucket widths since the epoch, that this bucket counts
+	total    int
+	failures int
+}
+
+// newWindow returns a window covering the duration, in n buckets.
+func newWindow(d time.Duration, n int) *window {
+	return &window{
+		width:   d / time.Duration(n),
+		now:     time.Now,
+		buckets: make([]windowBucket, n),
+	}
+}
+
+// add records a event, which may have failed.
+func (w *window) add(failed bool) {
+	slot := w.slot()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[slot%int64(len(w.buckets))]
+	if b.slot != slot {
+		// Reusing a bucket from a earlier lap of the ring.
+		*b = windowBucket{slot: slot}
+	}
+	b.total++
+	if failed {
+		b.failures++
+	}
+}
+
+// ratio returns the fraction of the events in the window that failed, or 0 if there were none.
+func (w *window) ratio() float64 {
+	oldest := w.slot() - int64(len(w.buckets))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	total, failures := 0, 0
+	for _, b := range w.buckets {
+		if b.slot > oldest {
+			total += b.total
+			failures += b.failures
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(failures) / float64(total)
+}
+
+// slot returns the current slot, see windowBucket.
+func (w *window) slot() int64 {
+	return w.now().UnixNano() / int64(w.width)
+}
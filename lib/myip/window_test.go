@@ -0,0 +1,38 @@
+package myip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	w := newWindow(time.Minute, 6)
+	w.now = func() time.Time { return now }
+
+	if got := w.ratio(); got != 0 {
+		t.Errorf("ratio() with no events = %v, want 0", got)
+	}
+
+	w.add(false)
+	w.add(true)
+	now = now.Add(30 * time.Second)
+	w.add(false)
+	w.add(false)
+	if got, want := w.ratio(), 0.25; got != want {
+		t.Errorf("ratio() = %v, want %v", got, want)
+	}
+
+	// The first two events have expired.
+	now = now.Add(35 * time.Second)
+	w.add(true)
+	if got, want := w.ratio(), 1.0/3; got != want {
+		t.Errorf("ratio() after 65s = %v, want %v", got, want)
+	}
+
+	// Everything has expired.
+	now = now.Add(time.Hour)
+	if got := w.ratio(); got != 0 {
+		t.Errorf("ratio() after a hour = %v, want 0", got)
+	}
+}
@@ -0,0 +1,93 @@
+package myip
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// xmlEntry is a single entry of a map, as XML doesn't support maps.
+type xmlEntry struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xmlHeader returns the headers as XML entries, sorted by name.
+func xmlHeader(header http.Header) []xmlEntry {
+	var entries []xmlEntry
+	for name, values := range header {
+		for _, value := range values {
+			entries = append(entries, xmlEntry{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// xmlStrings returns the map as XML entries, sorted by name.
+func xmlStrings(m map[string]string) []xmlEntry {
+	var entries []xmlEntry
+	for name, value := range m {
+		entries = append(entries, xmlEntry{Name: name, Value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// MarshalXML encodes the Response, replacing its maps with lists of entries.
+func (r *Response) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type response Response // Without the MarshalXML method
+
+	timings := make(map[string]string, len(r.Timings))
+	for name, ms := range r.Timings {
+		timings[name] = strconv.Itoa(ms)
+	}
+
+	start.Name.Local = "Response"
+	return e.EncodeElement(struct {
+		*response
+
+		// Shadow the maps
+		Header   []xmlEntry `xml:"Header>Entry"`
+		Insights []xmlEntry `xml:"Insights>Entry,omitempty"`
+		Timings  []xmlEntry `xml:"Timings>Entry,omitempty"`
+	}{
+		response: (*response)(r),
+
+		Header:   xmlHeader(r.Header),
+		Insights: xmlStrings(r.Insights),
+		Timings:  xmlStrings(timings),
+	}, start)
+}
+
+// XMLHandler does the lookups and returns the results as XML.
+func (s *DefaultServer) XMLHandler(w http.ResponseWriter, req *http.Request) {
+	s.metrics.request(formatXML)
+
+	response, err := s.MyIPHandler(req)
+	if cancelled(err) {
+		return
+	}
+	if err != nil {
+		status, resp := errResponse(err)
+		s.writeXMLStatus(w, req, status, resp)
+		return
+	}
+
+	response = s.addInsights(req, response)
+	writeServerTiming(w, response.serverTiming)
+	setDownload(w, req, "myip.xml")
+	s.writeXMLStatus(w, req, http.StatusOK, response)
+}
+
+func (s *DefaultServer) writeXMLStatus(w http.ResponseWriter, req *http.Request, status int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	s.writeCORSHeaders(w, req)
+
+	// TODO Do something with the returned err
+	buf := bytes.NewBufferString(xml.Header)
+	xml.NewEncoder(buf).Encode(obj)
+	writeBody(w, req, status, buf.Bytes())
+}
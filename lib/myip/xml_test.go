@@ -0,0 +1,83 @@
+package myip
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"bramp.net/myip/lib/dns"
+	"bramp.net/myip/lib/location"
+)
+
+// wellFormed returns a error if s is not well formed XML.
+func wellFormed(s string) error {
+	d := xml.NewDecoder(strings.NewReader(s))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestResponseMarshalXML(t *testing.T) {
+	resp := &Response{
+		RemoteAddr:        "192.0.2.1",
+		RemoteAddrReverse: &dns.Response{Query: "192.0.2.1", Names: []string{"host.example.com."}},
+		Header:            http.Header{"User-Agent": {"curl/7.64.1"}, "Accept": {"*/*"}},
+		Location:          &location.Response{Country: "GB"},
+		Insights:          map[string]string{"Proxy": "via"},
+		Timings:           map[string]int{"dns": 12},
+	}
+
+	b, err := xml.Marshal(resp)
+	if err != nil {
+		t.Fatalf("xml.Marshal(%+v) err = %s, want nil", resp, err)
+	}
+	got := string(b)
+
+	if err := wellFormed(got); err != nil {
+		t.Errorf("xml.Marshal(%+v) = %q, not well formed: %s", resp, got, err)
+	}
+	for _, want := range []string{
+		"<Response>",
+		"<RemoteAddr>192.0.2.1</RemoteAddr>",
+		"<ReverseDNS><Query>192.0.2.1</Query><Names><Name>host.example.com.</Name></Names>",
+		`<Header><Entry name="Accept">*/*</Entry><Entry name="User-Agent">curl/7.64.1</Entry></Header>`,
+		`<Insights><Entry name="Proxy">via</Entry></Insights>`,
+		`<Timings><Entry name="dns">12</Entry></Timings>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("xml.Marshal(%+v) = %q, want it to contain %q", resp, got, want)
+		}
+	}
+}
+
+func TestXMLHandlerError(t *testing.T) {
+	s := newDefaultServer(&conf.Config{Debug: true})
+
+	req := httptest.NewRequest("GET", "/xml?host=example.com", nil)
+	w := httptest.NewRecorder()
+	s.XMLHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("XMLHandler(%q) code = %d, want %d", req.URL, w.Code, http.StatusBadRequest)
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/xml"; got != want {
+		t.Errorf("XMLHandler(%q) Content-Type = %q, want %q", req.URL, got, want)
+	}
+
+	got := w.Body.String()
+	if err := wellFormed(got); err != nil {
+		t.Errorf("XMLHandler(%q) = %q, not well formed: %s", req.URL, got, err)
+	}
+	want := `<Error code="INVALID_IP" value="example.com">getting remote addr: invalid IP address &#34;example.com&#34;</Error>`
+	if !strings.Contains(got, want) {
+		t.Errorf("XMLHandler(%q) = %q, want it to contain %q", req.URL, got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package myip
+
+import (
+	"bytes"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLHandler does the lookups and returns the results as YAML.
+func (s *DefaultServer) YAMLHandler(w http.ResponseWriter, req *http.Request) {
+	s.metrics.request(formatYAML)
+
+	response, err := s.MyIPHandler(req)
+	if cancelled(err) {
+		return
+	}
+	if err != nil {
+		status, resp := errResponse(err)
+		s.writeYAMLStatus(w, req, status, resp)
+		return
+	}
+
+	response = s.addInsights(req, response)
+	writeServerTiming(w, response.serverTiming)
+	setDownload(w, req, "myip.yaml")
+	s.writeYAMLStatus(w, req, http.StatusOK, response)
+}
+
+func (s *DefaultServer) writeYAMLStatus(w http.ResponseWriter, req *http.Request, status int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/yaml")
+	s.writeCORSHeaders(w, req)
+
+	// TODO Do something with the returned err
+	var buf bytes.Buffer
+	e := yaml.NewEncoder(&buf)
+	e.Encode(obj)
+	e.Close()
+	writeBody(w, req, status, buf.Bytes())
+}
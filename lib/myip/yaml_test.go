@@ -0,0 +1,48 @@
+package myip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bramp.net/myip/lib/conf"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAMLHandler(t *testing.T) {
+	s := newDefaultServer(&conf.Config{
+		Debug: true,
+	})
+
+	data := []struct {
+		url      string
+		wantCode int
+		wantKey  string
+		want     string
+	}{
+		{url: "/yaml?include=none", wantCode: http.StatusOK, wantKey: "remoteaddr", want: "192.0.2.1"},
+		{url: "/yaml?host=example.com", wantCode: http.StatusBadRequest, wantKey: "code", want: codeInvalidIP},
+	}
+
+	for _, test := range data {
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		s.YAMLHandler(w, req)
+
+		if w.Code != test.wantCode {
+			t.Errorf("YAMLHandler(%q) code = %d, want %d", test.url, w.Code, test.wantCode)
+		}
+		if got, want := w.Header().Get("Content-Type"), "application/yaml"; got != want {
+			t.Errorf("YAMLHandler(%q) Content-Type = %q, want %q", test.url, got, want)
+		}
+
+		var got map[string]interface{}
+		if err := yaml.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("YAMLHandler(%q) returned invalid yaml: %s", test.url, err)
+		}
+		if got[test.wantKey] != test.want {
+			t.Errorf("YAMLHandler(%q)[%q] = %v, want %q", test.url, test.wantKey, got[test.wantKey], test.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package refresh periodically reloads local data sources (such as geo databases) in the
+// background.
+package refresh
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Source is a data set that can be reloaded from its backing store.
+type Source interface {
+	// Name identifies this source in the logs and stats.
+	Name() string
+
+	// Load returns a freshly loaded copy of the data. It must not modify any data previously
+	// returned, as that may still be in use by in-flight requests.
+	Load() (interface{}, error)
+}
+
+// Value holds the most recent good copy of a Source's data.
+type Value struct {
+	source Source
+	value  atomic.Value
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+	lastAttempt time.Time
+	lastError   error
+}
+
+// Load returns the most recent good copy of the data, or nil if it has never loaded successfully.
+func (v *Value) Load() interface{} {
+	return v.value.Load()
+}
+
+// refresh reloads the data, only replacing the current copy if the load was successful.
+func (v *Value) refresh() error {
+	data, err := v.source.Load()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.lastAttempt = time.Now()
+	v.lastError = err
+	if err != nil {
+		log.Warningf("Refresh of %q failed, keeping previous data: %s", v.source.Name(), err)
+		return err
+	}
+
+	v.value.Store(data)
+	v.lastRefresh = v.lastAttempt
+	log.Infof("Refresh of %q succeeded", v.source.Name())
+	return nil
+}
+
+// Stat is the refresh state of a single Source.
+type Stat struct {
+	Name string
+
+	LastRefresh *time.Time `json:",omitempty"` // Last successful refresh
+	LastAttempt *time.Time `json:",omitempty"`
+	LastError   string     `json:",omitempty"`
+}
+
+func (v *Value) stat() Stat {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s := Stat{
+		Name: v.source.Name(),
+	}
+	if !v.lastRefresh.IsZero() {
+		t := v.lastRefresh
+		s.LastRefresh = &t
+	}
+	if !v.lastAttempt.IsZero() {
+		t := v.lastAttempt
+		s.LastAttempt = &t
+	}
+	if v.lastError != nil {
+		s.LastError = v.lastError.Error()
+	}
+	return s
+}
+
+// Scheduler reloads each of its Sources on a fixed interval.
+type Scheduler struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	values []*Value
+	stop   chan struct{}
+}
+
+// NewScheduler returns a Scheduler which refreshes every interval, once started.
+func NewScheduler(interval time.Duration) *Scheduler {
+	return &Scheduler{
+		interval: interval,
+	}
+}
+
+// Add loads the source for the first time, and schedules it to be refreshed. The returned Value
+// is always usable, even if the initial load failed (in which case it'll be retried with the
+// next refresh).
+func (s *Scheduler) Add(source Source) (*Value, error) {
+	v := &Value{
+		source: source,
+	}
+	err := v.refresh()
+
+	s.mu.Lock()
+	s.values = append(s.values, v)
+	s.mu.Unlock()
+
+	return v, err
+}
+
+// Refresh reloads all the sources now.
+func (s *Scheduler) Refresh() {
+	s.mu.Lock()
+	values := append([]*Value(nil), s.values...)
+	s.mu.Unlock()
+
+	for _, v := range values {
+		v.refresh() // Errors are logged, and the previous data retained
+	}
+}
+
+// Start begins refreshing in the background. It does nothing if the interval is not positive, or
+// the scheduler is already started.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.interval <= 0 || s.stop != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.stop = stop
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Refresh()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops any background refreshing.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// Stats returns the refresh state of each source.
+func (s *Scheduler) Stats() []Stat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]Stat, 0, len(s.values))
+	for _, v := range s.values {
+		stats = append(stats, v.stat())
+	}
+	return stats
+}
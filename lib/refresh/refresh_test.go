@@ -0,0 +1,79 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refresh
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeSource returns each of its results in turn.
+type fakeSource struct {
+	results []interface{}
+}
+
+func (f *fakeSource) Name() string { return "fake" }
+
+func (f *fakeSource) Load() (interface{}, error) {
+	result := f.results[0]
+	f.results = f.results[1:]
+
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return result, nil
+}
+
+func TestRefreshKeepsPreviousData(t *testing.T) {
+	source := &fakeSource{
+		results: []interface{}{"v1", errors.New("broken"), "v2"},
+	}
+
+	s := NewScheduler(0)
+	v, err := s.Add(source)
+	if err != nil {
+		t.Fatalf("Add() err: %q, want nil", err)
+	}
+
+	want := []struct {
+		value     string
+		lastError string
+	}{
+		{value: "v1", lastError: ""},
+		{value: "v1", lastError: "broken"}, // The failed refresh keeps the old data.
+		{value: "v2", lastError: ""},
+	}
+
+	for i, test := range want {
+		if i > 0 {
+			s.Refresh()
+		}
+
+		if got := v.Load(); got != test.value {
+			t.Errorf("refresh %d: Load() = %v, want %q", i, got, test.value)
+		}
+
+		stats := s.Stats()
+		if len(stats) != 1 {
+			t.Fatalf("refresh %d: len(Stats()) = %d, want 1", i, len(stats))
+		}
+		if stats[0].LastError != test.lastError {
+			t.Errorf("refresh %d: Stats()[0].LastError = %q, want %q", i, stats[0].LastError, test.lastError)
+		}
+		if stats[0].LastRefresh == nil {
+			t.Errorf("refresh %d: Stats()[0].LastRefresh = nil, want a time", i)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package ua
 
 import (
+	"strings"
+
 	"github.com/ua-parser/uap-go/uaparser"
 )
 
@@ -24,3 +26,30 @@ var parser = uaparser.NewFromSaved()
 func DetermineUA(useragent string) *uaparser.Client {
 	return parser.Parse(useragent)
 }
+
+// botSignatures are substrings (in lower case) of the user agents of crawlers. "bot" matches most,
+// such as Googlebot and bingbot.
+var botSignatures = []string{
+	"bot",
+	"spider",
+	"crawler",
+}
+
+// spiderFamily is the Device.Family uaparser gives crawlers.
+const spiderFamily = "Spider"
+
+// IsBot returns true if this user agent is a known bot or crawler. The client is the parsed user
+// agent from DetermineUA, and may be nil.
+func IsBot(useragent string, client *uaparser.Client) bool {
+	if client != nil && client.Device != nil && client.Device.Family == spiderFamily {
+		return true
+	}
+
+	useragent = strings.ToLower(useragent)
+	for _, signature := range botSignatures {
+		if strings.Contains(useragent, signature) {
+			return true
+		}
+	}
+	return false
+}
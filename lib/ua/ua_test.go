@@ -46,3 +46,28 @@ func TestDetermineUA(t *testing.T) {
 		t.Errorf("DetermineUA(%q) diff: (-got +want)\n%s", ua, diff)
 	}
 }
+
+func TestIsBot(t *testing.T) {
+	data := []struct {
+		useragent string
+		want      bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", true},
+		{"Mozilla/5.0 (compatible; YandexSpider/3.0)", true},
+		{"ia_archiver", true}, // Only known to uaparser
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/60.0.3112.113 Safari/537.36", false},
+		{"curl/7.64.1", false},
+		{"", false},
+	}
+
+	for _, test := range data {
+		if got := IsBot(test.useragent, DetermineUA(test.useragent)); got != test.want {
+			t.Errorf("IsBot(%q) = %t, want %t", test.useragent, got, test.want)
+		}
+	}
+
+	if got := IsBot("Googlebot/2.1", nil); !got {
+		t.Errorf("IsBot(%q, nil) = %t, want %t", "Googlebot/2.1", got, true)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whois
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+)
+
+// AbuseContact is where abuse from a network should be reported.
+type AbuseContact struct {
+	Email string `json:",omitempty"`
+	Phone string `json:",omitempty"`
+}
+
+// The (lower case) whois fields holding the abuse contact.
+var (
+	// abuseEmailKeys are ARIN's org and network abuse POC fields, and the RIPE, APNIC and AFRINIC
+	// abuse-mailbox attribute (of the irt, role or organisation object).
+	abuseEmailKeys = map[string]bool{
+		"orgabuseemail": true,
+		"rabuseemail":   true,
+		"abuse-mailbox": true,
+	}
+
+	abusePhoneKeys = map[string]bool{
+		"orgabusephone": true,
+		"rabusephone":   true,
+	}
+)
+
+// abuseCommentRegexp matches the comment RIPE and APNIC add to their responses, such as
+// "% Abuse contact for '193.0.0.0 - 193.0.7.255' is 'abuse@ripe.net'".
+var abuseCommentRegexp = regexp.MustCompile(`(?m)^%\s*Abuse contact for .* is '([^']+@[^']+)'`)
+
+// ParseAbuseContact returns the abuse contact listed in the whois body, or nil if there is none.
+// If multiple are listed, the first is returned.
+func ParseAbuseContact(body string) *AbuseContact {
+	contact := &AbuseContact{}
+
+	// The RIPE style "phone" attribute is only the abuse phone, if it's in the same object (that
+	// is paragraph) as the abuse-mailbox.
+	var objectPhone string
+	inAbuseObject := false
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if inAbuseObject && contact.Phone == "" {
+				contact.Phone = objectPhone
+			}
+			objectPhone, inAbuseObject = "", false
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		if value == "" {
+			continue
+		}
+
+		switch {
+		case abuseEmailKeys[key]:
+			if contact.Email == "" {
+				contact.Email = value
+				inAbuseObject = key == "abuse-mailbox"
+			}
+		case abusePhoneKeys[key]:
+			if contact.Phone == "" {
+				contact.Phone = value
+			}
+		case key == "phone" && objectPhone == "":
+			objectPhone = value
+		}
+	}
+	if inAbuseObject && contact.Phone == "" {
+		contact.Phone = objectPhone
+	}
+
+	if contact.Email == "" {
+		if m := abuseCommentRegexp.FindStringSubmatch(body); m != nil {
+			contact.Email = m[1]
+		}
+	}
+
+	if *contact == (AbuseContact{}) {
+		return nil
+	}
+	return contact
+}
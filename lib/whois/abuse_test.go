@@ -0,0 +1,104 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whois
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+const ripeAbuseBody = `% Abuse contact for '193.0.0.0 - 193.0.7.255' is 'abuse@ripe.net'
+
+inetnum:        193.0.0.0 - 193.0.7.255
+netname:        RIPE-NCC
+org:            ORG-RIEN1-RIPE
+country:        NL
+abuse-c:        ops4-RIPE
+
+role:           RIPE NCC Operations
+address:        Stationsplein 11
+phone:          +31 20 535 4444
+abuse-mailbox:  abuse@ripe.net
+nic-hdl:        ops4-RIPE
+
+person:         Someone Else
+phone:          +31 20 000 0000
+`
+
+const arinAbuseBody = `NetRange:       8.8.8.0 - 8.8.8.255
+CIDR:           8.8.8.0/24
+NetName:        LVLT-GOGL-8-8-8
+
+OrgName:        Google LLC
+OrgId:          GOGL
+
+OrgAbuseHandle: ABUSE5250-ARIN
+OrgAbuseName:   Abuse
+OrgAbusePhone:  +1-650-253-0000
+OrgAbuseEmail:  network-abuse@google.com
+`
+
+const apnicAbuseBody = `% Abuse contact for '1.2.3.0 - 1.2.3.255' is 'abuse@apnic.net'
+
+inetnum:        1.2.3.0 - 1.2.3.255
+netname:        Debogon-prefix
+mnt-irt:        IRT-APNICRANDNET-AU
+
+irt:            IRT-APNICRANDNET-AU
+e-mail:         helpdesk@apnic.net
+abuse-mailbox:  helpdesk@apnic.net
+`
+
+func TestParseAbuseContact(t *testing.T) {
+	data := []struct {
+		name string
+		body string
+		want *AbuseContact
+	}{
+		{
+			name: "ripe",
+			body: ripeAbuseBody,
+			want: &AbuseContact{Email: "abuse@ripe.net", Phone: "+31 20 535 4444"},
+		}, {
+			name: "arin",
+			body: arinAbuseBody,
+			want: &AbuseContact{Email: "network-abuse@google.com", Phone: "+1-650-253-0000"},
+		}, {
+			name: "apnic",
+			body: apnicAbuseBody,
+			want: &AbuseContact{Email: "helpdesk@apnic.net"},
+		}, {
+			name: "comment only",
+			body: "% Abuse contact for '192.0.2.0 - 192.0.2.255' is 'abuse@example.com'\n\ninetnum: 192.0.2.0 - 192.0.2.255\n",
+			want: &AbuseContact{Email: "abuse@example.com"},
+		}, {
+			name: "none",
+			body: "NetRange: 192.0.2.0 - 192.0.2.255\nOrgName: Example\n",
+			want: nil,
+		}, {
+			name: "empty",
+			body: "",
+			want: nil,
+		},
+	}
+
+	for _, test := range data {
+		got := ParseAbuseContact(test.body)
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("ParseAbuseContact(%s) diff: (-got +want)\n%s", test.name, diff)
+		}
+	}
+}
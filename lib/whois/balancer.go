@@ -0,0 +1,109 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whois
+
+import (
+	"sync"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+)
+
+// mirrorBackoff is how long a mirror is avoided after it returns an error.
+const mirrorBackoff = 30 * time.Second
+
+// balancer picks between a registry's mirrors using smooth weighted round-robin (as used by
+// nginx), skipping any mirror that has recently failed.
+type balancer struct {
+	mu      sync.Mutex
+	mirrors []*mirror
+
+	now func() time.Time // Overridden in tests
+}
+
+type mirror struct {
+	host    string
+	weight  int
+	current int
+
+	downUntil time.Time
+}
+
+func newBalancer(mirrors []conf.WhoisMirror) *balancer {
+	b := &balancer{
+		now: time.Now,
+	}
+	for _, m := range mirrors {
+		weight := m.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		b.mirrors = append(b.mirrors, &mirror{
+			host:   m.Host,
+			weight: weight,
+		})
+	}
+	return b
+}
+
+// pick returns the host of the next mirror to query.
+func (b *balancer) pick() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	total := 0
+	var best *mirror
+	for _, m := range b.mirrors {
+		if now.Before(m.downUntil) {
+			continue
+		}
+		m.current += m.weight
+		total += m.weight
+		if best == nil || m.current > best.current {
+			best = m
+		}
+	}
+
+	if best == nil {
+		// Every mirror is failing, so try the one that will recover first.
+		for _, m := range b.mirrors {
+			if best == nil || m.downUntil.Before(best.downUntil) {
+				best = m
+			}
+		}
+		return best.host
+	}
+
+	best.current -= total
+	return best.host
+}
+
+// report records the result of querying the host, so failing mirrors can be avoided.
+func (b *balancer) report(host string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, m := range b.mirrors {
+		if m.host != host {
+			continue
+		}
+		if err != nil {
+			m.downUntil = b.now().Add(mirrorBackoff)
+		} else {
+			m.downUntil = time.Time{}
+		}
+	}
+}
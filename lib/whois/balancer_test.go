@@ -0,0 +1,67 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whois
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestBalancerWeights(t *testing.T) {
+	b := newBalancer([]conf.WhoisMirror{
+		{Host: "a", Weight: 3},
+		{Host: "b", Weight: 1},
+	})
+
+	got := map[string]int{}
+	for i := 0; i < 8; i++ {
+		got[b.pick()]++
+	}
+
+	want := map[string]int{"a": 6, "b": 2}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("pick() distribution diff: (-got +want)\n%s", diff)
+	}
+}
+
+func TestBalancerAvoidsFailingMirror(t *testing.T) {
+	now := time.Now()
+	b := newBalancer([]conf.WhoisMirror{
+		{Host: "a"},
+		{Host: "b"},
+	})
+	b.now = func() time.Time { return now }
+
+	b.report("a", errors.New("connection refused"))
+	for i := 0; i < 4; i++ {
+		if got := b.pick(); got != "b" {
+			t.Errorf("pick() = %q while a is failing, want %q", got, "b")
+		}
+	}
+
+	// After the backoff, "a" is used again.
+	now = now.Add(mirrorBackoff + time.Second)
+	got := map[string]int{}
+	for i := 0; i < 4; i++ {
+		got[b.pick()]++
+	}
+	if got["a"] == 0 {
+		t.Errorf("pick() never returned %q after backoff", "a")
+	}
+}
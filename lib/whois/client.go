@@ -0,0 +1,178 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whois
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+	domainr "github.com/domainr/whois"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultClient is used by the package level functions, and has no mirrors.
+var defaultClient = NewClient(&conf.Config{})
+
+// Client issues whois queries, spreading them across any configured mirrors.
+type Client struct {
+	client  *domainr.Client
+	timeout time.Duration
+
+	// mirrors is keyed by the registry's whois server.
+	mirrors map[string]*balancer
+}
+
+// NewClient returns a Client using the whois mirrors in this config.
+func NewClient(config *conf.Config) *Client {
+	timeout := config.WhoisTimeout
+	if timeout <= 0 {
+		timeout = WhoisTimeout
+	}
+
+	c := &Client{
+		client:  domainr.NewClient(timeout),
+		timeout: timeout,
+		mirrors: make(map[string]*balancer),
+	}
+	c.client.DialContext = dialContext
+
+	for registry, mirrors := range config.WhoisMirrors {
+		if len(mirrors) > 0 {
+			c.mirrors[registry] = newBalancer(mirrors)
+		}
+	}
+
+	return c
+}
+
+// Handle generates a whois.Response
+func (c *Client) Handle(ctx context.Context, ipAddr string) *Response {
+
+	body, err := c.QueryIPWhois(ctx, ipAddr)
+	resp := &Response{
+		Query: ipAddr,
+		Body:  cleanupWhois(body),
+	}
+	resp.AbuseContact = ParseAbuseContact(resp.Body)
+	if err != nil {
+		resp.Error, resp.err = err.Error(), err
+	}
+
+	return resp
+}
+
+// QueryWhois issues a WHOIS query to the given registry's whois server, or one of its mirrors.
+func (c *Client) QueryWhois(ctx context.Context, query, registry string) (string, error) {
+	b, found := c.mirrors[registry]
+	if !found {
+		return c.query(ctx, query, registry, registry)
+	}
+
+	host := b.pick()
+	response, err := c.query(ctx, query, registry, host)
+	b.report(host, err)
+	return response, err
+}
+
+// query issues a WHOIS query to the host, returning a TimeoutError if it took longer than the
+// Client's timeout.
+func (c *Client) query(ctx context.Context, query, registry, host string) (string, error) {
+	response, err := queryWhoisWithClient(ctx, c.client, query, registry, host)
+
+	var fetchErr *domainr.FetchError
+	if errors.As(err, &fetchErr) {
+		var netErr net.Error
+		if errors.As(fetchErr.Err, &netErr) && netErr.Timeout() {
+			return "", &TimeoutError{Host: host, Timeout: c.timeout}
+		}
+	}
+	return response, err
+}
+
+// dialContext dials the whois server, closing the connection if the context is cancelled, so a
+// unresponsive server can't outlive the request. domainr only applies the context's deadline,
+// which is left to expire the connection, so it's reported as a timeout.
+func dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	// domainr.Client.FetchContext always cancels its context when it returns, so this exits.
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() == context.Canceled {
+			conn.Close()
+		}
+	}()
+	return conn, nil
+}
+
+// QueryIPWhois issues two whois queries, the first to find the right whois server,
+// and the 2nd to that server.
+func (c *Client) QueryIPWhois(ctx context.Context, ipAddr string) (string, error) {
+	response, err := c.QueryWhois(ctx, ipAddr, ianaWhoisServer)
+	if err != nil {
+		return "", err
+	}
+
+	// IANA returns a key value response with a "whois: ..." line to indicate the whois
+	// server for the owner of this IP range.
+	m, err := parseWhois(response)
+	if err != nil {
+		return "", err
+	}
+
+	host, found := m[whoisKey]
+	if !found {
+		return response, fmt.Errorf("no whois server found for %q", ipAddr)
+	}
+
+	return c.QueryWhois(ctx, ipAddr, host)
+}
+
+// queryWhoisWithClient issues a WHOIS query to the given host, which is serving the registry.
+func queryWhoisWithClient(ctx context.Context, client *domainr.Client, query, registry, host string) (string, error) {
+
+	if registry == "whois.arin.net" {
+		// ARIN's whois servers will reply with "Query terms are ambiguous" if the query
+		// is not prefixed with a "n"
+		query = "n " + query
+	}
+
+	request := &domainr.Request{
+		Query: query,
+		Host:  host,
+	}
+	if err := request.Prepare(); err != nil {
+		return "", err
+	}
+
+	log.Infof("Whois request %q from %q", query, host)
+
+	response, err := client.FetchContext(ctx, request)
+	if err != nil {
+		log.Warningf("Whois failed %q from %q: %s", query, host, err)
+		return "", err
+	}
+
+	log.Infof("Whois response %q from %q:\n%s", query, host, response)
+	return response.String(), err
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whois
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"bramp.net/myip/lib/conf"
+)
+
+// newHangingServer returns a Client whose queries all go to a whois server that accepts
+// connections, but never replies.
+func newHangingServer(t *testing.T, timeout time.Duration) *Client {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() err = %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	c := NewClient(&conf.Config{WhoisTimeout: timeout})
+	c.client.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialContext(ctx, network, l.Addr().String())
+	}
+	return c
+}
+
+func TestClientTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	c := newHangingServer(t, timeout)
+
+	start := time.Now()
+	resp := c.Handle(context.Background(), "192.0.2.1")
+	took := time.Since(start)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(resp.Err(), &timeoutErr) {
+		t.Errorf("Handle() err = %v, want a TimeoutError", resp.Err())
+	}
+	if resp.Error == "" {
+		t.Errorf("Handle().Error = %q, want the timeout", resp.Error)
+	}
+	if took > 10*timeout {
+		t.Errorf("Handle() took %s, want about %s", took, timeout)
+	}
+}
+
+func TestClientCancelled(t *testing.T) {
+	c := newHangingServer(t, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := c.QueryWhois(ctx, "192.0.2.1", ianaWhoisServer)
+	took := time.Since(start)
+
+	if err == nil {
+		t.Errorf("QueryWhois() err = nil, want a error")
+	}
+	if took > time.Second {
+		t.Errorf("QueryWhois() took %s after being cancelled, want it to return promptly", took)
+	}
+}
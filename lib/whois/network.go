@@ -0,0 +1,107 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whois
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+)
+
+var (
+	// cidrRegexp matches CIDRs, such as "8.8.8.0/24" (ARIN's CIDR, RIPE's route fields).
+	cidrRegexp = regexp.MustCompile(`[0-9a-fA-F:.]+/[0-9]{1,3}`)
+
+	// rangeRegexp matches address ranges, such as "8.8.8.0 - 8.8.8.255" (inetnum, NetRange fields).
+	rangeRegexp = regexp.MustCompile(`([0-9a-fA-F:.]+)\s+-\s+([0-9a-fA-F:.]+)`)
+)
+
+// Network returns the most specific network, in CIDR form, listed in the whois body that contains
+// the address. Returns "" if none was found.
+func Network(body, ipAddr string) string {
+	ip := normalizeIP(net.ParseIP(ipAddr))
+	if ip == nil {
+		return ""
+	}
+
+	var best *net.IPNet
+	consider := func(n *net.IPNet) {
+		if n == nil || !n.Contains(ip) {
+			return
+		}
+		if best == nil {
+			best = n
+			return
+		}
+		if a, _ := n.Mask.Size(); a > prefixLen(best) {
+			best = n
+		}
+	}
+
+	for _, cidr := range cidrRegexp.FindAllString(body, -1) {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			consider(n)
+		}
+	}
+
+	for _, match := range rangeRegexp.FindAllStringSubmatch(body, -1) {
+		start := normalizeIP(net.ParseIP(match[1]))
+		end := normalizeIP(net.ParseIP(match[2]))
+		consider(rangeBlock(start, end, ip))
+	}
+
+	if best == nil {
+		return ""
+	}
+	return best.String()
+}
+
+func prefixLen(n *net.IPNet) int {
+	l, _ := n.Mask.Size()
+	return l
+}
+
+// normalizeIP returns the IPv4 address in its 4 byte form, so it can be compared with others.
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// rangeBlock returns the largest CIDR block that contains ip, and is within the start-end range.
+func rangeBlock(start, end, ip net.IP) *net.IPNet {
+	if start == nil || end == nil || len(start) != len(ip) || len(end) != len(ip) {
+		return nil
+	}
+	if bytes.Compare(ip, start) < 0 || bytes.Compare(ip, end) > 0 {
+		return nil
+	}
+
+	bits := len(ip) * 8
+	for l := 0; l <= bits; l++ {
+		mask := net.CIDRMask(l, bits)
+		first := ip.Mask(mask)
+		last := make(net.IP, len(first))
+		for i := range first {
+			last[i] = first[i] | ^mask[i]
+		}
+
+		if bytes.Compare(first, start) >= 0 && bytes.Compare(last, end) <= 0 {
+			return &net.IPNet{IP: first, Mask: mask}
+		}
+	}
+	return nil
+}
@@ -20,9 +20,6 @@ import (
 	"fmt"
 	"strings"
 	"time"
-
-	domainr "github.com/domainr/whois"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -32,7 +29,8 @@ const (
 	// ianaWhoisServer is the address of the Internet Assigned Numbers Authority whois server.
 	ianaWhoisServer = "whois.iana.org"
 
-	// WhoisTimeout is the dial/read timeout for the whois requests.
+	// WhoisTimeout is the default dial/read timeout for the whois requests, see
+	// conf.Config.WhoisTimeout.
 	WhoisTimeout = 10 * time.Second
 )
 
@@ -43,6 +41,31 @@ type Response struct {
 	// One of the following
 	Body  string `json:",omitempty"`
 	Error string `json:",omitempty"`
+
+	// AbuseContact is parsed from the Body, if it lists one.
+	AbuseContact *AbuseContact `json:",omitempty"`
+
+	err error
+}
+
+// Err returns the error that caused Response.Error, if any.
+func (r *Response) Err() error {
+	return r.err
+}
+
+// ErrorResponse returns the Response for a query that failed with the error.
+func ErrorResponse(query string, err error) *Response {
+	return &Response{Query: query, Error: err.Error(), err: err}
+}
+
+// TimeoutError is returned when a whois server doesn't answer within the timeout.
+type TimeoutError struct {
+	Host    string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("whois query to %q timed out after %s", e.Host, e.Timeout)
 }
 
 // parseWhois takes a whois response, and splits it into key-value pairs, so fields can easily
@@ -130,72 +153,18 @@ func cleanupWhois(response string) string {
 	return strings.TrimSpace(response)
 }
 
-// Handle generates a whois.Response
+// Handle generates a whois.Response, using the default Client.
 func Handle(ctx context.Context, ipAddr string) *Response {
-
-	body, err := QueryIPWhois(ctx, ipAddr)
-	resp := &Response{
-		Query: ipAddr,
-		Body:  cleanupWhois(body),
-	}
-	if err != nil {
-		resp.Error = err.Error()
-	}
-
-	return resp
+	return defaultClient.Handle(ctx, ipAddr)
 }
 
-// QueryWhois issues a WHOIS query to the given host.
-func queryWhoisWithClient(ctx context.Context, client *domainr.Client, query, host string) (string, error) {
-
-	if host == "whois.arin.net" {
-		// ARIN's whois servers will reply with "Query terms are ambiguous" if the query
-		// is not prefixed with a "n"
-		query = "n " + query
-	}
-
-	request := &domainr.Request{
-		Query: query,
-		Host:  host,
-	}
-	if err := request.Prepare(); err != nil {
-		return "", err
-	}
-
-	log.Infof("Whois request %q from %q", query, host)
-
-	response, err := client.Fetch(request)
-	if err != nil {
-		log.Warningf("Whois failed %q from %q: %s", query, host, err)
-		return "", err
-	}
-
-	log.Infof("Whois response %q from %q:\n%s", query, host, response)
-	return response.String(), err
-}
-
-// QueryWhois issues a WHOIS query to the given host.
+// QueryWhois issues a WHOIS query to the given host, using the default Client.
 func QueryWhois(ctx context.Context, query, host string) (string, error) {
-	client := domainr.NewClient(WhoisTimeout)
-	return queryWhoisWithClient(ctx, client, query, host)
+	return defaultClient.QueryWhois(ctx, query, host)
 }
 
 // QueryIPWhois issues two whois queries, the first to find the right whois server,
-// and the 2nd to that server.
+// and the 2nd to that server. It uses the default Client.
 func QueryIPWhois(ctx context.Context, ipAddr string) (string, error) {
-	response, err := QueryWhois(ctx, ipAddr, ianaWhoisServer)
-
-	// IANA returns a key value response with a "whois: ..." line to indicate the whois
-	// server for the owner of this IP range.
-	m, err := parseWhois(response)
-	if err != nil {
-		return "", err
-	}
-
-	host, found := m[whoisKey]
-	if !found {
-		return response, fmt.Errorf("no whois server found for %q", ipAddr)
-	}
-
-	return QueryWhois(ctx, ipAddr, host)
+	return defaultClient.QueryIPWhois(ctx, ipAddr)
 }
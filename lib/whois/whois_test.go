@@ -84,3 +84,32 @@ func TestCleanupWhois(t *testing.T) {
 	}
 
 }
+
+func TestNetwork(t *testing.T) {
+	data := []struct {
+		query  string
+		result string
+		want   string
+	}{
+		{query: "1.2.3.4", result: "whois-1.txt", want: "1.0.0.0/8"},
+		{query: "8.8.8.8", result: "whois-3.txt", want: "8.8.8.0/24"}, // Most specific of two ranges
+		{query: "2601:646:c200:b466:0:0:0:1", result: "whois-4.txt", want: "2600::/12"},
+		{query: "9.9.9.9", result: "whois-1.txt", want: ""}, // Not within any listed network
+	}
+
+	for _, test := range data {
+		input, err := ioutil.ReadFile(path.Join("testdata", test.result))
+		if err != nil {
+			t.Fatalf("Failed to read test data %q: %s", test.result, err)
+		}
+		if got := Network(string(input), test.query); got != test.want {
+			t.Errorf("Network(%q, %q) = %q, want %q", test.result, test.query, got, test.want)
+		}
+	}
+
+	// Ranges that aren't a single CIDR use the block containing the address.
+	body := "inetnum: 192.0.2.0 - 192.0.3.127"
+	if got, want := Network(body, "192.0.3.1"), "192.0.3.0/25"; got != want {
+		t.Errorf("Network(%q, %q) = %q, want %q", body, "192.0.3.1", got, want)
+	}
+}